@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// client
+// Calls banner-service HTTP API with the admin token
+type client struct {
+	baseUrl string
+	token   string
+	env     string
+	http    *http.Client
+}
+
+// apiError
+// Non-2xx response of the API
+type apiError struct {
+	status    int
+	message   string
+	requestId string
+}
+
+func (e *apiError) Error() string {
+	msg := e.message
+	if msg == "" {
+		msg = http.StatusText(e.status)
+	}
+
+	if e.requestId != "" {
+		return fmt.Sprintf("%d %s (request id: %s)", e.status, msg, e.requestId)
+	}
+
+	return fmt.Sprintf("%d %s", e.status, msg)
+}
+
+func newClient(baseUrl string, token string, env string, timeout time.Duration) *client {
+	return &client{
+		baseUrl: strings.TrimRight(baseUrl, "/"),
+		token:   token,
+		env:     env,
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// do
+// Sends the request, body is encoded as JSON if not nil, the response is decoded into out if not nil
+func (c *client) do(method string, path string, query url.Values, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+
+	u := c.baseUrl + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Access-Token", c.token)
+	if c.env != "" {
+		req.Header.Set("X-Environment", c.env)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errBody dto.ErrorResponseDto
+		_ = json.Unmarshal(raw, &errBody)
+
+		requestId := errBody.RequestId
+		if requestId == "" {
+			requestId = resp.Header.Get("X-Request-Id")
+		}
+
+		return &apiError{
+			status:    resp.StatusCode,
+			message:   errBody.Error,
+			requestId: requestId,
+		}
+	}
+
+	if out == nil || len(raw) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(raw, out)
+}
@@ -0,0 +1,409 @@
+// bannerctl
+// Command line client of banner-service admin API
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const usage = `usage: bannerctl [flags] <command> [args]
+
+commands:
+  list [-feature id] [-tag id] [-limit n] [-offset n]   list banners by feature and/or tag
+  get <id>                                              show banner
+  create -f <file.json>                                 create banner, "-" reads stdin
+  patch <id> -f <file.json>                             change banner fields present in the file
+  delete <id>                                           mark banner as deleted
+  restore <id>                                          restore deleted banner
+  versions <id>                                         show banner versions
+  rollback <id> <version>                               roll banner back to the version
+  promote <id> <environment>                            copy current version of banner of -env to the environment
+  features list [-limit n] [-offset n] | create <name> | delete <id>
+  tags list [-limit n] [-offset n] | create <name> | delete <id>
+
+flags:
+`
+
+var errUsage = errors.New("invalid arguments, see bannerctl -h")
+
+func main() {
+	flags := flag.NewFlagSet("bannerctl", flag.ExitOnError)
+	baseUrl := flags.String("url", envOr("BANNERCTL_URL", "http://localhost:8080/api/v1"), "API base url, $BANNERCTL_URL")
+	token := flags.String("token", os.Getenv("BANNERCTL_TOKEN"), "admin token, $BANNERCTL_TOKEN")
+	env := flags.String("env", os.Getenv("BANNERCTL_ENV"), "environment, server default if empty, $BANNERCTL_ENV")
+	output := flags.String("o", outputTable, "output format: table or json")
+	timeout := flags.Duration("timeout", 10*time.Second, "request timeout")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if *output != outputTable && *output != outputJson {
+		fail(fmt.Errorf("unknown output format %q", *output))
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c := newClient(*baseUrl, *token, *env, *timeout)
+	p := &printer{w: os.Stdout, format: *output}
+
+	if err := run(c, p, flags.Arg(0), flags.Args()[1:]); err != nil {
+		fail(err)
+	}
+}
+
+func run(c *client, p *printer, command string, args []string) error {
+	switch command {
+	case "list":
+		return listBanners(c, p, args)
+	case "get":
+		return getBanner(c, p, args)
+	case "create":
+		return createBanner(c, p, args)
+	case "patch":
+		return patchBanner(c, p, args)
+	case "delete":
+		return bannerAction(c, p, "DELETE", "", "deleted", args)
+	case "restore":
+		return bannerAction(c, p, "POST", "/restore", "restored", args)
+	case "versions":
+		return bannerVersions(c, p, args)
+	case "rollback":
+		return rollbackBanner(c, p, args)
+	case "promote":
+		return promoteBanner(c, p, args)
+	case "features":
+		return manageCatalog(c, p, "/feature", "feature", args)
+	case "tags":
+		return manageCatalog(c, p, "/tag", "tag", args)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func listBanners(c *client, p *printer, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	featureId := fs.Int64("feature", 0, "feature id")
+	tagId := fs.Int64("tag", 0, "tag id")
+	limit := fs.Int64("limit", 0, "max number of banners")
+	offset := fs.Int64("offset", 0, "number of banners to skip")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *featureId == 0 && *tagId == 0 {
+		return errors.New("list: -feature or -tag is required")
+	}
+
+	query := url.Values{}
+	setPositive(query, "feature_id", *featureId)
+	setPositive(query, "tag_id", *tagId)
+	setPositive(query, "limit", *limit)
+	setPositive(query, "offset", *offset)
+
+	var banners []dto.FilterBannersResponseDto
+	if err := c.do("GET", "/banner", query, nil, &banners); err != nil {
+		return err
+	}
+
+	return p.banners(banners)
+}
+
+func getBanner(c *client, p *printer, args []string) error {
+	ids, err := parseIds(args, 1)
+	if err != nil {
+		return err
+	}
+
+	var banner dto.FilterBannersResponseDto
+	if err := c.do("GET", bannerPath(ids[0]), nil, nil, &banner); err != nil {
+		return err
+	}
+
+	return p.banner(banner)
+}
+
+func createBanner(c *client, p *printer, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	file := fs.String("f", "", "JSON file with tag_ids, feature_id, content and is_active")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	var banner dto.CreateBannerDto
+	if err := readJson(*file, &banner); err != nil {
+		return err
+	}
+
+	var resp dto.CreateBannerResponseDto
+	if err := c.do("POST", "/banner", nil, banner, &resp); err != nil {
+		return err
+	}
+
+	return p.created("banner", resp.BannerId)
+}
+
+func patchBanner(c *client, p *printer, args []string) error {
+	fs := flag.NewFlagSet("patch", flag.ContinueOnError)
+	file := fs.String("f", "", "JSON file with the fields to change")
+	pos, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ids, err := parseIds(pos, 1)
+	if err != nil {
+		return err
+	}
+
+	var change dto.ChangeBannerDto
+	if err := readJson(*file, &change); err != nil {
+		return err
+	}
+
+	if err := c.do("PATCH", bannerPath(ids[0]), nil, change, nil); err != nil {
+		return err
+	}
+
+	p.message("banner %d changed", ids[0])
+
+	return nil
+}
+
+// bannerAction
+// Calls an endpoint of the banner that has no request and response body
+func bannerAction(c *client, p *printer, method string, suffix string, done string, args []string) error {
+	ids, err := parseIds(args, 1)
+	if err != nil {
+		return err
+	}
+
+	if err := c.do(method, bannerPath(ids[0])+suffix, nil, nil, nil); err != nil {
+		return err
+	}
+
+	p.message("banner %d %s", ids[0], done)
+
+	return nil
+}
+
+func bannerVersions(c *client, p *printer, args []string) error {
+	ids, err := parseIds(args, 1)
+	if err != nil {
+		return err
+	}
+
+	var resp dto.GetVersionsResponseDto
+	if err := c.do("GET", bannerPath(ids[0])+"/ver", nil, nil, &resp); err != nil {
+		return err
+	}
+
+	return p.versions(resp.Versions)
+}
+
+func rollbackBanner(c *client, p *printer, args []string) error {
+	ids, err := parseIds(args, 2)
+	if err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("%s/ver/%d", bannerPath(ids[0]), ids[1])
+	if err := c.do("PATCH", path, nil, nil, nil); err != nil {
+		return err
+	}
+
+	p.message("banner %d rolled back to version %d", ids[0], ids[1])
+
+	return nil
+}
+
+func promoteBanner(c *client, p *printer, args []string) error {
+	if len(args) != 2 || args[1] == "" {
+		return errUsage
+	}
+
+	ids, err := parseIds(args[:1], 1)
+	if err != nil {
+		return err
+	}
+
+	var resp dto.PromoteBannerResponseDto
+	query := url.Values{"to": {args[1]}}
+	if err := c.do("POST", bannerPath(ids[0])+"/promote", query, nil, &resp); err != nil {
+		return err
+	}
+
+	if p.format == outputJson {
+		return p.json(resp)
+	}
+
+	p.message("banner %d promoted to %s as banner %d, version %d", ids[0], resp.Environment, resp.BannerId, resp.Version)
+
+	return nil
+}
+
+// manageCatalog
+// Handles list, create and delete of features or tags
+func manageCatalog(c *client, p *printer, path string, entity string, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet(entity+"s list", flag.ContinueOnError)
+		limit := fs.Int64("limit", 0, "max number of items")
+		offset := fs.Int64("offset", 0, "number of items to skip")
+		if _, err := parseArgs(fs, args[1:], 0); err != nil {
+			return err
+		}
+
+		query := url.Values{}
+		setPositive(query, "limit", *limit)
+		setPositive(query, "offset", *offset)
+
+		var items []models.CatalogItem
+		if err := c.do("GET", path, query, nil, &items); err != nil {
+			return err
+		}
+
+		return p.catalog(items)
+	case "create":
+		if len(args) != 2 || args[1] == "" {
+			return errUsage
+		}
+
+		var resp dto.CreateCatalogItemResponseDto
+		if err := c.do("POST", path, nil, dto.CreateCatalogItemDto{Name: args[1]}, &resp); err != nil {
+			return err
+		}
+
+		return p.created(entity, resp.Id)
+	case "delete":
+		ids, err := parseIds(args[1:], 1)
+		if err != nil {
+			return err
+		}
+
+		if err := c.do("DELETE", fmt.Sprintf("%s/%d", path, ids[0]), nil, nil, nil); err != nil {
+			return err
+		}
+
+		p.message("%s %d deleted", entity, ids[0])
+
+		return nil
+	default:
+		return errUsage
+	}
+}
+
+// -------- Helper functions --------
+
+// parseArgs
+// Parses flags placed before and after positional arguments,
+// returns positional arguments, their number must be exactly n
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%s: %w", fs.Name(), err)
+		}
+
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+
+	if len(pos) != n {
+		return nil, errUsage
+	}
+
+	return pos, nil
+}
+
+func parseIds(args []string, n int) ([]int64, error) {
+	if len(args) != n {
+		return nil, errUsage
+	}
+
+	ids := make([]int64, n)
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id %q", arg)
+		}
+		ids[i] = id
+	}
+
+	return ids, nil
+}
+
+// readJson
+// Decodes the file into v, "-" reads stdin. Unknown fields are rejected,
+// so a typo doesn't silently leave a field unchanged
+func readJson(file string, v any) error {
+	if file == "" {
+		return errors.New("-f is required")
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	return nil
+}
+
+func bannerPath(id int64) string {
+	return "/banner/" + strconv.FormatInt(id, 10)
+}
+
+func setPositive(query url.Values, key string, val int64) {
+	if val > 0 {
+		query.Set(key, strconv.FormatInt(val, 10))
+	}
+}
+
+func envOr(key string, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+
+	return def
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "bannerctl: %v\n", err)
+	os.Exit(1)
+}
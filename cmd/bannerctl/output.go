@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJson  = "json"
+)
+
+// printer
+// Prints API responses as a table or as indented JSON
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func (p *printer) json(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// message is printed in table mode only, JSON output of actions without response body is empty
+func (p *printer) message(format string, args ...any) {
+	if p.format == outputTable {
+		fmt.Fprintf(p.w, format+"\n", args...)
+	}
+}
+
+func (p *printer) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func (p *printer) banners(banners []dto.FilterBannersResponseDto) error {
+	if p.format == outputJson {
+		return p.json(banners)
+	}
+
+	rows := make([][]string, len(banners))
+	for i, b := range banners {
+		rows[i] = []string{
+			strconv.FormatInt(b.BannerId, 10),
+			strconv.FormatInt(b.FeatureId, 10),
+			joinIds(b.TagIds),
+			strconv.FormatBool(b.IsActive),
+			strconv.FormatBool(b.ToDelete),
+			formatTime(b.UpdatedAt),
+		}
+	}
+
+	return p.table([]string{"ID", "FEATURE", "TAGS", "ACTIVE", "DELETED", "UPDATED"}, rows)
+}
+
+func (p *printer) banner(b dto.FilterBannersResponseDto) error {
+	if p.format == outputJson {
+		return p.json(b)
+	}
+
+	return p.table([]string{"FIELD", "VALUE"}, [][]string{
+		{"id", strconv.FormatInt(b.BannerId, 10)},
+		{"environment", b.Environment},
+		{"external key", b.ExternalKey},
+		{"feature", strconv.FormatInt(b.FeatureId, 10)},
+		{"tags", joinIds(b.TagIds)},
+		{"active", strconv.FormatBool(b.IsActive)},
+		{"deleted", strconv.FormatBool(b.ToDelete)},
+		{"created", formatTime(b.CreatedAt)},
+		{"updated", formatTime(b.UpdatedAt)},
+		{"content", string(b.Content)},
+	})
+}
+
+func (p *printer) versions(versions []models.BannerVersion) error {
+	if p.format == outputJson {
+		return p.json(versions)
+	}
+
+	rows := make([][]string, len(versions))
+	for i, v := range versions {
+		rows[i] = []string{
+			strconv.FormatInt(v.Version, 10),
+			strconv.FormatInt(v.FeatureId, 10),
+			v.Tags,
+			formatTime(v.CreatedAt),
+			string(v.Content),
+		}
+	}
+
+	return p.table([]string{"VERSION", "FEATURE", "TAGS", "CREATED", "CONTENT"}, rows)
+}
+
+func (p *printer) catalog(items []models.CatalogItem) error {
+	if p.format == outputJson {
+		return p.json(items)
+	}
+
+	rows := make([][]string, len(items))
+	for i, item := range items {
+		rows[i] = []string{strconv.FormatInt(item.Id, 10), item.Name}
+	}
+
+	return p.table([]string{"ID", "NAME"}, rows)
+}
+
+// created prints the id of the created entity
+func (p *printer) created(entity string, id int64) error {
+	if p.format == outputJson {
+		return p.json(map[string]int64{"id": id})
+	}
+
+	p.message("%s %d created", entity, id)
+
+	return nil
+}
+
+func joinIds(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func formatTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"flag"
 	"github.com/BurntSushi/toml"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mBayzigitov/dynamic-content-service/internal/apiserver"
-	"github.com/redis/go-redis/v9"
-	"go.uber.org/zap"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/migrate"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/mBayzigitov/dynamic-content-service/migrations"
 	"log"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 var (
@@ -33,32 +36,78 @@ func main() {
 		log.Fatal(err)
 	}
 
-	logger, _ := zap.NewDevelopment()
+	logger, err := logging.New(config.Log)
+	if err != nil {
+		log.Fatalf("Unable to set up logger: %v\n", err)
+	}
 	defer logger.Sync()
 
-	time.Sleep(5 * time.Second) // wait until environment is load completely
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(context.Background(), config, logger.Sugar(), flag.Args()[1:]); err != nil {
+			logger.Sugar().Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), config.Tracing)
+	if err != nil {
+		log.Fatalf("Unable to set up tracing: %v\n", err)
+	}
 
-	dbURL := config.Postgres.GetDbUrl()
-	pool, err := pgxpool.New(context.Background(), dbURL)
+	// wait until environment is load completely
+	pool, err := apiserver.ConnectPostgres(context.Background(), config, logger.Sugar())
 	if err != nil {
 		log.Fatalf("Unable to connect to a database: %v\n", err)
 	}
-	defer pool.Close()
 
-	rediscli := redis.NewClient(&redis.Options{
-		Addr:     config.Redis.Url,
-		Password: config.Redis.Password,
-		DB:       config.Redis.Db,
-	})
-	defer rediscli.Close()
+	if config.Migrations.RunAtStartup {
+		m, err := migrate.New(pool, migrations.FS, logger.Sugar())
+		if err == nil {
+			_, err = m.Up(context.Background())
+		}
+		if err != nil {
+			log.Fatalf("Unable to migrate the database: %v\n", err)
+		}
+	}
+
+	rediscli, err := apiserver.ConnectRedis(context.Background(), config, logger.Sugar())
+	if err != nil {
+		// cache is optional, the server starts in degraded mode
+		logger.Sugar().Warnf("Redis is not available, starting without cache: %v", err)
+	}
 
+	// pool and redis client are closed by the server on shutdown
 	serv := apiserver.New(
 		config,
 		logger.Sugar(),
 		pool,
 		rediscli,
 	)
-	if err := serv.Start(); err != nil {
-		log.Fatal(err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- serv.Start()
+	}()
+
+	select {
+	case err = <-serverErr:
+		logger.Sugar().Errorf("API server stopped: %v", err)
+	case <-ctx.Done():
+		logger.Sugar().Info("Shutdown signal received")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.GetShutdownTimeout())
+	defer cancel()
+
+	if err := serv.Shutdown(shutdownCtx); err != nil {
+		logger.Sugar().Errorf("Shutdown is not clean: %v", err)
+	}
+
+	// flush spans of the last requests
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Sugar().Errorf("Unable to flush spans: %v", err)
 	}
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mBayzigitov/dynamic-content-service/internal/apiserver"
+	"github.com/mBayzigitov/dynamic-content-service/internal/migrate"
+	"github.com/mBayzigitov/dynamic-content-service/migrations"
+	"go.uber.org/zap"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = "usage: migrate up | down [steps | all] | baseline <version> | status"
+
+// runMigrate
+// Handles the migrate subcommand: up applies pending migrations, down reverts
+// the last one (or the given number of them, all reverts every one), baseline
+// records migrations up to the version as applied without running them,
+// status lists them
+func runMigrate(ctx context.Context, config *apiserver.AppConfig, logger *zap.SugaredLogger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	pool, err := apiserver.ConnectPostgres(ctx, config, logger)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	m, err := migrate.New(pool, migrations.FS, logger)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := m.Up(ctx)
+		logger.Infof("migrate: %d migrations applied", n)
+		return err
+	case "down":
+		var n int
+		switch {
+		case len(args) < 2:
+			n, err = m.Down(ctx, 1)
+		case args[1] == "all":
+			n, err = m.DownAll(ctx)
+		default:
+			steps, perr := strconv.Atoi(args[1])
+			if perr != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q, use a positive number or all", args[1])
+			}
+			n, err = m.Down(ctx, steps)
+		}
+
+		logger.Infof("migrate: %d migrations reverted", n)
+		return err
+	case "baseline":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+
+		n, err := m.Baseline(ctx, version)
+		logger.Infof("migrate: %d migrations recorded as applied", n)
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			at := "pending"
+			if s.Applied {
+				at = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, at)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
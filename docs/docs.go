@@ -56,6 +56,12 @@ const docTemplate = `{
                         "name": "X-Access-Token",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -80,6 +86,12 @@ const docTemplate = `{
                     "403": {
                         "description": "Пользователь не имеет доступа"
                     },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
@@ -116,6 +128,12 @@ const docTemplate = `{
                         "name": "X-Access-Token",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -137,6 +155,18 @@ const docTemplate = `{
                     "403": {
                         "description": "Пользователь не имеет доступа"
                     },
+                    "409": {
+                        "description": "Пара feature_id-tag_id или external_key заняты другим баннером",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
@@ -173,6 +203,12 @@ const docTemplate = `{
                         "name": "X-Access-Token",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -194,6 +230,12 @@ const docTemplate = `{
                     "404": {
                         "description": "Фича или тэг не найдены"
                     },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
@@ -203,23 +245,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/banner/{bannerId}": {
-            "delete": {
-                "description": "Удаляет баннер по banner_id",
+        "/banner/bulk": {
+            "post": {
+                "description": "Создаёт баннеры из массива. Фичи и теги всего массива проверяются одним запросом,\nпары feature_id-tag_id должны быть уникальны и внутри массива.\nВ режиме atomic баннеры создаются в одной транзакции, при ошибке в любом элементе не создаётся ничего\nи возвращается 400 с результатом по каждому элементу. В режиме best_effort создаются все корректные элементы",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "banner"
                 ],
-                "summary": "Удаление банера",
+                "summary": "Массовое создание баннеров",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Идентификатор баннера",
-                        "name": "bannerId",
-                        "in": "path",
-                        "required": true
+                        "description": "Баннеры",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.CreateBannerDto"
+                            }
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "atomic (по умолчанию) или best_effort",
+                        "name": "mode",
+                        "in": "query"
                     },
                     {
                         "type": "string",
@@ -227,16 +283,31 @@ const docTemplate = `{
                         "name": "X-Access-Token",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "Баннер успешно удалён"
+                    "200": {
+                        "description": "Результат best_effort по каждому элементу",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BulkResponseDto"
+                        }
+                    },
+                    "201": {
+                        "description": "Все баннеры созданы",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BulkResponseDto"
+                        }
                     },
                     "400": {
-                        "description": "Некорректные данные",
+                        "description": "Некорректные данные, в режиме atomic ничего не создано",
                         "schema": {
-                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                            "$ref": "#/definitions/dto.BulkResponseDto"
                         }
                     },
                     "401": {
@@ -245,8 +316,11 @@ const docTemplate = `{
                     "403": {
                         "description": "Пользователь не имеет доступа"
                     },
-                    "404": {
-                        "description": "Баннер не найден"
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
@@ -257,7 +331,7 @@ const docTemplate = `{
                 }
             },
             "patch": {
-                "description": "Изменяет баннер по данным из тела запроса",
+                "description": "Изменяет баннеры из массива так же, как PATCH /banner/{bannerId}, каждый баннер получает новую версию.\nПары feature_id-tag_id должны быть уникальны и внутри массива.\nВ режиме atomic изменения применяются в одной транзакции, при ошибке в любом элементе не меняется ничего\nи возвращается 400 с результатом по каждому элементу. В режиме best_effort применяются все корректные элементы",
                 "consumes": [
                     "application/json"
                 ],
@@ -267,40 +341,51 @@ const docTemplate = `{
                 "tags": [
                     "banner"
                 ],
-                "summary": "Изменение баннера",
+                "summary": "Массовое изменение баннеров",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Идентификатор баннера",
-                        "name": "bannerId",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Шаблон изменений баннера",
+                        "description": "Изменения баннеров",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.ChangeBannerDto"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.BulkChangeBannerDto"
+                            }
                         }
                     },
+                    {
+                        "type": "string",
+                        "description": "atomic (по умолчанию) или best_effort",
+                        "name": "mode",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Токен админа",
                         "name": "X-Access-Token",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Баннер успешно обновлён"
+                        "description": "Результат по каждому элементу",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BulkResponseDto"
+                        }
                     },
                     "400": {
-                        "description": "Некорректные данные",
+                        "description": "Некорректные данные, в режиме atomic ничего не изменено",
                         "schema": {
-                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                            "$ref": "#/definitions/dto.BulkResponseDto"
                         }
                     },
                     "401": {
@@ -309,8 +394,11 @@ const docTemplate = `{
                     "403": {
                         "description": "Пользователь не имеет доступа"
                     },
-                    "404": {
-                        "description": "Баннер не найден"
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
@@ -321,23 +409,28 @@ const docTemplate = `{
                 }
             }
         },
-        "/banner/{bannerId}/ver": {
+        "/banner/export": {
             "get": {
-                "description": "Возвращает версии баннера, имеющего указанный bannerId",
+                "description": "Возвращает неудалённые баннеры по одному JSON-объекту в строке, в порядке id.\nРезультат можно передать в /banner/import без изменений",
                 "produces": [
-                    "application/json"
+                    "application/x-ndjson"
                 ],
                 "tags": [
                     "banner"
                 ],
-                "summary": "Получение версий баннера",
+                "summary": "Выгрузка баннеров в формате NDJSON",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Идентификатор баннера",
-                        "name": "bannerId",
-                        "in": "path",
-                        "required": true
+                        "description": "Выгрузить только баннеры фичи",
+                        "name": "feature_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить историю версий",
+                        "name": "versions",
+                        "in": "query"
                     },
                     {
                         "type": "string",
@@ -345,16 +438,19 @@ const docTemplate = `{
                         "name": "X-Access-Token",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Массив версий баннера",
+                        "description": "Строка выгрузки",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/dto.GetVersionsResponseDto"
-                            }
+                            "$ref": "#/definitions/dto.BannerLineDto"
                         }
                     },
                     "400": {
@@ -369,6 +465,12 @@ const docTemplate = `{
                     "403": {
                         "description": "Пользователь не имеет доступа"
                     },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
@@ -378,11 +480,11 @@ const docTemplate = `{
                 }
             }
         },
-        "/banner/{bannerId}/ver/{versionId}": {
-            "patch": {
-                "description": "Устанавливает баннеру контекст этой версии: изменяет контент, связанные тэги, фичу и др.",
+        "/banner/import": {
+            "post": {
+                "description": "Проверяет и создаёт баннеры построчно, ошибка в строке не прерывает загрузку.\nВ режиме upsert баннер с тем же external_key перезаписывается новой версией.\nПри dry_run ничего не сохраняется, возвращается результат проверки",
                 "consumes": [
-                    "application/json"
+                    "application/x-ndjson"
                 ],
                 "produces": [
                     "application/json"
@@ -390,21 +492,28 @@ const docTemplate = `{
                 "tags": [
                     "banner"
                 ],
-                "summary": "Установка определенной версии для баннера",
+                "summary": "Загрузка баннеров в формате NDJSON",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Идентификатор баннера",
-                        "name": "bannerId",
-                        "in": "path",
-                        "required": true
+                        "description": "Баннеры, по одному в строке",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BannerLineDto"
+                        }
                     },
                     {
-                        "type": "integer",
-                        "description": "Идентификатор версии",
-                        "name": "versionId",
-                        "in": "path",
-                        "required": true
+                        "type": "string",
+                        "description": "create (по умолчанию) или upsert",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только проверить",
+                        "name": "dry_run",
+                        "in": "query"
                     },
                     {
                         "type": "string",
@@ -412,11 +521,20 @@ const docTemplate = `{
                         "name": "X-Access-Token",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Баннеру успешно выставлена указанная версия"
+                        "description": "Результат по каждой строке",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ImportBannersResponseDto"
+                        }
                     },
                     "400": {
                         "description": "Некорректные данные",
@@ -430,8 +548,11 @@ const docTemplate = `{
                     "403": {
                         "description": "Пользователь не имеет доступа"
                     },
-                    "404": {
-                        "description": "Баннер или фича не найдены"
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
                     },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
@@ -442,50 +563,117 @@ const docTemplate = `{
                 }
             }
         },
-        "/user_banner": {
-            "get": {
-                "description": "Возвращает баннер на основании featureId, tagId и useLastRevision",
+        "/banner/preview": {
+            "post": {
+                "description": "Показывает, какой баннер получит пользователь (не администратор) с указанными тегами и атрибутами\nв /user_banner. Используется актуальное состояние баннеров, кеш не читается и не изменяется.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "banner"
                 ],
-                "summary": "Получение баннера для пользователя",
+                "summary": "Предпросмотр баннера для пользователя",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Идентификатор тэга группы пользователей",
-                        "name": "tag_id",
-                        "in": "query",
-                        "required": true
+                        "description": "Пользователь",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.PreviewBannerDto"
+                        }
                     },
                     {
-                        "type": "integer",
-                        "description": "Идентификатор фичи",
-                        "name": "feature_id",
-                        "in": "query",
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
                         "required": true
                     },
                     {
-                        "type": "boolean",
-                        "description": "Получать актуальную информацию",
-                        "name": "use_last_revision",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.PreviewBannerResponseDto"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Пользователь не получит баннер"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/banner/{bannerId}": {
+            "get": {
+                "description": "Возвращает баннер с тегами, в том числе неактивный или удалённый",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Получение баннера по идентификатору",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор баннера",
+                        "name": "bannerId",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Токен пользователя",
+                        "description": "Токен админа",
                         "name": "X-Access-Token",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "JSON-отображение баннера",
+                        "description": "OK",
                         "schema": {
-                            "type": "object"
+                            "$ref": "#/definitions/dto.FilterBannersResponseDto"
                         }
                     },
                     "400": {
@@ -503,6 +691,12 @@ const docTemplate = `{
                     "404": {
                         "description": "Баннер не найден"
                     },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
@@ -510,10 +704,1251 @@ const docTemplate = `{
                         }
                     }
                 }
+            },
+            "delete": {
+                "description": "Удаляет баннер по banner_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Удаление банера",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор баннера",
+                        "name": "bannerId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Баннер успешно удалён"
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Баннер не найден"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Изменяет баннер по данным из тела запроса",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Изменение баннера",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор баннера",
+                        "name": "bannerId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Шаблон изменений баннера",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ChangeBannerDto"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Баннер успешно обновлён"
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Баннер не найден"
+                    },
+                    "409": {
+                        "description": "Пара feature_id-tag_id занята другим баннером или баннер изменён другим запросом",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/banner/{bannerId}/preview": {
+            "get": {
+                "description": "Возвращает контент версии баннера так, как его получит пользователь с указанной локалью,\nбез отката версии. Без version — текущая версия. Доступен и для неактивных, и для удалённых баннеров.\nВ режиме resolve отвечает dto.ResolveBannerResponseDto: какой баннер фичи feature_id в окружении баннера\nполучит пользователь (не администратор) с тегами tag_id и атрибутами из параметров, как в /user_banner,\nи почему исключены остальные. Без feature_id используется фича баннера, без tag_id - его теги.\nКеш не читается и не изменяется.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Предпросмотр версии баннера",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор баннера",
+                        "name": "bannerId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Номер версии, по умолчанию текущая",
+                        "name": "version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Локаль контента, по умолчанию из Accept-Language",
+                        "name": "locale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "resolve, чтобы объяснить выбор баннера",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Фича в режиме resolve, по умолчанию фича баннера",
+                        "name": "feature_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Теги пользователя в режиме resolve, до 20",
+                        "name": "tag_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Версия; в режиме resolve — dto.ResolveBannerResponseDto",
+                        "schema": {
+                            "$ref": "#/definitions/dto.VersionPreviewResponseDto"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Баннер или версия не найдены"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/banner/{bannerId}/promote": {
+            "post": {
+                "description": "Копирует текущую версию баннера окружения запроса в окружение to. Баннер с тем же external_key\n(или, если ключа нет, с теми же парами feature_id-tag_id) получает новую версию,\nесли такого баннера нет - он создаётся",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Перенос баннера в другое окружение",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор баннера",
+                        "name": "bannerId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Целевое окружение, например production",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Баннер перенесён",
+                        "schema": {
+                            "$ref": "#/definitions/dto.PromoteBannerResponseDto"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Баннер не найден"
+                    },
+                    "409": {
+                        "description": "Пара feature_id-tag_id занята другим баннером",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/banner/{bannerId}/restore": {
+            "post": {
+                "description": "Снимает отметку об удалении, пока баннер не удалён окончательно",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Восстановление удалённого баннера",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор баннера",
+                        "name": "bannerId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Баннер успешно восстановлен"
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Удалённый баннер не найден"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/banner/{bannerId}/ver": {
+            "get": {
+                "description": "Возвращает версии баннера, имеющего указанный bannerId",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Получение версий баннера",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор баннера",
+                        "name": "bannerId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Массив версий баннера",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.GetVersionsResponseDto"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/banner/{bannerId}/ver/{versionId}": {
+            "patch": {
+                "description": "Устанавливает баннеру контекст этой версии: изменяет контент, связанные тэги, фичу и др.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Установка определенной версии для баннера",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор баннера",
+                        "name": "bannerId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор версии",
+                        "name": "versionId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Баннеру успешно выставлена указанная версия"
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Баннер или фича не найдены"
+                    },
+                    "409": {
+                        "description": "Пара feature_id-tag_id занята другим баннером",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/feature": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feature"
+                ],
+                "summary": "Получение списка фич",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Лимит",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Оффсет",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CatalogItem"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feature"
+                ],
+                "summary": "Создание фичи",
+                "parameters": [
+                    {
+                        "description": "Название фичи",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateCatalogItemDto"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateCatalogItemResponseDto"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/feature/{id}": {
+            "delete": {
+                "description": "Удаляет фичу, если её не использует ни один баннер",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "feature"
+                ],
+                "summary": "Удаление фичи",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор фичи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Фича удалена"
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Фича не найдена"
+                    },
+                    "409": {
+                        "description": "Фича используется баннерами",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Возвращает 200, пока процесс и фоновые задачи работают",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка жизнеспособности сервиса",
+                "responses": {
+                    "200": {
+                        "description": "Сервис работает",
+                        "schema": {
+                            "$ref": "#/definitions/service.HealthReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис остановлен",
+                        "schema": {
+                            "$ref": "#/definitions/service.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Проверяет postgres, версию схемы БД, redis и состояние фоновых задач.\nКаждая проверка возвращает ok или fail, текст ошибки пишется только в лог.\nНедоступность redis не делает сервис неготовым (статус degraded)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Проверка готовности сервиса",
+                "responses": {
+                    "200": {
+                        "description": "Сервис готов принимать запросы",
+                        "schema": {
+                            "$ref": "#/definitions/service.HealthReport"
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис не готов",
+                        "schema": {
+                            "$ref": "#/definitions/service.HealthReport"
+                        }
+                    }
+                }
+            }
+        },
+        "/tag": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tag"
+                ],
+                "summary": "Получение списка тегов",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Лимит",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Оффсет",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CatalogItem"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tag"
+                ],
+                "summary": "Создание тега",
+                "parameters": [
+                    {
+                        "description": "Название тега",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateCatalogItemDto"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateCatalogItemResponseDto"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/tag/{id}": {
+            "delete": {
+                "description": "Удаляет тег, если его не использует ни один баннер",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tag"
+                ],
+                "summary": "Удаление тега",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор тега",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен админа",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Тег удалён"
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Тег не найден"
+                    },
+                    "409": {
+                        "description": "Тег используется баннерами",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/user_banner": {
+            "get": {
+                "description": "Возвращает баннер на основании featureId, tagId и useLastRevision.\nНеактивные баннеры возвращаются только администраторам.\nЕсли пользователь входит в несколько групп, возвращается баннер с наибольшим priority,\nпри равных priority - с наименьшим id. Если ни одной группе баннер не назначен,\nвозвращается баннер фичи по умолчанию (is_default).\nБаннер с правилом (rule) возвращается, только если правило выполняется для атрибутов запроса:\nplatform, app_version, country и пользовательских attr.\u003cимя\u003e, например attr.segment=beta.\nВозвращается вариант контента первой из запрошенных локалей, которая есть у баннера (pt-br, затем pt),\nиначе контент по умолчанию. Локаль варианта указывается в Content-Language",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "banner"
+                ],
+                "summary": "Получение баннера для пользователя",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Идентификаторы тэгов групп пользователя, до 20",
+                        "name": "tag_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Идентификатор фичи",
+                        "name": "feature_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Получать актуальную информацию",
+                        "name": "use_last_revision",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Локаль варианта контента, например en или pt-br, важнее Accept-Language",
+                        "name": "locale",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Платформа пользователя для правил таргетинга",
+                        "name": "platform",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Версия приложения для правил таргетинга",
+                        "name": "app_version",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Страна пользователя для правил таргетинга",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Токен пользователя",
+                        "name": "X-Access-Token",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Предпочитаемые локали варианта контента",
+                        "name": "Accept-Language",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Окружение, по умолчанию production",
+                        "name": "X-Environment",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JSON-отображение баннера",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные данные",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "401": {
+                        "description": "Пользователь не авторизован"
+                    },
+                    "403": {
+                        "description": "Пользователь не имеет доступа"
+                    },
+                    "404": {
+                        "description": "Баннер не найден"
+                    },
+                    "429": {
+                        "description": "Слишком много запросов",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ErrorResponseDto"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dto.BannerLineDto": {
+            "type": "object",
+            "required": [
+                "content",
+                "feature_id",
+                "tag_ids"
+            ],
+            "properties": {
+                "banner_id": {
+                    "type": "integer"
+                },
+                "content": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "external_key": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "feature_id": {
+                    "type": "integer"
+                },
+                "is_active": {
+                    "type": "boolean"
+                },
+                "is_default": {
+                    "type": "boolean"
+                },
+                "locales": {
+                    "$ref": "#/definitions/models.Locales"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "tag_ids": {
+                    "type": "array",
+                    "minItems": 1,
+                    "uniqueItems": true,
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BannerVersion"
+                    }
+                }
+            }
+        },
+        "dto.BulkChangeBannerDto": {
+            "type": "object",
+            "required": [
+                "banner_id"
+            ],
+            "properties": {
+                "banner_id": {
+                    "type": "integer"
+                },
+                "content": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "feature_id": {
+                    "type": "integer"
+                },
+                "is_active": {
+                    "type": "boolean"
+                },
+                "is_default": {
+                    "type": "boolean"
+                },
+                "locales": {
+                    "$ref": "#/definitions/models.Locales"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
+                "tag_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
             }
-        }
-    },
-    "definitions": {
+        },
+        "dto.BulkItemResultDto": {
+            "type": "object",
+            "properties": {
+                "banner_id": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "index": {
+                    "description": "позиция в теле запроса, с нуля",
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.BulkResponseDto": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "description": "число сохранённых баннеров",
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.BulkItemResultDto"
+                    }
+                },
+                "mode": {
+                    "description": "atomic или best_effort",
+                    "type": "string"
+                }
+            }
+        },
         "dto.ChangeBannerDto": {
             "type": "object",
             "properties": {
@@ -529,6 +1964,19 @@ const docTemplate = `{
                 "is_active": {
                     "type": "boolean"
                 },
+                "is_default": {
+                    "type": "boolean"
+                },
+                "locales": {
+                    "$ref": "#/definitions/models.Locales"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
                 "tag_ids": {
                     "type": "array",
                     "items": {
@@ -551,12 +1999,29 @@ const docTemplate = `{
                         "type": "integer"
                     }
                 },
+                "external_key": {
+                    "type": "string",
+                    "maxLength": 255
+                },
                 "feature_id": {
                     "type": "integer"
                 },
                 "is_active": {
                     "type": "boolean"
                 },
+                "is_default": {
+                    "type": "boolean"
+                },
+                "locales": {
+                    "$ref": "#/definitions/models.Locales"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "type": "string",
+                    "maxLength": 1024
+                },
                 "tag_ids": {
                     "type": "array",
                     "items": {
@@ -573,11 +2038,43 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.CreateCatalogItemDto": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 255
+                }
+            }
+        },
+        "dto.CreateCatalogItemResponseDto": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                }
+            }
+        },
         "dto.ErrorResponseDto": {
             "type": "object",
             "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/serverr.FieldError"
+                    }
+                },
                 "error": {
                     "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
                 }
             }
         },
@@ -596,12 +2093,30 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "environment": {
+                    "type": "string"
+                },
+                "external_key": {
+                    "type": "string"
+                },
                 "feature_id": {
                     "type": "integer"
                 },
                 "is_active": {
                     "type": "boolean"
                 },
+                "is_default": {
+                    "type": "boolean"
+                },
+                "locales": {
+                    "$ref": "#/definitions/models.Locales"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "rule": {
+                    "type": "string"
+                },
                 "tag_ids": {
                     "type": "array",
                     "items": {
@@ -627,6 +2142,164 @@ const docTemplate = `{
                 }
             }
         },
+        "dto.ImportBannersResponseDto": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ImportLineResultDto"
+                    }
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.ImportLineResultDto": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "create или update",
+                    "type": "string"
+                },
+                "banner_id": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "external_key": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.PreviewBannerDto": {
+            "type": "object",
+            "required": [
+                "feature_id",
+                "tag_ids"
+            ],
+            "properties": {
+                "attributes": {
+                    "$ref": "#/definitions/targeting.Attributes"
+                },
+                "feature_id": {
+                    "type": "integer"
+                },
+                "locale": {
+                    "type": "string"
+                },
+                "tag_ids": {
+                    "type": "array",
+                    "maxItems": 20,
+                    "minItems": 1,
+                    "uniqueItems": true,
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "dto.PreviewBannerResponseDto": {
+            "type": "object",
+            "properties": {
+                "banner_id": {
+                    "type": "integer"
+                },
+                "content": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "locale": {
+                    "description": "локаль варианта контента",
+                    "type": "string"
+                },
+                "tag_id": {
+                    "description": "тег, по которому выбран баннер, нет для баннера по умолчанию",
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.PromoteBannerResponseDto": {
+            "type": "object",
+            "properties": {
+                "banner_id": {
+                    "description": "баннер в целевом окружении",
+                    "type": "integer"
+                },
+                "created": {
+                    "description": "баннер создан, а не обновлён",
+                    "type": "boolean"
+                },
+                "environment": {
+                    "description": "целевое окружение",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "созданная версия",
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.VersionPreviewResponseDto": {
+            "type": "object",
+            "properties": {
+                "banner_id": {
+                    "type": "integer"
+                },
+                "content": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "current": {
+                    "description": "версия действует сейчас",
+                    "type": "boolean"
+                },
+                "feature_id": {
+                    "type": "integer"
+                },
+                "is_active": {
+                    "description": "текущее состояние баннера, активность не версионируется",
+                    "type": "boolean"
+                },
+                "locale": {
+                    "description": "локаль варианта контента",
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                },
+                "tag_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "to_delete": {
+                    "type": "boolean"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
         "models.BannerVersion": {
             "type": "object",
             "properties": {
@@ -645,6 +2318,12 @@ const docTemplate = `{
                 "feature_id": {
                     "type": "integer"
                 },
+                "locales": {
+                    "$ref": "#/definitions/models.Locales"
+                },
+                "rule": {
+                    "type": "string"
+                },
                 "tags": {
                     "type": "string"
                 },
@@ -652,6 +2331,63 @@ const docTemplate = `{
                     "type": "integer"
                 }
             }
+        },
+        "models.CatalogItem": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Locales": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "array",
+                "items": {
+                    "type": "integer"
+                }
+            }
+        },
+        "serverr.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "param": {
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.HealthReport": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "targeting.Attributes": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
         }
     }
 }`
@@ -1,5 +0,0 @@
-INSERT INTO features(name)
-SELECT 'Feature ' || generate_series(1, 2000);
-
-INSERT INTO tags(name)
-SELECT 'Tag ' || generate_series(1, 2000);
\ No newline at end of file
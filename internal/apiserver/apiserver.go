@@ -1,11 +1,23 @@
 package apiserver
 
 import (
+	"context"
+	"errors"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mBayzigitov/dynamic-content-service/internal/handler/banner"
+	"github.com/mBayzigitov/dynamic-content-service/internal/handler/catalog"
+	"github.com/mBayzigitov/dynamic-content-service/internal/handler/health"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/metrics"
+	"github.com/mBayzigitov/dynamic-content-service/internal/migrate"
+	"github.com/mBayzigitov/dynamic-content-service/internal/ratelimit"
 	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
 	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/mBayzigitov/dynamic-content-service/migrations"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"net/http"
@@ -16,33 +28,125 @@ type ApiServer struct {
 	config *AppConfig
 	logger *zap.SugaredLogger
 	redis  *redis.Client
+	srv    *http.Server
+	bs     *service.BannerService
 }
 
 func New(config *AppConfig, logger *zap.SugaredLogger, p *pgxpool.Pool, rc *redis.Client) *ApiServer {
-
-	return &ApiServer{
+	serv := &ApiServer{
 		p:      p,
 		config: config,
 		logger: logger,
 		redis:  rc,
 	}
+
+	router := mux.NewRouter()
+	router.Use(logging.RequestIdMiddleware)
+	router.Use(metrics.HttpMiddleware)
+	router.Use(tracing.HttpMiddleware)
+	router.Use(RecoveryMiddleware(serv.logger))
+	router.Use(serv.config.Deadlines.DeadlineMiddleware)
+	subrouter := router.PathPrefix("/api/v1").Subrouter()
+
+	cr := repo.NewCacheRepo(serv.redis, serv.config.CacheBreaker, serv.logger)
+
+	// unauthorized requests are limited too, so limiter goes first,
+	// requests with invalid tokens are limited by ip
+	rl := ratelimit.NewRateLimiter(serv.config.RateLimit, serv.newLimiter(cr), serv.logger)
+	subrouter.Use(rl.Middleware)
+	subrouter.Use(service.TokenValidationMiddleware)
+	subrouter.Use(service.EnvironmentMiddleware(serv.config.Environments))
+	subrouter.Use(service.TenantMiddleware)
+
+	br := repo.NewBannerRepository(serv.p, serv.logger)
+	serv.bs = service.NewBannerService(br, cr, serv.config.Cache, serv.config.Environments, serv.config.Tenants, serv.logger)
+
+	bh := banner.NewHandler(serv.bs, serv.logger)
+	bh.RegisterRoutes(subrouter)
+
+	ch := catalog.NewHandler(service.NewCatalogService(repo.NewCatalogRepository(serv.p, serv.logger)), serv.logger)
+	ch.RegisterRoutes(subrouter)
+
+	schemaVersion, err := migrate.Latest(migrations.FS)
+	if err != nil {
+		serv.logger.Errorf("schema version is not checked by readiness: %v", err)
+	}
+
+	// health endpoints are not under token validation
+	hh := health.NewHandler(service.NewHealthService(serv.p, br, cr, serv.bs, schemaVersion, serv.logger))
+	hh.RegisterRoutes(router)
+
+	if err := prometheus.Register(metrics.NewPoolCollector(serv.p)); err != nil {
+		serv.logger.Warnf("pgx pool metrics are not registered: %v", err)
+	}
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	sc := serv.config.Server.withDefaults()
+	serv.srv = &http.Server{
+		Addr:         serv.config.ServerPort,
+		Handler:      router,
+		ReadTimeout:  sc.ReadTimeout,
+		WriteTimeout: sc.WriteTimeout,
+		IdleTimeout:  sc.IdleTimeout,
+	}
+
+	return serv
+}
+
+// newLimiter
+// Returns the limiter of the configured backend
+func (serv *ApiServer) newLimiter(cr *repo.CacheRepo) ratelimit.Limiter {
+	switch serv.config.RateLimit.Backend {
+	case ratelimit.BackendRedis:
+		return ratelimit.NewRedisLimiter(cr, serv.logger)
+	case "", ratelimit.BackendMemory:
+		return ratelimit.NewMemoryLimiter()
+	default:
+		serv.logger.Warnf("unknown rate limit backend %q, limiting in memory", serv.config.RateLimit.Backend)
+		return ratelimit.NewMemoryLimiter()
+	}
 }
 
+// Start
+// Serves requests until Shutdown is called, returns nil in that case
 func (serv *ApiServer) Start() error {
 	serv.logger.Info("Starting API server")
 
-	router := mux.NewRouter()
-	subrouter := router.PathPrefix("/api/v1").Subrouter()
+	serv.bs.StartWarmUp()
 
-	subrouter.Use(service.TokenValidationMiddleware)
+	err := serv.srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
 
-	cr := repo.NewCacheRepo(serv.redis)
+	return err
+}
 
-	br := repo.NewBannerRepository(serv.p)
-	bs := service.NewBannerService(br, cr)
+// Shutdown
+// Stops accepting connections and drains in-flight requests, then stops
+// background jobs and closes storage connections. Postgres and redis are
+// closed last because in-flight requests and jobs still use them
+func (serv *ApiServer) Shutdown(ctx context.Context) error {
+	serv.logger.Info("Shutting down API server")
+	var errs []error
 
-	bh := banner.NewHandler(bs)
-	bh.RegisterRoutes(subrouter)
+	if err := serv.srv.Shutdown(ctx); err != nil {
+		serv.logger.Errorf("unable to drain requests: %v", err)
+		errs = append(errs, err)
+	}
+
+	if err := serv.bs.Stop(ctx); err != nil {
+		serv.logger.Errorf("unable to stop background jobs: %v", err)
+		errs = append(errs, err)
+	}
+
+	serv.p.Close()
+	serv.logger.Info("Postgres pool is closed")
+
+	if err := serv.redis.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	serv.logger.Info("Redis client is closed")
 
-	return http.ListenAndServe(serv.config.ServerPort, router)
+	return errors.Join(errs...)
 }
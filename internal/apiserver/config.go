@@ -4,15 +4,40 @@ import (
 	"context"
 	"fmt"
 	"github.com/joho/godotenv"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/migrate"
+	"github.com/mBayzigitov/dynamic-content-service/internal/ratelimit"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
 	"github.com/sethvargo/go-envconfig"
 	"os"
+	"time"
 )
 
 type (
 	AppConfig struct {
-		ServerPort string `toml:"server_port"`
-		Postgres   *Postgres
-		Redis      *Redis
+		ServerPort   string `toml:"server_port"`
+		Server       Server `toml:"server"`
+		Startup      Retry  `toml:"startup"`
+		Postgres     *Postgres
+		Redis        *Redis
+		Cache        service.CacheConfig       `toml:"cache"`
+		CacheBreaker repo.BreakerConfig        `toml:"cache_breaker"`
+		Tracing      tracing.Config            `toml:"tracing"`
+		Deadlines    Deadlines                 `toml:"deadlines"`
+		Log          logging.Config            `toml:"log"`
+		RateLimit    ratelimit.Config          `toml:"rate_limit"`
+		Migrations   migrate.Config            `toml:"migrations"`
+		Environments service.EnvironmentConfig `toml:"environments"`
+		Tenants      service.TenantConfig      `toml:"tenants"`
+	}
+
+	Server struct {
+		ReadTimeout     time.Duration `toml:"read_timeout"`
+		WriteTimeout    time.Duration `toml:"write_timeout"`
+		IdleTimeout     time.Duration `toml:"idle_timeout"`
+		ShutdownTimeout time.Duration `toml:"shutdown_timeout"`
 	}
 
 	Postgres struct {
@@ -62,6 +87,29 @@ func GetAppConfig() (*AppConfig, error) {
 	return &config, nil
 }
 
+func (sc Server) withDefaults() Server {
+	if sc.ReadTimeout <= 0 {
+		sc.ReadTimeout = 5 * time.Second
+	}
+	if sc.WriteTimeout <= 0 {
+		sc.WriteTimeout = 10 * time.Second
+	}
+	if sc.IdleTimeout <= 0 {
+		sc.IdleTimeout = 60 * time.Second
+	}
+	if sc.ShutdownTimeout <= 0 {
+		sc.ShutdownTimeout = 15 * time.Second
+	}
+
+	return sc
+}
+
+// GetShutdownTimeout
+// Returns the time given to drain requests and stop background jobs
+func (config *AppConfig) GetShutdownTimeout() time.Duration {
+	return config.Server.withDefaults().ShutdownTimeout
+}
+
 func (pg *Postgres) GetDbUrl() string {
 	return fmt.Sprintf(
 		"postgres://%s:%s@%s:%s/%s",
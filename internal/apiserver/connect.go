@@ -0,0 +1,102 @@
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"time"
+)
+
+type Retry struct {
+	Attempts       int           `toml:"attempts"`
+	InitialBackoff time.Duration `toml:"initial_backoff"`
+	MaxBackoff     time.Duration `toml:"max_backoff"`
+}
+
+func (rc Retry) withDefaults() Retry {
+	if rc.Attempts <= 0 {
+		rc.Attempts = 10
+	}
+	if rc.InitialBackoff <= 0 {
+		rc.InitialBackoff = 500 * time.Millisecond
+	}
+	if rc.MaxBackoff <= 0 {
+		rc.MaxBackoff = 10 * time.Second
+	}
+
+	return rc
+}
+
+// withRetry
+// Calls f until it succeeds, the delay between attempts doubles up to MaxBackoff
+func withRetry(ctx context.Context, rc Retry, logger *zap.SugaredLogger, name string, f func(ctx context.Context) error) error {
+	rc = rc.withDefaults()
+	backoff := rc.InitialBackoff
+
+	var err error
+	for attempt := 1; attempt <= rc.Attempts; attempt++ {
+		if err = f(ctx); err == nil {
+			return nil
+		}
+
+		if attempt == rc.Attempts {
+			break
+		}
+
+		logger.Warnf("%s is not available (attempt %d/%d), retry in %s: %v", name, attempt, rc.Attempts, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff = min(backoff*2, rc.MaxBackoff)
+	}
+
+	return fmt.Errorf("%s is not available after %d attempts: %w", name, rc.Attempts, err)
+}
+
+// ConnectPostgres
+// Creates the pool and waits until the database accepts connections
+func ConnectPostgres(ctx context.Context, config *AppConfig, logger *zap.SugaredLogger) (*pgxpool.Pool, error) {
+	pc, err := pgxpool.ParseConfig(config.Postgres.GetDbUrl())
+	if err != nil {
+		return nil, err
+	}
+	pc.ConnConfig.Tracer = tracing.PgxTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, pc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = withRetry(ctx, config.Startup, logger, "postgres", pool.Ping)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// ConnectRedis
+// Creates the client and waits until redis answers. Redis is optional for
+// the service, so the client is returned even if redis is not available yet
+func ConnectRedis(ctx context.Context, config *AppConfig, logger *zap.SugaredLogger) (*redis.Client, error) {
+	rediscli := redis.NewClient(&redis.Options{
+		Addr:     config.Redis.Url,
+		Password: config.Redis.Password,
+		DB:       config.Redis.Db,
+	})
+	rediscli.AddHook(tracing.RedisHook{})
+
+	err := withRetry(ctx, config.Startup, logger, "redis", func(ctx context.Context) error {
+		return rediscli.Ping(ctx).Err()
+	})
+
+	return rediscli, err
+}
@@ -0,0 +1,49 @@
+package apiserver
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// Deadlines
+// Request deadlines, Routes are keyed by mux path template (e.g. "/api/v1/banner/{bannerId}")
+// and override Default. Zero disables the deadline
+type Deadlines struct {
+	Default time.Duration            `toml:"default"`
+	Routes  map[string]time.Duration `toml:"routes"`
+}
+
+func (d Deadlines) forRoute(route string) time.Duration {
+	if timeout, ok := d.Routes[route]; ok {
+		return timeout
+	}
+
+	return d.Default
+}
+
+// DeadlineMiddleware
+// Bounds the request context with the deadline of the matched route, so queries
+// and transactions started by the request are cancelled once it is exceeded
+func (d Deadlines) DeadlineMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if cr := mux.CurrentRoute(r); cr != nil {
+			if tmpl, err := cr.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		timeout := d.forRoute(route)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,70 @@
+package apiserver
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"go.uber.org/zap"
+	"net/http"
+	"runtime/debug"
+)
+
+// writeTracker remembers whether the handler has started the response
+type writeTracker struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (wt *writeTracker) WriteHeader(status int) {
+	wt.written = true
+	wt.ResponseWriter.WriteHeader(status)
+}
+
+func (wt *writeTracker) Write(b []byte) (int, error) {
+	wt.written = true
+	return wt.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (wt *writeTracker) Unwrap() http.ResponseWriter {
+	return wt.ResponseWriter
+}
+
+// RecoveryMiddleware
+// Converts a panic in a handler into 500 response, so a single bad
+// request never takes the whole server down. When the response is
+// already started, the status can't be changed and the panic is only logged
+func RecoveryMiddleware(l *zap.SugaredLogger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wt := &writeTracker{ResponseWriter: w}
+
+			defer func() {
+				pv := recover()
+				if pv == nil {
+					return
+				}
+
+				// the response is aborted on purpose, let net/http handle it
+				if err, ok := pv.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(pv)
+				}
+
+				logging.For(r.Context(), l).Errorw(
+					"panic while handling request",
+					"panic", pv,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"stack", string(debug.Stack()),
+					"response_started", wt.written,
+				)
+				if !wt.written {
+					serverr.InternalError.Write(w, r)
+				}
+			}()
+
+			next.ServeHTTP(wt, r)
+		})
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/targeting"
 	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"reflect"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -16,18 +20,29 @@ type ValidationEntity interface {
 
 // @schema CreateBannerDto
 type CreateBannerDto struct {
-	TagIds    []int64         `json:"tag_ids" validate:"required"`
-	FeatureId int64           `json:"feature_id" validate:"required"`
-	Content   json.RawMessage `json:"content" validate:"required"`
-	IsActive  bool            `json:"is_active"`
+	TagIds      []int64         `json:"tag_ids" validate:"required"`
+	FeatureId   int64           `json:"feature_id" validate:"required"`
+	Content     json.RawMessage `json:"content" validate:"required"`
+	Locales     models.Locales  `json:"locales,omitempty" validate:"omitempty,dive,keys,locale,endkeys"`
+	Rule        string          `json:"rule,omitempty" validate:"max=1024,rule"`
+	IsActive    bool            `json:"is_active"`
+	Priority    int             `json:"priority"`
+	IsDefault   bool            `json:"is_default"`
+	ExternalKey string          `json:"external_key,omitempty" validate:"max=255"`
 }
 
 // @schema ChangeBannerDto
+// Absent fields are kept, locales replace all content variants of the banner, {} removes them,
+// empty rule removes targeting
 type ChangeBannerDto struct {
 	TagIds    []int64          `json:"tag_ids"`
 	FeatureId *int64           `json:"feature_id"`
 	Content   *json.RawMessage `json:"content"`
+	Locales   models.Locales   `json:"locales" validate:"omitempty,dive,keys,locale,endkeys"`
+	Rule      *string          `json:"rule" validate:"omitempty,max=1024,rule"`
 	IsActive  *bool            `json:"is_active"`
+	Priority  *int             `json:"priority"`
+	IsDefault *bool            `json:"is_default"`
 }
 
 // @schema CreateBannerResponseDto
@@ -42,19 +57,28 @@ type GetBannerResponseDto struct {
 
 // @schema ErrorResponseDto
 type ErrorResponseDto struct {
-	Error string `json:"error"`
+	Code      string               `json:"code"`
+	Error     string               `json:"error"`
+	Details   []serverr.FieldError `json:"details,omitempty"`
+	RequestId string               `json:"request_id"`
 }
 
 // @schema FilterBannersResponseDto
 type FilterBannersResponseDto struct {
-	BannerId  int64           `json:"banner_id"`
-	TagIds    []int64         `json:"tag_ids"`
-	FeatureId int64           `json:"feature_id"`
-	Content   json.RawMessage `json:"content"`
-	IsActive  bool            `json:"is_active"`
-	ToDelete  bool            `json:"to_delete"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
+	BannerId    int64           `json:"banner_id"`
+	TagIds      []int64         `json:"tag_ids"`
+	FeatureId   int64           `json:"feature_id"`
+	Content     json.RawMessage `json:"content"`
+	Locales     models.Locales  `json:"locales,omitempty"`
+	Rule        string          `json:"rule,omitempty"`
+	IsActive    bool            `json:"is_active"`
+	Priority    int             `json:"priority"`
+	IsDefault   bool            `json:"is_default"`
+	ToDelete    bool            `json:"to_delete"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	ExternalKey string          `json:"external_key,omitempty"`
+	Environment string          `json:"environment"`
 }
 
 // @schema GetVersionsResponseDto
@@ -62,6 +86,143 @@ type GetVersionsResponseDto struct {
 	Versions []models.BannerVersion `json:"versions"`
 }
 
+// @schema CreateCatalogItemDto
+type CreateCatalogItemDto struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// @schema CreateCatalogItemResponseDto
+type CreateCatalogItemResponseDto struct {
+	Id int64 `json:"id"`
+}
+
+// @schema BannerLineDto
+// Line of NDJSON export and import. Banner id and versions are only exported,
+// an imported banner gets a new id and starts from the first version
+type BannerLineDto struct {
+	ExternalKey string                 `json:"external_key,omitempty" validate:"max=255"`
+	BannerId    int64                  `json:"banner_id,omitempty"`
+	TagIds      []int64                `json:"tag_ids" validate:"required,min=1,unique"`
+	FeatureId   int64                  `json:"feature_id" validate:"required"`
+	Content     json.RawMessage        `json:"content" validate:"required"`
+	Locales     models.Locales         `json:"locales,omitempty" validate:"omitempty,dive,keys,locale,endkeys"`
+	Rule        string                 `json:"rule,omitempty" validate:"max=1024,rule"`
+	IsActive    bool                   `json:"is_active"`
+	Priority    int                    `json:"priority,omitempty"`
+	IsDefault   bool                   `json:"is_default,omitempty"`
+	Versions    []models.BannerVersion `json:"versions,omitempty"`
+}
+
+// ImportLine
+// Parsed line of import body, Error is set if the line is malformed
+type ImportLine struct {
+	Number int
+	Banner BannerLineDto
+	Error  *serverr.ApiError
+}
+
+// @schema ImportLineResultDto
+type ImportLineResultDto struct {
+	Line        int    `json:"line"`
+	ExternalKey string `json:"external_key,omitempty"`
+	Action      string `json:"action,omitempty"` // create или update
+	BannerId    int64  `json:"banner_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// Err is the failure of the line, it's written to Error in the language of the response
+	Err *serverr.ApiError `json:"-"`
+}
+
+// @schema ImportBannersResponseDto
+type ImportBannersResponseDto struct {
+	DryRun  bool                  `json:"dry_run"`
+	Created int                   `json:"created"`
+	Updated int                   `json:"updated"`
+	Failed  int                   `json:"failed"`
+	Lines   []ImportLineResultDto `json:"lines"`
+}
+
+// @schema PromoteBannerResponseDto
+type PromoteBannerResponseDto struct {
+	BannerId    int64  `json:"banner_id"`   // баннер в целевом окружении
+	Environment string `json:"environment"` // целевое окружение
+	Version     int64  `json:"version"`     // созданная версия
+	Created     bool   `json:"created"`     // баннер создан, а не обновлён
+}
+
+// @schema PreviewBannerDto
+// Hypothetical user, attributes are the ones passed to /user_banner as parameters
+type PreviewBannerDto struct {
+	FeatureId  int64                `json:"feature_id" validate:"required"`
+	TagIds     []int64              `json:"tag_ids" validate:"required,min=1,max=20,unique"`
+	Attributes targeting.Attributes `json:"attributes" validate:"dive,keys,attribute,endkeys"`
+	Locale     string               `json:"locale,omitempty" validate:"omitempty,locale"`
+}
+
+// @schema PreviewBannerResponseDto
+type PreviewBannerResponseDto struct {
+	BannerId int64           `json:"banner_id"`
+	TagId    int64           `json:"tag_id,omitempty"` // тег, по которому выбран баннер, нет для баннера по умолчанию
+	Locale   string          `json:"locale,omitempty"` // локаль варианта контента
+	Content  json.RawMessage `json:"content"`
+}
+
+// @schema ResolveBannerResponseDto
+type ResolveBannerResponseDto struct {
+	Banner     *PreviewBannerResponseDto `json:"banner"`     // null, если пользователь не получит баннер
+	Candidates []models.ResolveCandidate `json:"candidates"` // рассмотренные баннеры в порядке тегов, баннер по умолчанию последний
+}
+
+// @schema VersionPreviewResponseDto
+type VersionPreviewResponseDto struct {
+	BannerId  int64           `json:"banner_id"`
+	Version   int64           `json:"version"`
+	Current   bool            `json:"current"` // версия действует сейчас
+	FeatureId int64           `json:"feature_id"`
+	TagIds    []int64         `json:"tag_ids"`
+	Rule      string          `json:"rule,omitempty"`
+	IsActive  bool            `json:"is_active"` // текущее состояние баннера, активность не версионируется
+	ToDelete  bool            `json:"to_delete"`
+	Locale    string          `json:"locale,omitempty"` // локаль варианта контента
+	Content   json.RawMessage `json:"content"`
+}
+
+// @schema BulkChangeBannerDto
+type BulkChangeBannerDto struct {
+	BannerId int64 `json:"banner_id" validate:"required"`
+	ChangeBannerDto
+}
+
+// BulkCreateItem
+// Item of bulk creation body, Error is set if the item is malformed
+type BulkCreateItem struct {
+	Banner CreateBannerDto
+	Error  *serverr.ApiError
+}
+
+// BulkChangeItem
+// Item of bulk change body, Error is set if the item is malformed
+type BulkChangeItem struct {
+	Change BulkChangeBannerDto
+	Error  *serverr.ApiError
+}
+
+// @schema BulkItemResultDto
+type BulkItemResultDto struct {
+	Index    int    `json:"index"` // позиция в теле запроса, с нуля
+	BannerId int64  `json:"banner_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Err is the failure of the item, it's written to Error in the language of the response
+	Err *serverr.ApiError `json:"-"`
+}
+
+// @schema BulkResponseDto
+type BulkResponseDto struct {
+	Mode    string              `json:"mode"`    // atomic или best_effort
+	Applied int                 `json:"applied"` // число сохранённых баннеров
+	Failed  int                 `json:"failed"`
+	Items   []BulkItemResultDto `json:"items"`
+}
+
 // ///////////////////// TYPES INIT METHODS ///////////////////////
 func NewGetBannerResponse(banner *models.BannerModel) *GetBannerResponseDto {
 	return &GetBannerResponseDto{
@@ -77,14 +238,71 @@ func NewCreateBannerResponse(banner_id int64) *CreateBannerResponseDto {
 
 func NewFilterBannersResponseDto(b models.BannerTagsModel) FilterBannersResponseDto {
 	return FilterBannersResponseDto{
+		BannerId:    b.Id,
+		TagIds:      b.TagIds,
+		FeatureId:   b.FeatureId,
+		Content:     b.Content,
+		Locales:     b.Locales,
+		Rule:        b.Rule,
+		IsActive:    b.IsActive,
+		Priority:    b.Priority,
+		IsDefault:   b.IsDefault,
+		ToDelete:    b.ToDelete,
+		CreatedAt:   b.CreatedAt,
+		UpdatedAt:   b.UpdatedAt,
+		ExternalKey: b.ExternalKey,
+		Environment: b.Environment,
+	}
+}
+
+func NewBannerLine(b models.BannerTagsModel, versions []models.BannerVersion) BannerLineDto {
+	return BannerLineDto{
+		ExternalKey: b.ExternalKey,
+		BannerId:    b.Id,
+		TagIds:      b.TagIds,
+		FeatureId:   b.FeatureId,
+		Content:     b.Content,
+		Locales:     b.Locales,
+		Rule:        b.Rule,
+		IsActive:    b.IsActive,
+		Priority:    b.Priority,
+		IsDefault:   b.IsDefault,
+		Versions:    versions,
+	}
+}
+
+func NewPreviewBannerResponse(banner *models.BannerModel) *PreviewBannerResponseDto {
+	return &PreviewBannerResponseDto{
+		BannerId: banner.Id,
+		TagId:    banner.TagId,
+		Locale:   banner.Locale,
+		Content:  banner.Content,
+	}
+}
+
+func NewResolveBannerResponse(banner *models.BannerModel, candidates []models.ResolveCandidate) *ResolveBannerResponseDto {
+	resp := &ResolveBannerResponseDto{Candidates: candidates}
+	if banner.Id != 0 {
+		resp.Banner = NewPreviewBannerResponse(banner)
+	}
+	if resp.Candidates == nil {
+		resp.Candidates = []models.ResolveCandidate{}
+	}
+
+	return resp
+}
+
+func NewVersionPreviewResponse(b models.BannerTagsModel, content json.RawMessage, locale string) VersionPreviewResponseDto {
+	return VersionPreviewResponseDto{
 		BannerId:  b.Id,
-		TagIds:    b.TagIds,
+		Version:   b.LastRevision,
 		FeatureId: b.FeatureId,
-		Content:   b.Content,
+		TagIds:    b.TagIds,
+		Rule:      b.Rule,
 		IsActive:  b.IsActive,
 		ToDelete:  b.ToDelete,
-		CreatedAt: b.CreatedAt,
-		UpdatedAt: b.UpdatedAt,
+		Locale:    locale,
+		Content:   content,
 	}
 }
 
@@ -94,24 +312,17 @@ func NewBannerVersionsResponse(v []models.BannerVersion) *GetVersionsResponseDto
 	}
 }
 
+func NewCreateCatalogItemResponse(id int64) *CreateCatalogItemResponseDto {
+	return &CreateCatalogItemResponseDto{
+		Id: id,
+	}
+}
+
 // ///////////////////// HELPER FUNCTIONS ///////////////////////
 
 func (cbd *CreateBannerDto) Validate(v *validator.Validate) *serverr.ApiError {
 	if err := v.Struct(cbd); err != nil {
-		var verrs validator.ValidationErrors
-		errors.As(err, &verrs)
-
-		var errBody string
-		if verrs != nil && len(verrs) > 0 {
-			f := verrs[0]
-			errBody = "field '" + f.Field() + "' validation failed: '" + f.ActualTag() + "' is violated"
-		} else {
-			errBody = "validation error"
-		}
-
-		apierr := serverr.NewInvalidRequestError(errBody)
-
-		return apierr
+		return validationError(err)
 	}
 
 	return nil
@@ -119,23 +330,73 @@ func (cbd *CreateBannerDto) Validate(v *validator.Validate) *serverr.ApiError {
 
 func (cbd *ChangeBannerDto) Validate(v *validator.Validate) *serverr.ApiError {
 	if err := v.Struct(cbd); err != nil {
-		var verrs validator.ValidationErrors
-		errors.As(err, &verrs)
-
-		var errBody string
-		if verrs != nil && len(verrs) > 0 {
-			f := verrs[0]
-			errBody = "field '" + f.Field() + "' validation failed: '" + f.ActualTag() + "' is violated"
-		} else {
-			errBody = "validation error"
+		return validationError(err)
+	}
+
+	return nil
+}
+
+// localePattern matches locales as they are stored, lower case with hyphens, e.g. en or pt-br
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// ValidLocale
+// Reports whether the tag is a locale of content variants
+func ValidLocale(tag string) bool {
+	return localePattern.MatchString(tag)
+}
+
+// NewValidator
+// Returns the validator naming fields by their json names, "locale" rule checks locales of content
+// variants, "rule" checks targeting expressions and "attribute" checks names of attributes they use
+func NewValidator() *validator.Validate {
+	v := validator.New()
+	_ = v.RegisterValidation("locale", func(fl validator.FieldLevel) bool {
+		return ValidLocale(fl.Field().String())
+	})
+	_ = v.RegisterValidation("rule", func(fl validator.FieldLevel) bool {
+		_, err := targeting.Parse(fl.Field().String())
+		return err == nil
+	})
+	_ = v.RegisterValidation("attribute", func(fl validator.FieldLevel) bool {
+		return targeting.KnownAttribute(fl.Field().String())
+	})
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
 		}
 
-		apierr := serverr.NewInvalidRequestError(errBody)
+		return name
+	})
+
+	return v
+}
 
-		return apierr
+// validationError
+// Converts validation errors into the error listing every failed field
+func validationError(err error) *serverr.ApiError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return serverr.NewValidationError()
 	}
 
-	return nil
+	details := make([]serverr.FieldError, len(verrs))
+	for i, f := range verrs {
+		details[i] = serverr.FieldError{
+			Field: f.Field(),
+			Rule:  f.ActualTag(),
+			Param: f.Param(),
+		}
+
+		// the parse error tells what is wrong with the expression
+		if rule, ok := f.Value().(string); ok && f.ActualTag() == "rule" {
+			if _, err := targeting.Parse(rule); err != nil {
+				details[i].Param = err.Error()
+			}
+		}
+	}
+
+	return serverr.NewValidationError(details...)
 }
 
 func JsonBody(dto any) string {
@@ -143,11 +404,82 @@ func JsonBody(dto any) string {
 	return string(resp)
 }
 
+// Localize
+// Writes errors of the items in lang
+func (resp *BulkResponseDto) Localize(lang string) {
+	for i, item := range resp.Items {
+		if item.Err != nil {
+			resp.Items[i].Error = item.Err.Message(lang)
+		}
+	}
+}
+
+// Localize
+// Writes errors of the lines in lang
+func (resp *ImportBannersResponseDto) Localize(lang string) {
+	for i, line := range resp.Lines {
+		if line.Err != nil {
+			resp.Lines[i].Error = line.Err.Message(lang)
+		}
+	}
+}
+
 func (cbd *CreateBannerDto) ToModel() *models.BannerTagsModel {
 	return &models.BannerTagsModel{
-		TagIds:    cbd.TagIds,
-		FeatureId: cbd.FeatureId,
-		Content:   cbd.Content,
-		IsActive:  cbd.IsActive,
+		TagIds:      cbd.TagIds,
+		FeatureId:   cbd.FeatureId,
+		Content:     cbd.Content,
+		Locales:     cbd.Locales,
+		Rule:        cbd.Rule,
+		IsActive:    cbd.IsActive,
+		Priority:    cbd.Priority,
+		IsDefault:   cbd.IsDefault,
+		ExternalKey: cbd.ExternalKey,
+	}
+}
+
+func (pbd *PreviewBannerDto) Validate(v *validator.Validate) *serverr.ApiError {
+	if err := v.Struct(pbd); err != nil {
+		return validationError(err)
+	}
+
+	return nil
+}
+
+func (bcd *BulkChangeBannerDto) Validate(v *validator.Validate) *serverr.ApiError {
+	if err := v.Struct(bcd); err != nil {
+		return validationError(err)
+	}
+
+	return nil
+}
+
+func (bld *BannerLineDto) Validate(v *validator.Validate) *serverr.ApiError {
+	if err := v.Struct(bld); err != nil {
+		return validationError(err)
+	}
+
+	return nil
+}
+
+func (bld *BannerLineDto) ToModel() *models.BannerTagsModel {
+	return &models.BannerTagsModel{
+		TagIds:      bld.TagIds,
+		FeatureId:   bld.FeatureId,
+		Content:     bld.Content,
+		Locales:     bld.Locales,
+		Rule:        bld.Rule,
+		IsActive:    bld.IsActive,
+		Priority:    bld.Priority,
+		IsDefault:   bld.IsDefault,
+		ExternalKey: bld.ExternalKey,
 	}
 }
+
+func (cid *CreateCatalogItemDto) Validate(v *validator.Validate) *serverr.ApiError {
+	if err := v.Struct(cid); err != nil {
+		return validationError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package banner
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"net/http"
+)
+
+// maxBulkItems bounds the batch, it's applied in one transaction in atomic mode
+const maxBulkItems = 1000
+
+//	@Summary		Массовое создание баннеров
+//	@Description	Создаёт баннеры из массива. Фичи и теги всего массива проверяются одним запросом,
+//	@Description	пары feature_id-tag_id должны быть уникальны и внутри массива.
+//	@Description	В режиме atomic баннеры создаются в одной транзакции, при ошибке в любом элементе не создаётся ничего
+//	@Description	и возвращается 400 с результатом по каждому элементу. В режиме best_effort создаются все корректные элементы
+//	@Tags			banner
+//	@Accept			json
+//	@Param			request	body	[]dto.CreateBannerDto	true	"Баннеры"
+//	@Param			mode	query	string	false	"atomic (по умолчанию) или best_effort"
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		json
+//	@Success		200	{object} dto.BulkResponseDto "Результат best_effort по каждому элементу"
+//	@Success		201	{object} dto.BulkResponseDto "Все баннеры созданы"
+//	@Failure		400	{object} dto.BulkResponseDto "Некорректные данные, в режиме atomic ничего не создано"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/bulk [post]
+func (bh *BannerHandler) handleBulkCreation(w http.ResponseWriter, r *http.Request) {
+	accessErr := bh.adminOnlyAccess(r)
+	if accessErr != nil {
+		accessErr.Write(w, r)
+		return
+	}
+
+	mode, apierr := bh.bulkMode(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	raw, apierr := bh.readBulkItems(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	items := make([]dto.BulkCreateItem, len(raw))
+	for i, item := range raw {
+		if err := json.Unmarshal(item, &items[i].Banner); err != nil {
+			items[i].Error = serverr.NewInvalidRequestErrorf("Некорректный JSON: %v", err)
+		} else if apierr := items[i].Banner.Validate(bh.valid); apierr != nil {
+			items[i].Error = apierr
+		}
+	}
+
+	resp, apierr := bh.service.CreateBanners(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), items, mode)
+	if apierr != nil {
+		apierr.Write(w, r)
+		return
+	}
+
+	bh.writeBulkResponse(w, r, resp, http.StatusCreated)
+}
+
+//	@Summary		Массовое изменение баннеров
+//	@Description	Изменяет баннеры из массива так же, как PATCH /banner/{bannerId}, каждый баннер получает новую версию.
+//	@Description	Пары feature_id-tag_id должны быть уникальны и внутри массива.
+//	@Description	В режиме atomic изменения применяются в одной транзакции, при ошибке в любом элементе не меняется ничего
+//	@Description	и возвращается 400 с результатом по каждому элементу. В режиме best_effort применяются все корректные элементы
+//	@Tags			banner
+//	@Accept			json
+//	@Param			request	body	[]dto.BulkChangeBannerDto	true	"Изменения баннеров"
+//	@Param			mode	query	string	false	"atomic (по умолчанию) или best_effort"
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		json
+//	@Success		200	{object} dto.BulkResponseDto "Результат по каждому элементу"
+//	@Failure		400	{object} dto.BulkResponseDto "Некорректные данные, в режиме atomic ничего не изменено"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/bulk [patch]
+func (bh *BannerHandler) handleBulkChange(w http.ResponseWriter, r *http.Request) {
+	accessErr := bh.adminOnlyAccess(r)
+	if accessErr != nil {
+		accessErr.Write(w, r)
+		return
+	}
+
+	mode, apierr := bh.bulkMode(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	raw, apierr := bh.readBulkItems(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	items := make([]dto.BulkChangeItem, len(raw))
+	for i, item := range raw {
+		if err := json.Unmarshal(item, &items[i].Change); err != nil {
+			items[i].Error = serverr.NewInvalidRequestErrorf("Некорректный JSON: %v", err)
+		} else if apierr := items[i].Change.Validate(bh.valid); apierr != nil {
+			items[i].Error = apierr
+		}
+	}
+
+	resp, apierr := bh.service.ChangeBanners(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), items, mode)
+	if apierr != nil {
+		apierr.Write(w, r)
+		return
+	}
+
+	bh.writeBulkResponse(w, r, resp, http.StatusOK)
+}
+
+func (bh *BannerHandler) bulkMode(r *http.Request) (string, *serverr.ApiError) {
+	mode := r.URL.Query().Get(ModeParam)
+	if mode == "" {
+		return service.BulkAtomic, nil
+	}
+
+	if mode != service.BulkAtomic && mode != service.BulkBestEffort {
+		return "", serverr.NewInvalidRequestError("Некорректное значение 'mode'")
+	}
+
+	return mode, nil
+}
+
+// readBulkItems
+// Splits the body into raw items, so a malformed item is reported with the rest
+func (bh *BannerHandler) readBulkItems(r *http.Request) ([]json.RawMessage, *serverr.ApiError) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		return nil, serverr.NewInvalidRequestError("Тело запроса должно быть массивом")
+	}
+
+	if len(items) == 0 {
+		return nil, serverr.NewInvalidRequestError("Пустой массив баннеров")
+	}
+
+	if len(items) > maxBulkItems {
+		return nil, serverr.NewInvalidRequestErrorf("Больше %d баннеров в одном запросе", maxBulkItems)
+	}
+
+	return items, nil
+}
+
+// writeBulkResponse
+// Failed atomic batch is a bad request, nothing is applied then.
+// Errors of the items are in the language of Accept-Language
+func (bh *BannerHandler) writeBulkResponse(w http.ResponseWriter, r *http.Request, resp *dto.BulkResponseDto, applied int) {
+	status := http.StatusOK
+	switch {
+	case resp.Mode == service.BulkAtomic && resp.Failed > 0:
+		status = http.StatusBadRequest
+	case resp.Failed == 0:
+		status = applied
+	}
+
+	lang := serverr.Lang(r)
+	resp.Localize(lang)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(status)
+	w.Write([]byte(dto.JsonBody(resp)))
+}
@@ -0,0 +1,207 @@
+package banner
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/internal/targeting"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"net/http"
+	"strconv"
+)
+
+// PreviewResolve is the mode of preview explaining the choice of the banner
+const PreviewResolve = "resolve"
+
+//	@Summary		Предпросмотр баннера для пользователя
+//	@Description	Показывает, какой баннер получит пользователь (не администратор) с указанными тегами и атрибутами
+//	@Description	в /user_banner. Используется актуальное состояние баннеров, кеш не читается и не изменяется.
+//	@Tags			banner
+//	@Accept			json
+//	@Param			request	body dto.PreviewBannerDto true "Пользователь"
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		json
+//	@Success		200	{object} dto.PreviewBannerResponseDto
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		404	"Пользователь не получит баннер"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/preview [post]
+func (bh *BannerHandler) handleBannerPreview(w http.ResponseWriter, r *http.Request) {
+	if apierr := bh.adminOnlyAccess(r); apierr != nil {
+		apierr.Write(w, r)
+		return
+	}
+
+	var rb dto.PreviewBannerDto
+	if err := json.NewDecoder(r.Body).Decode(&rb); err != nil {
+		apierr := serverr.InvalidRequestError
+		bh.log(r).Info(err)
+		apierr.Write(w, r)
+		return
+	}
+
+	rb.Locale = service.NormalizeLocale(rb.Locale)
+	if apierr := rb.Validate(bh.valid); apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	q := service.BannerQuery{
+		FeatureId:  rb.FeatureId,
+		TagIds:     rb.TagIds,
+		Locales:    service.LocaleChain(rb.Locale, ""),
+		Attributes: rb.Attributes,
+	}
+
+	banner, apierr := bh.service.PreviewBanner(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), q)
+	if apierr != nil {
+		apierr.Write(w, r)
+		return
+	}
+
+	w.Write([]byte(dto.JsonBody(dto.NewPreviewBannerResponse(&banner))))
+}
+
+//	@Summary		Предпросмотр версии баннера
+//	@Description	Возвращает контент версии баннера так, как его получит пользователь с указанной локалью,
+//	@Description	без отката версии. Без version — текущая версия. Доступен и для неактивных, и для удалённых баннеров.
+//	@Description	В режиме resolve отвечает dto.ResolveBannerResponseDto: какой баннер фичи feature_id в окружении баннера
+//	@Description	получит пользователь (не администратор) с тегами tag_id и атрибутами из параметров, как в /user_banner,
+//	@Description	и почему исключены остальные. Без feature_id используется фича баннера, без tag_id - его теги.
+//	@Description	Кеш не читается и не изменяется.
+//	@Tags			banner
+//	@Param			bannerId path integer true "Идентификатор баннера"
+//	@Param			version	query	integer	false	"Номер версии, по умолчанию текущая"
+//	@Param			locale	query	string	false	"Локаль контента, по умолчанию из Accept-Language"
+//	@Param			mode	query	string	false	"resolve, чтобы объяснить выбор баннера"
+//	@Param			feature_id	query	integer	false	"Фича в режиме resolve, по умолчанию фича баннера"
+//	@Param			tag_id	query	[]integer	false	"Теги пользователя в режиме resolve, до 20"	collectionFormat(multi)
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		json
+//	@Success		200	{object} dto.VersionPreviewResponseDto "Версия; в режиме resolve — dto.ResolveBannerResponseDto"
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		404	"Баннер или версия не найдены"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/{bannerId}/preview [get]
+func (bh *BannerHandler) handleVersionPreview(w http.ResponseWriter, r *http.Request) {
+	if apierr := bh.adminOnlyAccess(r); apierr != nil {
+		apierr.Write(w, r)
+		return
+	}
+
+	bannerId, apierr := bh.bannerIdParam(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	mode := r.URL.Query().Get(ModeParam)
+	if mode != "" && mode != PreviewResolve {
+		apierr := serverr.NewInvalidRequestError("Некорректное значение 'mode'")
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	var version int64
+	if v := r.URL.Query().Get(VersionParam); v != "" {
+		var err error
+		version, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || version <= 0 {
+			apierr := serverr.NewInvalidRequestError("Некорректное значение version")
+			bh.log(r).Info(apierr.Error())
+			apierr.Write(w, r)
+			return
+		}
+	}
+
+	locale := service.NormalizeLocale(r.URL.Query().Get(LocaleParam))
+	if locale != "" && !dto.ValidLocale(locale) {
+		apierr := serverr.NewInvalidRequestError("Некорректное значение locale")
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	locales := service.LocaleChain(locale, r.Header.Get("Accept-Language"))
+
+	if mode == PreviewResolve {
+		bh.handleResolve(w, r, bannerId, version, locales)
+		return
+	}
+
+	resp, apierr := bh.service.PreviewVersion(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), bannerId, version, locales)
+	if apierr != nil {
+		apierr.Write(w, r)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept-Language")
+	if resp.Locale != "" {
+		w.Header().Set("Content-Language", resp.Locale)
+	}
+	w.Write([]byte(dto.JsonBody(resp)))
+}
+
+// handleResolve
+// Explains the choice of the banner of the feature, the one of the banner unless feature_id is given,
+// only the current state is resolved
+func (bh *BannerHandler) handleResolve(w http.ResponseWriter, r *http.Request, bannerId int64, version int64, locales []string) {
+	if version != 0 {
+		apierr := serverr.NewInvalidRequestError("Параметр version недоступен в режиме resolve")
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	// feature and tags of the banner are used if none are given
+	var featureId int64
+	if fi := r.URL.Query().Get(FeatureIdParam); fi != "" {
+		var err error
+		featureId, err = strconv.ParseInt(fi, 10, 64)
+		if err != nil || featureId <= 0 {
+			apierr := serverr.NewInvalidRequestError("Некорректное значение feature_id")
+			bh.log(r).Info(apierr.Error())
+			apierr.Write(w, r)
+			return
+		}
+	}
+
+	var tagIds []int64
+	if r.URL.Query().Has(TagIdParam) {
+		var apierr *serverr.ApiError
+		if tagIds, apierr = bh.tagIdsParam(r); apierr != nil {
+			bh.log(r).Info(apierr.Error())
+			apierr.Write(w, r)
+			return
+		}
+	}
+
+	res, apierr := bh.service.ResolveBanner(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), bannerId, service.BannerQuery{
+		FeatureId:  featureId,
+		TagIds:     tagIds,
+		Locales:    locales,
+		Attributes: targeting.FromQuery(r.URL.Query()),
+	})
+	if apierr != nil {
+		apierr.Write(w, r)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept-Language")
+	w.Write([]byte(dto.JsonBody(dto.NewResolveBannerResponse(&res.Banner, res.Candidates))))
+}
@@ -5,34 +5,42 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
 	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/internal/targeting"
 	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
 	"go.uber.org/zap"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 const (
 	TagIdParam            = "tag_id"
 	FeatureIdParam        = "feature_id"
 	UseLastRevisionParam  = "use_last_revision"
+	LocaleParam           = "locale"
+	VersionParam          = "version"
 	LimitParam            = "limit"
 	OffsetParam           = "offset"
 	BannerIdPathVariable  = "bannerId"
 	VersionIdPathVariable = "versionId"
 )
 
+// MaxUserTags bounds the number of tags of the user resolved in one request
+const MaxUserTags = 20
+
 type BannerHandler struct {
 	valid   *validator.Validate
 	l       *zap.SugaredLogger
 	service *service.BannerService
 }
 
-func NewHandler(service *service.BannerService) *BannerHandler {
-	loginst, _ := zap.NewDevelopment()
+func NewHandler(service *service.BannerService, l *zap.SugaredLogger) *BannerHandler {
 	return &BannerHandler{
-		valid:   validator.New(),
-		l:       loginst.Sugar(),
+		valid:   dto.NewValidator(),
+		l:       l,
 		service: service,
 	}
 }
@@ -48,24 +56,91 @@ func (bh *BannerHandler) RegisterRoutes(router *mux.Router) {
 
 	router.HandleFunc("/banner", bh.handleBannerFilter).Methods("GET")
 	router.HandleFunc("/banner", bh.handleBannerCreation).Methods("POST")
+	// registered before /banner/{bannerId}, so "export", "import", "bulk" and "preview" aren't taken for an id
+	router.HandleFunc("/banner/export", bh.handleBannerExport).Methods("GET")
+	router.HandleFunc("/banner/import", bh.handleBannerImport).Methods("POST")
+	router.HandleFunc("/banner/bulk", bh.handleBulkCreation).Methods("POST")
+	router.HandleFunc("/banner/bulk", bh.handleBulkChange).Methods("PATCH")
+	router.HandleFunc("/banner/preview", bh.handleBannerPreview).Methods("POST")
 	router.HandleFunc("/banner/{bannerId}", bh.handleBannerDeletion).Methods("DELETE")
 	router.HandleFunc("/banner", bh.handleDeleteByFeatureOrTag).Methods("DELETE")
 	router.HandleFunc("/banner/{bannerId}", bh.handleBannerChange).Methods("PATCH")
+	router.HandleFunc("/banner/{bannerId}", bh.handleBannerById).Methods("GET")
+	router.HandleFunc("/banner/{bannerId}/restore", bh.handleBannerRestore).Methods("POST")
+	router.HandleFunc("/banner/{bannerId}/promote", bh.handleBannerPromote).Methods("POST")
+	router.HandleFunc("/banner/{bannerId}/preview", bh.handleVersionPreview).Methods("GET")
 
 	router.HandleFunc("/banner/{bannerId}/ver", bh.handleGetVersions).Methods("GET")
 	router.HandleFunc("/banner/{bannerId}/ver/{versionId}", bh.handleSetVersion).Methods("PATCH")
 }
 
 // -------- Helper functions --------
-func (bh *BannerHandler) adminOnlyAccess(r *http.Request) *serverr.ApiError {
+func (bh *BannerHandler) log(r *http.Request) *zap.SugaredLogger {
+	return logging.For(r.Context(), bh.l)
+}
+
+func (bh *BannerHandler) isAdmin(r *http.Request) (bool, *serverr.ApiError) {
 	isAdmin, ok := r.Context().Value("isAdmin").(bool)
 	if !ok {
-		bh.l.Fatal(serverr.TokenParsingError)
-		return serverr.TokenParsingError
+		bh.log(r).Error(serverr.TokenParsingError)
+		return false, serverr.TokenParsingError
+	}
+
+	return isAdmin, nil
+}
+
+// bannerIdParam
+// Parses bannerId path variable
+func (bh *BannerHandler) bannerIdParam(r *http.Request) (int64, *serverr.ApiError) {
+	bi, ok := mux.Vars(r)[BannerIdPathVariable]
+	if !ok {
+		return 0, serverr.NewInvalidRequestError("Отсутствует параметр 'bannerId'")
+	}
+
+	bannerId, err := strconv.ParseInt(bi, 10, 64)
+	if err != nil {
+		return 0, serverr.NewInvalidRequestError("Неверный формат параметра 'bannerId'")
+	}
+
+	return bannerId, nil
+}
+
+// tagIdsParam
+// Parses tags of the user, tag_id is repeated or lists comma separated ids
+func (bh *BannerHandler) tagIdsParam(r *http.Request) ([]int64, *serverr.ApiError) {
+	var tagIds []int64
+	for _, ti := range r.URL.Query()[TagIdParam] {
+		for _, t := range strings.Split(ti, ",") {
+			tagId, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+			if err != nil {
+				return nil, serverr.NewInvalidRequestError("Некорректное значение tag_id")
+			}
+
+			if !slices.Contains(tagIds, tagId) {
+				tagIds = append(tagIds, tagId)
+			}
+		}
+	}
+
+	if len(tagIds) == 0 {
+		return nil, serverr.NewInvalidRequestError("Некорректное значение tag_id")
+	}
+
+	if len(tagIds) > MaxUserTags {
+		return nil, serverr.NewInvalidRequestErrorf("Больше %d tag_id в одном запросе", MaxUserTags)
+	}
+
+	return tagIds, nil
+}
+
+func (bh *BannerHandler) adminOnlyAccess(r *http.Request) *serverr.ApiError {
+	isAdmin, apierr := bh.isAdmin(r)
+	if apierr != nil {
+		return apierr
 	}
 
 	if !isAdmin {
-		bh.l.Info(serverr.ForbiddenAccessError.Error())
+		bh.log(r).Info(serverr.ForbiddenAccessError.Error())
 		return serverr.ForbiddenAccessError
 	}
 
@@ -75,13 +150,27 @@ func (bh *BannerHandler) adminOnlyAccess(r *http.Request) *serverr.ApiError {
 // -------- Handler functions --------
 
 //	@Summary		Получение баннера для пользователя
-//	@Description	Возвращает баннер на основании featureId, tagId и useLastRevision
+//	@Description	Возвращает баннер на основании featureId, tagId и useLastRevision.
+//	@Description	Неактивные баннеры возвращаются только администраторам.
+//	@Description	Если пользователь входит в несколько групп, возвращается баннер с наибольшим priority,
+//	@Description	при равных priority - с наименьшим id. Если ни одной группе баннер не назначен,
+//	@Description	возвращается баннер фичи по умолчанию (is_default).
+//	@Description	Баннер с правилом (rule) возвращается, только если правило выполняется для атрибутов запроса:
+//	@Description	platform, app_version, country и пользовательских attr.<имя>, например attr.segment=beta.
+//	@Description	Возвращается вариант контента первой из запрошенных локалей, которая есть у баннера (pt-br, затем pt),
+//	@Description	иначе контент по умолчанию. Локаль варианта указывается в Content-Language
 //	@Tags			banner
-//	@Param			tag_id				query	integer	true	"Идентификатор тэга группы пользователей"
+//	@Param			tag_id				query	[]integer	true	"Идентификаторы тэгов групп пользователя, до 20"	collectionFormat(multi)
 //	@Param			feature_id			query	integer	true	"Идентификатор фичи"
 //	@Param			use_last_revision	query	boolean	false	"Получать актуальную информацию"
+//	@Param			locale				query	string	false	"Локаль варианта контента, например en или pt-br, важнее Accept-Language"
+//	@Param			platform			query	string	false	"Платформа пользователя для правил таргетинга"
+//	@Param			app_version			query	string	false	"Версия приложения для правил таргетинга"
+//	@Param			country				query	string	false	"Страна пользователя для правил таргетинга"
 //
 // @Param X-Access-Token header string true "Токен пользователя"
+// @Param Accept-Language header string false "Предпочитаемые локали варианта контента"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
 //
 //	@Produce		json
 //	@Success		200	{object} any "JSON-отображение баннера"
@@ -89,31 +178,30 @@ func (bh *BannerHandler) adminOnlyAccess(r *http.Request) *serverr.ApiError {
 //	@Failure		401	"Пользователь не авторизован"
 //	@Failure		403	"Пользователь не имеет доступа"
 //	@Failure		404	"Баннер не найден"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
 //	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
 //	@Router			/user_banner [get]
 func (bh *BannerHandler) handleBannerGetting(w http.ResponseWriter, r *http.Request) {
 	// parse params
-	ti := r.URL.Query().Get(TagIdParam)
 	fi := r.URL.Query().Get(FeatureIdParam)
 	ulr := r.URL.Query().Get(UseLastRevisionParam)
 	var err error
 
-	var tagId, featureId int64
+	var featureId int64
 	var useLastRevision bool
 
-	tagId, err = strconv.ParseInt(ti, 10, 64)
-	if ti == "" || err != nil {
-		apierror := serverr.NewInvalidRequestError("Некорректное значение tag_id")
-		bh.l.Info(apierror.Error())
-		http.Error(w, apierror.JsonBody(), apierror.HttpStatus)
+	tagIds, apierror := bh.tagIdsParam(r)
+	if apierror != nil {
+		bh.log(r).Info(apierror.Error())
+		apierror.Write(w, r)
 		return
 	}
 
 	featureId, err = strconv.ParseInt(fi, 10, 64)
 	if fi == "" || err != nil {
 		apierror := serverr.NewInvalidRequestError("Некорректное значение feature_id")
-		bh.l.Info(apierror.Error())
-		http.Error(w, apierror.JsonBody(), apierror.HttpStatus)
+		bh.log(r).Info(apierror.Error())
+		apierror.Write(w, r)
 		return
 	}
 
@@ -124,15 +212,42 @@ func (bh *BannerHandler) handleBannerGetting(w http.ResponseWriter, r *http.Requ
 
 		if err != nil {
 			apierror := serverr.NewInvalidRequestError("Некорректное значение use_last_revision")
-			bh.l.Info(apierror.Error())
-			http.Error(w, apierror.JsonBody(), apierror.HttpStatus)
+			bh.log(r).Info(apierror.Error())
+			apierror.Write(w, r)
 			return
 		}
 	}
 
-	if resp, apierr := bh.service.GetBanner(tagId, featureId, useLastRevision); apierr != nil {
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+	locale := service.NormalizeLocale(r.URL.Query().Get(LocaleParam))
+	if locale != "" && !dto.ValidLocale(locale) {
+		apierror := serverr.NewInvalidRequestError("Некорректное значение locale")
+		bh.log(r).Info(apierror.Error())
+		apierror.Write(w, r)
+		return
+	}
+	locales := service.LocaleChain(locale, r.Header.Get("Accept-Language"))
+
+	isAdmin, apierr := bh.isAdmin(r)
+	if apierr != nil {
+		apierr.Write(w, r)
+		return
+	}
+
+	if resp, apierr := bh.service.GetBanner(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), service.BannerQuery{
+		FeatureId:       featureId,
+		TagIds:          tagIds,
+		UseLastRevision: useLastRevision,
+		IsAdmin:         isAdmin,
+		Locales:         locales,
+		Attributes:      targeting.FromQuery(r.URL.Query()),
+	}); apierr != nil {
+		apierr.Write(w, r)
 	} else {
+		// the response differs by requested locale, caches must keep it apart
+		w.Header().Set("Vary", "Accept-Language")
+		if resp.Locale != "" {
+			w.Header().Set("Content-Language", resp.Locale)
+		}
 		w.WriteHeader(http.StatusOK)
 		jsonBody := dto.JsonBody(dto.NewGetBannerResponse(&resp))
 		w.Write([]byte(jsonBody))
@@ -145,42 +260,45 @@ func (bh *BannerHandler) handleBannerGetting(w http.ResponseWriter, r *http.Requ
 // @Accept		json
 // @Param		request	body dto.CreateBannerDto true "Содержимое баннера"
 // @Param 	    X-Access-Token header string true "Токен админа"
+// @Param 	    X-Environment header string false "Окружение, по умолчанию production"
 // @Produce		json
 // @Success		201	{object} dto.CreateBannerResponseDto "Created"
 // @Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
 // @Failure		401	"Пользователь не авторизован"
 // @Failure		403	"Пользователь не имеет доступа"
+// @Failure		409	{object} dto.ErrorResponseDto "Пара feature_id-tag_id или external_key заняты другим баннером"
+// @Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
 // @Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
 // @Router	/banner [post]
 func (bh *BannerHandler) handleBannerCreation(w http.ResponseWriter, r *http.Request) {
 	accessErr := bh.adminOnlyAccess(r)
 	if accessErr != nil {
-		http.Error(w, accessErr.JsonBody(), accessErr.HttpStatus)
+		accessErr.Write(w, r)
 		return
 	}
 
 	var rb dto.CreateBannerDto
 	if err := json.NewDecoder(r.Body).Decode(&rb); err != nil {
 		apierr := serverr.InvalidRequestError
-		bh.l.Info(apierr.Error())
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	err := rb.Validate(bh.valid)
 	if err != nil {
-		bh.l.Info(err.Error())
-		http.Error(w, err.JsonBody(), err.HttpStatus)
+		bh.log(r).Info(err.Error())
+		err.Write(w, r)
 		return
 	}
 
-	if createdId, apierr := bh.service.CreateBanner(rb.ToModel()); apierr != nil {
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+	if createdId, apierr := bh.service.CreateBanner(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), rb.ToModel()); apierr != nil {
+		apierr.Write(w, r)
 	} else {
 		w.WriteHeader(201)
 		resp := dto.JsonBody(dto.NewCreateBannerResponse(createdId))
 		w.Write([]byte(resp))
-		bh.l.Infof("Banner [id=%d] is created", createdId)
+		bh.log(r).Infof("Banner [id=%d] is created", createdId)
 	}
 }
 
@@ -190,6 +308,7 @@ func (bh *BannerHandler) handleBannerCreation(w http.ResponseWriter, r *http.Req
 //	@Param			bannerId path integer true "Идентификатор баннера"
 //
 // @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
 //
 //	@Produce		json
 //	@Success		204	"Баннер успешно удалён"
@@ -197,12 +316,13 @@ func (bh *BannerHandler) handleBannerCreation(w http.ResponseWriter, r *http.Req
 //	@Failure		401	"Пользователь не авторизован"
 //	@Failure		403	"Пользователь не имеет доступа"
 //	@Failure		404	"Баннер не найден"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
 //	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
 //	@Router			/banner/{bannerId} [delete]
 func (bh *BannerHandler) handleBannerDeletion(w http.ResponseWriter, r *http.Request) {
 	accessErr := bh.adminOnlyAccess(r)
 	if accessErr != nil {
-		http.Error(w, accessErr.JsonBody(), accessErr.HttpStatus)
+		accessErr.Write(w, r)
 		return
 	}
 
@@ -213,22 +333,98 @@ func (bh *BannerHandler) handleBannerDeletion(w http.ResponseWriter, r *http.Req
 	// check whether path param exists & has correct value
 	if bi, ok := qp[BannerIdPathVariable]; !ok {
 		apierr := serverr.NewInvalidRequestError("Отсутствует параметр 'bannerId'")
-		bh.l.Info(apierr)
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr)
+		apierr.Write(w, r)
 		return
 	} else {
 		bannerId, err = strconv.ParseInt(bi, 10, 64)
 		if err != nil {
 			apierr := serverr.NewInvalidRequestError("Неверный формат параметра 'bannerId'")
-			bh.l.Info(apierr)
-			http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+			bh.log(r).Info(apierr)
+			apierr.Write(w, r)
 			return
 		}
 	}
 
 	// call service method and return response
-	if apierr := bh.service.DeleteBanner(bannerId); apierr != nil {
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+	if apierr := bh.service.DeleteBanner(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), bannerId); apierr != nil {
+		apierr.Write(w, r)
+	} else {
+		w.WriteHeader(204)
+	}
+}
+
+//	@Summary		Получение баннера по идентификатору
+//	@Description	Возвращает баннер с тегами, в том числе неактивный или удалённый
+//	@Tags			banner
+//	@Param			bannerId path integer true "Идентификатор баннера"
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		json
+//	@Success		200	{object} dto.FilterBannersResponseDto
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		404	"Баннер не найден"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/{bannerId} [get]
+func (bh *BannerHandler) handleBannerById(w http.ResponseWriter, r *http.Request) {
+	accessErr := bh.adminOnlyAccess(r)
+	if accessErr != nil {
+		accessErr.Write(w, r)
+		return
+	}
+
+	bannerId, apierr := bh.bannerIdParam(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr)
+		apierr.Write(w, r)
+		return
+	}
+
+	if banner, apierr := bh.service.GetBannerById(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), bannerId); apierr != nil {
+		apierr.Write(w, r)
+	} else {
+		w.Write([]byte(dto.JsonBody(banner)))
+	}
+}
+
+//	@Summary		Восстановление удалённого баннера
+//	@Description	Снимает отметку об удалении, пока баннер не удалён окончательно
+//	@Tags			banner
+//	@Param			bannerId path integer true "Идентификатор баннера"
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		json
+//	@Success		204	"Баннер успешно восстановлен"
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		404	"Удалённый баннер не найден"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/{bannerId}/restore [post]
+func (bh *BannerHandler) handleBannerRestore(w http.ResponseWriter, r *http.Request) {
+	accessErr := bh.adminOnlyAccess(r)
+	if accessErr != nil {
+		accessErr.Write(w, r)
+		return
+	}
+
+	bannerId, apierr := bh.bannerIdParam(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr)
+		apierr.Write(w, r)
+		return
+	}
+
+	if apierr := bh.service.RestoreBanner(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), bannerId); apierr != nil {
+		apierr.Write(w, r)
 	} else {
 		w.WriteHeader(204)
 	}
@@ -242,6 +438,7 @@ func (bh *BannerHandler) handleBannerDeletion(w http.ResponseWriter, r *http.Req
 //	@Param			request	body dto.ChangeBannerDto true	"Шаблон изменений баннера"
 //
 // @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
 //
 //	@Produce		json
 //	@Success		200	"Баннер успешно обновлён"
@@ -249,12 +446,14 @@ func (bh *BannerHandler) handleBannerDeletion(w http.ResponseWriter, r *http.Req
 //	@Failure		401	"Пользователь не авторизован"
 //	@Failure		403	"Пользователь не имеет доступа"
 //	@Failure		404	"Баннер не найден"
+//	@Failure		409	{object} dto.ErrorResponseDto "Пара feature_id-tag_id занята другим баннером или баннер изменён другим запросом"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
 //	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
 //	@Router			/banner/{bannerId} [patch]
 func (bh *BannerHandler) handleBannerChange(w http.ResponseWriter, r *http.Request) {
 	accessErr := bh.adminOnlyAccess(r)
 	if accessErr != nil {
-		http.Error(w, accessErr.JsonBody(), accessErr.HttpStatus)
+		accessErr.Write(w, r)
 		return
 	}
 
@@ -265,15 +464,15 @@ func (bh *BannerHandler) handleBannerChange(w http.ResponseWriter, r *http.Reque
 	// check whether path param exists & has correct value
 	if bi, ok := qp[BannerIdPathVariable]; !ok {
 		apierr := serverr.NewInvalidRequestError("Отсутствует параметр 'bannerId'")
-		bh.l.Info(apierr)
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr)
+		apierr.Write(w, r)
 		return
 	} else {
 		bannerId, err = strconv.ParseInt(bi, 10, 64)
 		if err != nil {
 			apierr := serverr.NewInvalidRequestError("Неверный формат параметра 'bannerId'")
-			bh.l.Info(apierr)
-			http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+			bh.log(r).Info(apierr)
+			apierr.Write(w, r)
 			return
 		}
 	}
@@ -283,14 +482,20 @@ func (bh *BannerHandler) handleBannerChange(w http.ResponseWriter, r *http.Reque
 
 	if err := dec.Decode(&cb); err != nil {
 		apierr := serverr.InvalidRequestError
-		bh.l.Info(err)
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(err)
+		apierr.Write(w, r)
+		return
+	}
+
+	if apierr := cb.Validate(bh.valid); apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	// call service method and return response
-	if apierr := bh.service.ChangeBanner(bannerId, cb); apierr != nil {
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+	if apierr := bh.service.ChangeBanner(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), bannerId, cb); apierr != nil {
+		apierr.Write(w, r)
 	} else {
 		w.WriteHeader(200)
 	}
@@ -305,18 +510,20 @@ func (bh *BannerHandler) handleBannerChange(w http.ResponseWriter, r *http.Reque
 //	@Param			offset		query	integer	false	"Оффсет"
 //
 // @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
 //
 //	@Produce		json
 //	@Success		200	{array}	dto.FilterBannersResponseDto "OK"
 //	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
 //	@Failure		401	"Пользователь не авторизован"
 //	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
 //	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
 //	@Router			/banner [get]
 func (bh *BannerHandler) handleBannerFilter(w http.ResponseWriter, r *http.Request) {
 	accessErr := bh.adminOnlyAccess(r)
 	if accessErr != nil {
-		http.Error(w, accessErr.JsonBody(), accessErr.HttpStatus)
+		accessErr.Write(w, r)
 		return
 	}
 
@@ -332,42 +539,42 @@ func (bh *BannerHandler) handleBannerFilter(w http.ResponseWriter, r *http.Reque
 	var tagId, featureId, limit, offset int64
 	offset, apierr = bh.parsePosInt(off, "offset")
 	if apierr != nil {
-		bh.l.Info(apierr.Error())
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	limit, apierr = bh.parsePosInt(lim, "limit")
 	if apierr != nil {
-		bh.l.Info(apierr.Error())
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	featureId, apierr = bh.parsePosInt(fi, "featureId")
 	if apierr != nil {
-		bh.l.Info(apierr.Error())
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	tagId, apierr = bh.parsePosInt(ti, "tagId")
 	if apierr != nil {
-		bh.l.Info(apierr.Error())
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	if tagId == 0 && featureId == 0 {
 		apierr = serverr.NewInvalidRequestError("'feature_id' и 'tag_id' не установлены")
-		bh.l.Info(apierr.Error())
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	// call service method and return response
-	if blist, apierr := bh.service.GetBannersByFilter(featureId, tagId, limit, offset); apierr != nil {
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+	if blist, apierr := bh.service.GetBannersByFilter(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), featureId, tagId, limit, offset); apierr != nil {
+		apierr.Write(w, r)
 	} else {
 		w.WriteHeader(200)
 		w.Write([]byte(dto.JsonBody(blist)))
@@ -381,7 +588,7 @@ func (bh *BannerHandler) parsePosInt(tg string, pname string) (int64, *serverr.A
 
 	val, err := strconv.ParseInt(tg, 10, 64)
 	if err != nil || val < 0 {
-		apierror := serverr.NewInvalidRequestError("Некорректное значение '" + pname + "'")
+		apierror := serverr.NewInvalidRequestErrorf("Некорректное значение '%s'", pname)
 		return 0, apierror
 	}
 
@@ -396,6 +603,7 @@ func (bh *BannerHandler) parsePosInt(tg string, pname string) (int64, *serverr.A
 //		@Param			feature_id	query	integer	false	"Идентификатор фичи"
 //
 // @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
 //
 //	@Produce		json
 //	@Success		200	"Баннеры удалены"
@@ -403,12 +611,13 @@ func (bh *BannerHandler) parsePosInt(tg string, pname string) (int64, *serverr.A
 //	@Failure		401	"Пользователь не авторизован"
 //	@Failure		403	"Пользователь не имеет доступа"
 //	@Failure		404	"Фича или тэг не найдены"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
 //	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
 //	@Router			/banner [delete]
 func (bh *BannerHandler) handleDeleteByFeatureOrTag(w http.ResponseWriter, r *http.Request) {
 	accessErr := bh.adminOnlyAccess(r)
 	if accessErr != nil {
-		http.Error(w, accessErr.JsonBody(), accessErr.HttpStatus)
+		accessErr.Write(w, r)
 		return
 	}
 	var apierr *serverr.ApiError
@@ -420,27 +629,27 @@ func (bh *BannerHandler) handleDeleteByFeatureOrTag(w http.ResponseWriter, r *ht
 
 	featureId, apierr = bh.parsePosInt(fi, "featureId")
 	if apierr != nil {
-		bh.l.Info(apierr.Error())
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	tagId, apierr = bh.parsePosInt(ti, "tagId")
 	if apierr != nil {
-		bh.l.Info(apierr.Error())
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
 		return
 	}
 
 	if (featureId == 0 && tagId == 0) || (featureId != 0 && tagId != 0) {
 		apierr = serverr.NewInvalidRequestError("Укажите либо feature_id, либо tag_id в отдельности")
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		apierr.Write(w, r)
 		return
 	}
 
 	// call service method and return response
-	if apierr := bh.service.DeleteByFeatureOrTagId(featureId, tagId); apierr != nil {
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+	if apierr := bh.service.DeleteByFeatureOrTagId(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), featureId, tagId); apierr != nil {
+		apierr.Write(w, r)
 	} else {
 		w.WriteHeader(200)
 	}
@@ -452,18 +661,20 @@ func (bh *BannerHandler) handleDeleteByFeatureOrTag(w http.ResponseWriter, r *ht
 //	@Param			bannerId path integer true "Идентификатор баннера"
 //
 // @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
 //
 //	@Produce		json
 //	@Success		200	{array} dto.GetVersionsResponseDto "Массив версий баннера"
 //	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
 //	@Failure		401	"Пользователь не авторизован"
 //	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
 //	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
 //	@Router			/banner/{bannerId}/ver [get]
 func (bh *BannerHandler) handleGetVersions(w http.ResponseWriter, r *http.Request) {
 	accessErr := bh.adminOnlyAccess(r)
 	if accessErr != nil {
-		http.Error(w, accessErr.JsonBody(), accessErr.HttpStatus)
+		accessErr.Write(w, r)
 		return
 	}
 
@@ -474,22 +685,22 @@ func (bh *BannerHandler) handleGetVersions(w http.ResponseWriter, r *http.Reques
 	// check whether path param exists & has correct value
 	if bi, ok := qp[BannerIdPathVariable]; !ok {
 		apierr := serverr.NewInvalidRequestError("Отсутствует параметр 'bannerId'")
-		bh.l.Info(apierr)
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr)
+		apierr.Write(w, r)
 		return
 	} else {
 		bannerId, err = strconv.ParseInt(bi, 10, 64)
 		if err != nil {
 			apierr := serverr.NewInvalidRequestError("Неверный формат параметра 'bannerId'")
-			bh.l.Info(apierr)
-			http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+			bh.log(r).Info(apierr)
+			apierr.Write(w, r)
 			return
 		}
 	}
 
 	// call service method and return response
-	if bv, apierr := bh.service.GetVersions(bannerId); apierr != nil {
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+	if bv, apierr := bh.service.GetVersions(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), bannerId); apierr != nil {
+		apierr.Write(w, r)
 	} else {
 		resp := dto.NewBannerVersionsResponse(bv)
 
@@ -507,6 +718,7 @@ func (bh *BannerHandler) handleGetVersions(w http.ResponseWriter, r *http.Reques
 //	@Accept			json
 //
 // @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
 //
 //	@Produce		json
 //	@Success		200	"Баннеру успешно выставлена указанная версия"
@@ -514,12 +726,14 @@ func (bh *BannerHandler) handleGetVersions(w http.ResponseWriter, r *http.Reques
 //	@Failure		401	"Пользователь не авторизован"
 //	@Failure		403	"Пользователь не имеет доступа"
 //	@Failure		404	"Баннер или фича не найдены"
+//	@Failure		409	{object} dto.ErrorResponseDto "Пара feature_id-tag_id занята другим баннером"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
 //	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
 //	@Router			/banner/{bannerId}/ver/{versionId} [patch]
 func (bh *BannerHandler) handleSetVersion(w http.ResponseWriter, r *http.Request) {
 	accessErr := bh.adminOnlyAccess(r)
 	if accessErr != nil {
-		http.Error(w, accessErr.JsonBody(), accessErr.HttpStatus)
+		accessErr.Write(w, r)
 		return
 	}
 
@@ -530,37 +744,37 @@ func (bh *BannerHandler) handleSetVersion(w http.ResponseWriter, r *http.Request
 	// check whether path param exists & has correct value
 	if bi, ok := qp[BannerIdPathVariable]; !ok {
 		apierr := serverr.NewInvalidRequestError("Отсутствует параметр 'bannerId'")
-		bh.l.Info(apierr)
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr)
+		apierr.Write(w, r)
 		return
 	} else {
 		bannerId, err = strconv.ParseInt(bi, 10, 64)
 		if err != nil {
 			apierr := serverr.NewInvalidRequestError("Неверный формат параметра 'bannerId'")
-			bh.l.Info(apierr)
-			http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+			bh.log(r).Info(apierr)
+			apierr.Write(w, r)
 			return
 		}
 	}
 
 	if bi, ok := qp[VersionIdPathVariable]; !ok {
 		apierr := serverr.NewInvalidRequestError("Отсутствует параметр 'versionId'")
-		bh.l.Info(apierr)
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+		bh.log(r).Info(apierr)
+		apierr.Write(w, r)
 		return
 	} else {
 		versionId, err = strconv.ParseInt(bi, 10, 64)
 		if err != nil {
 			apierr := serverr.NewInvalidRequestError("Неверный формат параметра 'versionId'")
-			bh.l.Info(apierr)
-			http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+			bh.log(r).Info(apierr)
+			apierr.Write(w, r)
 			return
 		}
 	}
 
 	// call service method and return response
-	if apierr := bh.service.SetVersion(bannerId, versionId); apierr != nil {
-		http.Error(w, apierr.JsonBody(), apierr.HttpStatus)
+	if apierr := bh.service.SetVersion(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), bannerId, versionId); apierr != nil {
+		apierr.Write(w, r)
 	} else {
 		w.WriteHeader(200)
 	}
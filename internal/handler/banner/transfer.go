@@ -0,0 +1,276 @@
+package banner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/auth"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"net/http"
+	"strconv"
+)
+
+const (
+	VersionsParam = "versions"
+	DryRunParam   = "dry_run"
+	ModeParam     = "mode"
+
+	TargetEnvironmentParam = "to"
+
+	ndjsonContentType = "application/x-ndjson"
+
+	// bounds of import body, a bigger import is split into several requests
+	maxImportLineSize = 1 << 20
+	maxImportLines    = 10000
+)
+
+//	@Summary		Выгрузка баннеров в формате NDJSON
+//	@Description	Возвращает неудалённые баннеры по одному JSON-объекту в строке, в порядке id.
+//	@Description	Результат можно передать в /banner/import без изменений
+//	@Tags			banner
+//	@Param			feature_id	query	integer	false	"Выгрузить только баннеры фичи"
+//	@Param			versions	query	boolean	false	"Добавить историю версий"
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		application/x-ndjson
+//	@Success		200	{object} dto.BannerLineDto "Строка выгрузки"
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/export [get]
+func (bh *BannerHandler) handleBannerExport(w http.ResponseWriter, r *http.Request) {
+	accessErr := bh.adminOnlyAccess(r)
+	if accessErr != nil {
+		accessErr.Write(w, r)
+		return
+	}
+
+	featureId, apierr := bh.parsePosInt(r.URL.Query().Get(FeatureIdParam), FeatureIdParam)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	withVersions, apierr := bh.parseBool(r.URL.Query().Get(VersionsParam), VersionsParam)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	// status is sent with the first line, so an error before it is still reported properly
+	enc := json.NewEncoder(w)
+	exported := 0
+	apierr = bh.service.ExportBanners(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), featureId, withVersions, func(line dto.BannerLineDto) error {
+		if exported == 0 {
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+		}
+		exported++
+
+		return enc.Encode(line)
+	})
+
+	switch {
+	case apierr != nil && exported == 0:
+		apierr.Write(w, r)
+	case apierr != nil:
+		// the response can't be changed anymore, client gets a truncated stream
+		bh.log(r).Errorf("Banners export is interrupted after %d banners", exported)
+		panic(http.ErrAbortHandler)
+	case exported == 0:
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.WriteHeader(http.StatusOK)
+	default:
+		bh.log(r).Infof("%d banners are exported", exported)
+	}
+}
+
+//	@Summary		Загрузка баннеров в формате NDJSON
+//	@Description	Проверяет и создаёт баннеры построчно, ошибка в строке не прерывает загрузку.
+//	@Description	В режиме upsert баннер с тем же external_key перезаписывается новой версией.
+//	@Description	При dry_run ничего не сохраняется, возвращается результат проверки
+//	@Tags			banner
+//	@Accept			application/x-ndjson
+//	@Param			request	body	dto.BannerLineDto	true	"Баннеры, по одному в строке"
+//	@Param			mode	query	string	false	"create (по умолчанию) или upsert"
+//	@Param			dry_run	query	boolean	false	"Только проверить"
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		json
+//	@Success		200	{object} dto.ImportBannersResponseDto "Результат по каждой строке"
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/import [post]
+func (bh *BannerHandler) handleBannerImport(w http.ResponseWriter, r *http.Request) {
+	accessErr := bh.adminOnlyAccess(r)
+	if accessErr != nil {
+		accessErr.Write(w, r)
+		return
+	}
+
+	mode := r.URL.Query().Get(ModeParam)
+	if mode == "" {
+		mode = service.ImportCreate
+	}
+
+	if mode != service.ImportCreate && mode != service.ImportUpsert {
+		apierr := serverr.NewInvalidRequestError("Некорректное значение 'mode'")
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	dryRun, apierr := bh.parseBool(r.URL.Query().Get(DryRunParam), DryRunParam)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	lines, apierr := bh.readImportLines(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	resp := bh.service.ImportBanners(r.Context(), service.Tenant(r.Context()), service.Environment(r.Context()), lines, mode, dryRun)
+
+	// errors of the lines are in the language of Accept-Language
+	lang := serverr.Lang(r)
+	resp.Localize(lang)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(dto.JsonBody(resp)))
+}
+
+// readImportLines
+// Splits the body into lines and validates each of them, empty lines are skipped.
+// Malformed lines are returned with the error, so they are reported with the rest
+func (bh *BannerHandler) readImportLines(r *http.Request) ([]dto.ImportLine, *serverr.ApiError) {
+	sc := bufio.NewScanner(r.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+
+	var lines []dto.ImportLine
+	number := 0
+	for sc.Scan() {
+		number++
+
+		raw := bytes.TrimSpace(sc.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		if len(lines) == maxImportLines {
+			return nil, serverr.NewInvalidRequestErrorf("Больше %d баннеров в одном запросе", maxImportLines)
+		}
+
+		line := dto.ImportLine{Number: number}
+		if err := json.Unmarshal(raw, &line.Banner); err != nil {
+			line.Error = serverr.NewInvalidRequestErrorf("Некорректный JSON: %v", err)
+		} else if apierr := line.Banner.Validate(bh.valid); apierr != nil {
+			line.Error = apierr
+		}
+
+		lines = append(lines, line)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, serverr.NewInvalidRequestErrorf("Не удалось прочитать строку %d: %v", number+1, err)
+	}
+
+	if len(lines) == 0 {
+		return nil, serverr.NewInvalidRequestError("Нет баннеров для загрузки")
+	}
+
+	return lines, nil
+}
+
+func (bh *BannerHandler) parseBool(val string, pname string) (bool, *serverr.ApiError) {
+	if val == "" {
+		return false, nil
+	}
+
+	res, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, serverr.NewInvalidRequestErrorf("Некорректное значение '%s'", pname)
+	}
+
+	return res, nil
+}
+
+//	@Summary		Перенос баннера в другое окружение
+//	@Description	Копирует текущую версию баннера окружения запроса в окружение to. Баннер с тем же external_key
+//	@Description	(или, если ключа нет, с теми же парами feature_id-tag_id) получает новую версию,
+//	@Description	если такого баннера нет - он создаётся
+//	@Tags			banner
+//	@Param			bannerId	path	integer	true	"Идентификатор баннера"
+//	@Param			to			query	string	true	"Целевое окружение, например production"
+//
+// @Param X-Access-Token header string true "Токен админа"
+// @Param X-Environment header string false "Окружение, по умолчанию production"
+//
+//	@Produce		json
+//	@Success		200	{object} dto.PromoteBannerResponseDto "Баннер перенесён"
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		404	"Баннер не найден"
+//	@Failure		409	{object} dto.ErrorResponseDto "Пара feature_id-tag_id занята другим баннером"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/banner/{bannerId}/promote [post]
+func (bh *BannerHandler) handleBannerPromote(w http.ResponseWriter, r *http.Request) {
+	accessErr := bh.adminOnlyAccess(r)
+	if accessErr != nil {
+		accessErr.Write(w, r)
+		return
+	}
+
+	bannerId, apierr := bh.bannerIdParam(r)
+	if apierr != nil {
+		bh.log(r).Info(apierr)
+		apierr.Write(w, r)
+		return
+	}
+
+	to := r.URL.Query().Get(TargetEnvironmentParam)
+	if to == "" {
+		apierr := serverr.NewInvalidRequestError("Отсутствует параметр 'to'")
+		bh.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	// token bound to an environment can't change banners of another one, the banner is taken
+	// from the environment of the request and is written to the target one
+	env := service.Environment(r.Context())
+	if bound := auth.TokenEnvironment(r.Header.Get("X-Access-Token")); bound != "" && (bound != to || bound != env) {
+		bh.log(r).Info(serverr.ForbiddenAccessError.Error())
+		serverr.ForbiddenAccessError.Write(w, r)
+		return
+	}
+
+	if resp, apierr := bh.service.PromoteBanner(r.Context(), service.Tenant(r.Context()), env, bannerId, to); apierr != nil {
+		apierr.Write(w, r)
+	} else {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(dto.JsonBody(resp)))
+	}
+}
@@ -0,0 +1,251 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+const (
+	LimitParam     = "limit"
+	OffsetParam    = "offset"
+	IdPathVariable = "id"
+)
+
+type CatalogHandler struct {
+	valid   *validator.Validate
+	l       *zap.SugaredLogger
+	service *service.CatalogService
+}
+
+func NewHandler(service *service.CatalogService, l *zap.SugaredLogger) *CatalogHandler {
+	return &CatalogHandler{
+		valid:   dto.NewValidator(),
+		l:       l,
+		service: service,
+	}
+}
+
+// RegisterRoutes
+// Features and tags are managed by admins only, the router must validate tokens
+func (ch *CatalogHandler) RegisterRoutes(router *mux.Router) {
+	admin := func(h http.HandlerFunc) http.Handler {
+		return service.AdminOnlyMiddleware(h)
+	}
+
+	router.Handle("/feature", admin(ch.handleFeatureList)).Methods("GET")
+	router.Handle("/feature", admin(ch.handleFeatureCreation)).Methods("POST")
+	router.Handle("/feature/{id}", admin(ch.handleFeatureDeletion)).Methods("DELETE")
+
+	router.Handle("/tag", admin(ch.handleTagList)).Methods("GET")
+	router.Handle("/tag", admin(ch.handleTagCreation)).Methods("POST")
+	router.Handle("/tag/{id}", admin(ch.handleTagDeletion)).Methods("DELETE")
+}
+
+// -------- Helper functions --------
+func (ch *CatalogHandler) log(r *http.Request) *zap.SugaredLogger {
+	return logging.For(r.Context(), ch.l)
+}
+
+func parsePosInt(val string, pname string) (int64, *serverr.ApiError) {
+	if val == "" {
+		return 0, nil
+	}
+
+	res, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || res < 0 {
+		return 0, serverr.NewInvalidRequestErrorf("Некорректное значение '%s'", pname)
+	}
+
+	return res, nil
+}
+
+func (ch *CatalogHandler) list(w http.ResponseWriter, r *http.Request, get func(ctx context.Context, tenant string, limit int64, offset int64) ([]models.CatalogItem, *serverr.ApiError)) {
+	limit, apierr := parsePosInt(r.URL.Query().Get(LimitParam), LimitParam)
+	if apierr != nil {
+		ch.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	offset, apierr := parsePosInt(r.URL.Query().Get(OffsetParam), OffsetParam)
+	if apierr != nil {
+		ch.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	if items, apierr := get(r.Context(), service.Tenant(r.Context()), limit, offset); apierr != nil {
+		apierr.Write(w, r)
+	} else {
+		w.Write([]byte(dto.JsonBody(items)))
+	}
+}
+
+func (ch *CatalogHandler) create(w http.ResponseWriter, r *http.Request, create func(ctx context.Context, tenant string, name string) (int64, *serverr.ApiError)) {
+	var rb dto.CreateCatalogItemDto
+	if err := json.NewDecoder(r.Body).Decode(&rb); err != nil {
+		apierr := serverr.InvalidRequestError
+		ch.log(r).Info(err)
+		apierr.Write(w, r)
+		return
+	}
+
+	if apierr := rb.Validate(ch.valid); apierr != nil {
+		ch.log(r).Info(apierr.Error())
+		apierr.Write(w, r)
+		return
+	}
+
+	if id, apierr := create(r.Context(), service.Tenant(r.Context()), rb.Name); apierr != nil {
+		apierr.Write(w, r)
+	} else {
+		w.WriteHeader(201)
+		w.Write([]byte(dto.JsonBody(dto.NewCreateCatalogItemResponse(id))))
+	}
+}
+
+func (ch *CatalogHandler) delete(w http.ResponseWriter, r *http.Request, del func(ctx context.Context, tenant string, id int64) *serverr.ApiError) {
+	id, err := strconv.ParseInt(mux.Vars(r)[IdPathVariable], 10, 64)
+	if err != nil {
+		apierr := serverr.NewInvalidRequestError("Неверный формат параметра 'id'")
+		ch.log(r).Info(apierr)
+		apierr.Write(w, r)
+		return
+	}
+
+	if apierr := del(r.Context(), service.Tenant(r.Context()), id); apierr != nil {
+		apierr.Write(w, r)
+	} else {
+		w.WriteHeader(204)
+	}
+}
+
+// -------- Handler functions --------
+
+//	@Summary		Получение списка фич
+//	@Tags			feature
+//	@Param			limit	query	integer	false	"Лимит"
+//	@Param			offset	query	integer	false	"Оффсет"
+//
+// @Param X-Access-Token header string true "Токен админа"
+//
+//	@Produce		json
+//	@Success		200	{array}	models.CatalogItem
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/feature [get]
+func (ch *CatalogHandler) handleFeatureList(w http.ResponseWriter, r *http.Request) {
+	ch.list(w, r, ch.service.GetFeatures)
+}
+
+//	@Summary		Создание фичи
+//	@Tags			feature
+//	@Accept			json
+//	@Param			request	body dto.CreateCatalogItemDto true "Название фичи"
+//
+// @Param X-Access-Token header string true "Токен админа"
+//
+//	@Produce		json
+//	@Success		201	{object} dto.CreateCatalogItemResponseDto
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/feature [post]
+func (ch *CatalogHandler) handleFeatureCreation(w http.ResponseWriter, r *http.Request) {
+	ch.create(w, r, ch.service.CreateFeature)
+}
+
+//	@Summary		Удаление фичи
+//	@Description	Удаляет фичу, если её не использует ни один баннер
+//	@Tags			feature
+//	@Param			id	path	integer	true	"Идентификатор фичи"
+//
+// @Param X-Access-Token header string true "Токен админа"
+//
+//	@Produce		json
+//	@Success		204	"Фича удалена"
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		404	"Фича не найдена"
+//	@Failure		409	{object} dto.ErrorResponseDto "Фича используется баннерами"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/feature/{id} [delete]
+func (ch *CatalogHandler) handleFeatureDeletion(w http.ResponseWriter, r *http.Request) {
+	ch.delete(w, r, ch.service.DeleteFeature)
+}
+
+//	@Summary		Получение списка тегов
+//	@Tags			tag
+//	@Param			limit	query	integer	false	"Лимит"
+//	@Param			offset	query	integer	false	"Оффсет"
+//
+// @Param X-Access-Token header string true "Токен админа"
+//
+//	@Produce		json
+//	@Success		200	{array}	models.CatalogItem
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/tag [get]
+func (ch *CatalogHandler) handleTagList(w http.ResponseWriter, r *http.Request) {
+	ch.list(w, r, ch.service.GetTags)
+}
+
+//	@Summary		Создание тега
+//	@Tags			tag
+//	@Accept			json
+//	@Param			request	body dto.CreateCatalogItemDto true "Название тега"
+//
+// @Param X-Access-Token header string true "Токен админа"
+//
+//	@Produce		json
+//	@Success		201	{object} dto.CreateCatalogItemResponseDto
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/tag [post]
+func (ch *CatalogHandler) handleTagCreation(w http.ResponseWriter, r *http.Request) {
+	ch.create(w, r, ch.service.CreateTag)
+}
+
+//	@Summary		Удаление тега
+//	@Description	Удаляет тег, если его не использует ни один баннер
+//	@Tags			tag
+//	@Param			id	path	integer	true	"Идентификатор тега"
+//
+// @Param X-Access-Token header string true "Токен админа"
+//
+//	@Produce		json
+//	@Success		204	"Тег удалён"
+//	@Failure		400	{object} dto.ErrorResponseDto "Некорректные данные"
+//	@Failure		401	"Пользователь не авторизован"
+//	@Failure		403	"Пользователь не имеет доступа"
+//	@Failure		404	"Тег не найден"
+//	@Failure		409	{object} dto.ErrorResponseDto "Тег используется баннерами"
+//	@Failure		429	{object} dto.ErrorResponseDto "Слишком много запросов"
+//	@Failure		500	{object} dto.ErrorResponseDto "Внутренняя ошибка сервера"
+//	@Router			/tag/{id} [delete]
+func (ch *CatalogHandler) handleTagDeletion(w http.ResponseWriter, r *http.Request) {
+	ch.delete(w, r, ch.service.DeleteTag)
+}
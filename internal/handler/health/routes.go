@@ -0,0 +1,62 @@
+package health
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"net/http"
+)
+
+type HealthHandler struct {
+	service *service.HealthService
+}
+
+func NewHandler(service *service.HealthService) *HealthHandler {
+	return &HealthHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes
+// Health endpoints don't require a token, they must be registered
+// outside of the router with token validation middleware
+func (hh *HealthHandler) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", hh.handleLiveness).Methods("GET")
+	router.HandleFunc("/readyz", hh.handleReadiness).Methods("GET")
+}
+
+// @Summary		Проверка жизнеспособности сервиса
+// @Description	Возвращает 200, пока процесс и фоновые задачи работают
+// @Tags			health
+// @Produce		json
+// @Success		200	{object} service.HealthReport "Сервис работает"
+// @Failure		503	{object} service.HealthReport "Сервис остановлен"
+// @Router			/healthz [get]
+func (hh *HealthHandler) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, hh.service.Liveness())
+}
+
+// @Summary		Проверка готовности сервиса
+// @Description	Проверяет postgres, версию схемы БД, redis и состояние фоновых задач.
+// @Description	Каждая проверка возвращает ok или fail, текст ошибки пишется только в лог.
+// @Description	Недоступность redis не делает сервис неготовым (статус degraded)
+// @Tags			health
+// @Produce		json
+// @Success		200	{object} service.HealthReport "Сервис готов принимать запросы"
+// @Failure		503	{object} service.HealthReport "Сервис не готов"
+// @Router			/readyz [get]
+func (hh *HealthHandler) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, hh.service.Readiness(r.Context()))
+}
+
+func writeReport(w http.ResponseWriter, report service.HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if report.Status == service.StatusUnavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.Write([]byte(dto.JsonBody(report)))
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	FormatJson    = "json"
+	FormatConsole = "console"
+)
+
+type Config struct {
+	// Level is one of debug, info, warn, error, "info" if not set
+	Level string `toml:"level"`
+	// Format is json or console, "json" if not set
+	Format string `toml:"format"`
+}
+
+// New
+// Builds the production logger of the application, the same logger
+// is passed to every component
+func New(lc Config) (*zap.Logger, error) {
+	if lc.Level == "" {
+		lc.Level = "info"
+	}
+	if lc.Format == "" {
+		lc.Format = FormatJson
+	}
+
+	level, err := zap.ParseAtomicLevel(lc.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	zc := zap.NewProductionConfig()
+	zc.Level = level
+	zc.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	switch lc.Format {
+	case FormatJson:
+	case FormatConsole:
+		zc.Encoding = FormatConsole
+		zc.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	default:
+		return nil, fmt.Errorf("unknown log format %q", lc.Format)
+	}
+
+	return zc.Build()
+}
+
+// For
+// Returns the logger annotated with the request id of ctx, if there is one
+func For(ctx context.Context, l *zap.SugaredLogger) *zap.SugaredLogger {
+	if id := RequestId(ctx); id != "" {
+		return l.With("request_id", id)
+	}
+
+	return l
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const RequestIdHeader = "X-Request-Id"
+
+// maxRequestIdLength limits ids propagated from clients
+const maxRequestIdLength = 128
+
+type requestIdKey struct{}
+
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId
+// Returns the id of the request ctx belongs to, empty string if there is none
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// RequestIdMiddleware
+// Propagates X-Request-Id of the client or generates a new one,
+// the id is put into the request context and the response header
+func RequestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIdHeader)
+		if !validRequestId(id) {
+			id = newRequestId()
+		}
+
+		w.Header().Set(RequestIdHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(WithRequestId(r.Context(), id)))
+	})
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// validRequestId
+// Accepts ids of a reasonable length made of printable characters only,
+// so a client can't break log lines with the id
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
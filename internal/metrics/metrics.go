@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "banner_service"
+
+// cache lookup results
+const (
+	CacheHit      = "hit"
+	CacheMiss     = "miss"
+	CacheNegative = "negative"
+	CacheError    = "error"
+)
+
+// background job and rollback outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+var (
+	HttpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status",
+	}, []string{"route", "method", "status"})
+
+	HttpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of banner cache lookups by result: hit, miss, negative or error",
+	}, []string{"result"})
+
+	PurgeRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "purge_runs_total",
+		Help:      "Number of runs of the job deleting banners marked as to_delete by outcome",
+	}, []string{"outcome"})
+
+	PurgeLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "purge_last_success_timestamp_seconds",
+		Help:      "Time of the last successful run of the job deleting banners",
+	})
+
+	VersionRollbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "version_rollbacks_total",
+		Help:      "Number of banner version rollbacks by outcome",
+	}, []string{"outcome"})
+
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Number of requests rejected by rate limiter by route class: user or admin",
+	}, []string{"class"})
+)
+
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+
+	return OutcomeSuccess
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector
+// Exposes pgx pool statistics, values are read on every scrape
+type PoolCollector struct {
+	p *pgxpool.Pool
+
+	acquired      *prometheus.Desc
+	idle          *prometheus.Desc
+	total         *prometheus.Desc
+	max           *prometheus.Desc
+	acquires      *prometheus.Desc
+	emptyAcquires *prometheus.Desc
+	waitDuration  *prometheus.Desc
+}
+
+func NewPoolCollector(p *pgxpool.Pool) *PoolCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pgx_pool", name), help, nil, nil)
+	}
+
+	return &PoolCollector{
+		p:             p,
+		acquired:      desc("acquired_connections", "Number of connections currently in use"),
+		idle:          desc("idle_connections", "Number of idle connections"),
+		total:         desc("total_connections", "Number of open connections"),
+		max:           desc("max_connections", "Maximum size of the pool"),
+		acquires:      desc("acquires_total", "Number of successful acquires from the pool"),
+		emptyAcquires: desc("empty_acquires_total", "Number of acquires that waited for a connection"),
+		waitDuration:  desc("acquire_wait_seconds_total", "Time spent waiting for a connection"),
+	}
+}
+
+func (pc *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pc.acquired
+	ch <- pc.idle
+	ch <- pc.total
+	ch <- pc.max
+	ch <- pc.acquires
+	ch <- pc.emptyAcquires
+	ch <- pc.waitDuration
+}
+
+func (pc *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := pc.p.Stat()
+
+	ch <- prometheus.MustNewConstMetric(pc.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(pc.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(pc.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(pc.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(pc.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pc.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pc.waitDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
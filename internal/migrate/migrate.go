@@ -0,0 +1,392 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"io/fs"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lockId is the key of postgres advisory lock held while migrating,
+// replicas started at the same time apply migrations one after another
+const lockId int64 = 0x62616e6e6572 // "banner"
+
+const createTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+		version    BIGINT PRIMARY KEY,
+		name       TEXT      NOT NULL,
+		checksum   TEXT      NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT now()
+	)
+`
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Config struct {
+	// RunAtStartup applies pending migrations before the server starts
+	RunAtStartup bool `toml:"run_at_startup"`
+}
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// Checksum of the up script, verified for applied migrations
+	Checksum string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type applied struct {
+	name     string
+	checksum string
+	at       time.Time
+}
+
+type Migrator struct {
+	p          *pgxpool.Pool
+	migrations []Migration
+	l          *zap.SugaredLogger
+}
+
+func New(p *pgxpool.Pool, fsys fs.FS, l *zap.SugaredLogger) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		p:          p,
+		migrations: migrations,
+		l:          l,
+	}, nil
+}
+
+// Latest
+// Returns the version of the last migration in fsys, zero if there are none
+func Latest(fsys fs.FS) (int64, error) {
+	migrations, err := Load(fsys)
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// Load
+// Reads migrations from the root of fsys sorted by version,
+// every migration must have both up and down scripts
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+
+		script, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(script)
+			sum := sha256.Sum256(script)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down scripts", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up
+// Applies pending migrations, each one in its own transaction.
+// Returns the number of applied migrations
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+
+	err := m.locked(ctx, func(conn *pgxpool.Conn, done map[int64]applied) error {
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+
+			m.l.Infof("migrate: applying %d_%s", mig.Version, mig.Name)
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Up); err != nil {
+					return err
+				}
+
+				_, err := tx.Exec(
+					ctx,
+					"INSERT INTO schema_migrations(version, name, checksum) VALUES ($1, $2, $3)",
+					mig.Version,
+					mig.Name,
+					mig.Checksum,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Down
+// Reverts the last steps applied migrations, steps must be positive.
+// Returns the number of reverted migrations
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, fmt.Errorf("number of steps must be positive, got %d", steps)
+	}
+
+	return m.down(ctx, steps)
+}
+
+// DownAll
+// Reverts every applied migration, returns the number of reverted migrations
+func (m *Migrator) DownAll(ctx context.Context) (int, error) {
+	return m.down(ctx, len(m.migrations))
+}
+
+func (m *Migrator) down(ctx context.Context, steps int) (int, error) {
+	count := 0
+
+	err := m.locked(ctx, func(conn *pgxpool.Conn, done map[int64]applied) error {
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+
+			m.l.Infof("migrate: reverting %d_%s", mig.Version, mig.Name)
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Down); err != nil {
+					return err
+				}
+
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			count++
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Baseline
+// Records migrations up to the version as applied without running them, so that
+// a database created before migrations were introduced can be adopted.
+// Returns the number of recorded migrations
+func (m *Migrator) Baseline(ctx context.Context, version int64) (int, error) {
+	if !slices.ContainsFunc(m.migrations, func(mig Migration) bool { return mig.Version == version }) {
+		return 0, fmt.Errorf("unknown migration version %d", version)
+	}
+
+	count := 0
+
+	err := m.locked(ctx, func(conn *pgxpool.Conn, done map[int64]applied) error {
+		return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			for _, mig := range m.migrations {
+				if mig.Version > version {
+					break
+				}
+
+				if _, ok := done[mig.Version]; ok {
+					continue
+				}
+
+				m.l.Infof("migrate: recording %d_%s as applied", mig.Version, mig.Name)
+				_, err := tx.Exec(
+					ctx,
+					"INSERT INTO schema_migrations(version, name, checksum) VALUES ($1, $2, $3)",
+					mig.Version,
+					mig.Name,
+					mig.Checksum,
+				)
+				if err != nil {
+					return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+				}
+
+				count++
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Status
+// Returns every known migration and whether it's applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.locked(ctx, func(conn *pgxpool.Conn, done map[int64]applied) error {
+		for _, mig := range m.migrations {
+			a, ok := done[mig.Version]
+			statuses = append(statuses, Status{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				Applied:   ok,
+				AppliedAt: a.at,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// locked
+// Runs f holding the advisory lock on a single connection, applied
+// migrations are read and verified against the known ones beforehand
+func (m *Migrator) locked(ctx context.Context, f func(conn *pgxpool.Conn, done map[int64]applied) error) error {
+	conn, err := m.p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", lockId); err != nil {
+		return fmt.Errorf("unable to take migration lock: %w", err)
+	}
+	defer func() {
+		// the lock is released even if ctx is cancelled
+		if _, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockId); err != nil {
+			m.l.Errorf("migrate: unable to release lock: %v", err)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createTable); err != nil {
+		return err
+	}
+
+	done, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	if err := m.verify(done); err != nil {
+		return err
+	}
+
+	return f(conn, done)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *pgxpool.Conn) (map[int64]applied, error) {
+	rows, err := conn.Query(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[int64]applied)
+	for rows.Next() {
+		var version int64
+		var a applied
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.at); err != nil {
+			return nil, err
+		}
+		done[version] = a
+	}
+
+	return done, rows.Err()
+}
+
+// verify
+// Fails if an applied migration was changed or is unknown to this build,
+// e.g. the database was migrated by a newer version of the service
+func (m *Migrator) verify(done map[int64]applied) error {
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+
+	var errs []error
+	for version, a := range done {
+		mig, ok := known[version]
+		if !ok {
+			errs = append(errs, fmt.Errorf("applied migration %d_%s is unknown", version, a.name))
+			continue
+		}
+
+		if mig.Checksum != a.checksum {
+			errs = append(errs, fmt.Errorf("checksum mismatch of applied migration %d_%s", version, mig.Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -6,14 +6,22 @@ import (
 )
 
 type BannerModel struct {
-	Id        int64
-	TagId     int64
-	FeatureId int64
-	Content   json.RawMessage
-	IsActive  bool
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	ToDelete  bool
+	Id           int64
+	TagId        int64
+	FeatureId    int64
+	Content      json.RawMessage
+	Locales      Locales
+	Locale       string // locale of Content if it's a variant, empty for the default content
+	Rule         string // targeting rule, see targeting.Parse
+	IsActive     bool
+	Priority     int
+	IsDefault    bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ToDelete     bool
+	LastRevision int64
+	Environment  string
+	Tenant       string
 }
 
 type BannerTagsModel struct {
@@ -21,11 +29,18 @@ type BannerTagsModel struct {
 	TagIds       []int64
 	FeatureId    int64
 	Content      json.RawMessage
+	Locales      Locales
+	Rule         string
 	IsActive     bool
+	Priority     int  // banner of the highest priority is served to a user with several tags
+	IsDefault    bool // default banner is served for the feature when no banner matches the tags
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	LastRevision int64
 	ToDelete     bool
+	ExternalKey  string
+	Environment  string
+	Tenant       string
 }
 
 // @schema BannerVersion
@@ -35,5 +50,41 @@ type BannerVersion struct {
 	FeatureId int64           `json:"feature_id"`
 	Tags      string          `json:"tags"`
 	Content   json.RawMessage `json:"content"`
+	Locales   Locales         `json:"locales,omitempty"`
+	Rule      string          `json:"rule,omitempty"`
 	CreatedAt time.Time       `json:"created_at"`
 }
+
+// Locales
+// Content variants of the banner by locale, e.g. "en" or "pt-br". Content of the banner
+// itself is the default variant, it's used when none of the requested locales is present
+type Locales map[string]json.RawMessage
+
+// outcomes of banners considered for the user, see ResolveCandidate
+const (
+	OutcomeChosen        = "chosen"         // the banner is served
+	OutcomeNoBanner      = "no_banner"      // the tag has no banner of the feature
+	OutcomeInactive      = "inactive"       // the banner is turned off
+	OutcomeRuleMismatch  = "rule_mismatch"  // the rule doesn't match attributes of the user
+	OutcomeInvalidRule   = "invalid_rule"   // the rule can't be parsed
+	OutcomeOutranked     = "outranked"      // the chosen banner has higher priority or, of equal ones, lower id
+	OutcomeDefaultUnused = "default_unused" // the default banner isn't needed, a banner of the tags is served
+)
+
+// @schema ResolveCandidate
+// Banner considered for the user and its outcome, the tag is the one it's found by
+type ResolveCandidate struct {
+	BannerId  int64  `json:"banner_id,omitempty"`
+	TagId     int64  `json:"tag_id,omitempty"`
+	Priority  int    `json:"priority"`
+	IsDefault bool   `json:"is_default,omitempty"`
+	Rule      string `json:"rule,omitempty"`
+	Outcome   string `json:"outcome"`
+}
+
+// @schema CatalogItem
+// Feature or tag, banners are bound to them by id
+type CatalogItem struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
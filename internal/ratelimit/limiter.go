@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// route classes, each has its own limit
+const (
+	ClassUser  = "user"
+	ClassAdmin = "admin"
+)
+
+// sweepInterval is how often idle buckets are removed from memory
+const sweepInterval = time.Minute
+
+type Config struct {
+	// Backend is memory (per replica) or redis (shared by replicas), "memory" if not set
+	Backend string `toml:"backend"`
+	User    Limit  `toml:"user"`
+	Admin   Limit  `toml:"admin"`
+	// UserRoutes are mux path templates limited as user routes, other routes are admin ones
+	UserRoutes []string `toml:"user_routes"`
+}
+
+// Limit
+// Token bucket parameters: Rate tokens per second are added up to Burst tokens,
+// a request takes one token. Zero Rate disables the limit
+type Limit struct {
+	Rate  float64 `toml:"rate"`
+	Burst int     `toml:"burst"`
+}
+
+func (lim Limit) Enabled() bool {
+	return lim.Rate > 0
+}
+
+func (lim Limit) burst() float64 {
+	return math.Max(float64(lim.Burst), 1)
+}
+
+// Limiter
+// Takes a token from the bucket of key, if the bucket is empty reports
+// how long the client should wait before retrying
+type Limiter interface {
+	Allow(ctx context.Context, key string, lim Limit) (bool, time.Duration, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is the time the bucket is refilled completely
+	full time.Time
+}
+
+// MemoryLimiter
+// Keeps buckets in memory, so the limit applies to a single replica
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (ml *MemoryLimiter) Allow(_ context.Context, key string, lim Limit) (bool, time.Duration, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := ml.now()
+	ml.sweep(now)
+
+	b, ok := ml.buckets[key]
+	if !ok {
+		b = &bucket{tokens: lim.burst(), last: now}
+		ml.buckets[key] = b
+	}
+
+	// refill for the time passed since the last request
+	b.tokens = math.Min(lim.burst(), b.tokens+now.Sub(b.last).Seconds()*lim.Rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((lim.burst() - b.tokens) / lim.Rate * float64(time.Second)))
+
+	if allowed {
+		return true, 0, nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / lim.Rate * float64(time.Second))
+
+	return false, wait, nil
+}
+
+// sweep removes buckets that are refilled completely, they are
+// the same as a new bucket created on the next request
+func (ml *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(ml.lastSweep) < sweepInterval {
+		return
+	}
+	ml.lastSweep = now
+
+	for key, b := range ml.buckets {
+		if !now.Before(b.full) {
+			delete(ml.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/metrics"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/auth"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"go.uber.org/zap"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+type RateLimiter struct {
+	rc      Config
+	limiter Limiter
+	l       *zap.SugaredLogger
+}
+
+func NewRateLimiter(rc Config, limiter Limiter, l *zap.SugaredLogger) *RateLimiter {
+	return &RateLimiter{
+		rc:      rc,
+		limiter: limiter,
+		l:       l,
+	}
+}
+
+// Middleware
+// Limits requests per client: by token if it is valid, otherwise by ip.
+// User and admin routes have separate limits and buckets. If the limiter
+// fails the request is let through
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := rl.routeClass(r)
+		lim := rl.rc.Admin
+		if class == ClassUser {
+			lim = rl.rc.User
+		}
+
+		if !lim.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, wait, err := rl.limiter.Allow(r.Context(), class+":"+clientKey(r), lim)
+		if err != nil {
+			logging.For(r.Context(), rl.l).Errorf("rate limit: %v", err)
+		} else if !allowed {
+			metrics.RateLimited.WithLabelValues(class).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+			serverr.TooManyRequestsError.Write(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rl *RateLimiter) routeClass(r *http.Request) string {
+	if cr := mux.CurrentRoute(r); cr != nil {
+		if tmpl, err := cr.GetPathTemplate(); err == nil && slices.Contains(rl.rc.UserRoutes, tmpl) {
+			return ClassUser
+		}
+	}
+
+	return ClassAdmin
+}
+
+// clientKey
+// Identifies the client by token subject, the token is hashed so it is
+// not stored as is. Requests without valid token are identified by ip,
+// otherwise rotating garbage tokens would get a fresh bucket every time
+func clientKey(r *http.Request) string {
+	token := r.Header.Get("X-Access-Token")
+	if _, err := auth.ValidateToken(token); token != "" && err == nil {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:16])
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return "ip:" + ip
+}
+
+// retryAfterSeconds rounds the wait up, Retry-After is in whole seconds
+func retryAfterSeconds(wait time.Duration) int {
+	return max(int(math.Ceil(wait.Seconds())), 1)
+}
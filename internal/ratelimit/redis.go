@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"go.uber.org/zap"
+	"time"
+)
+
+const keyPrefix = "rate_limit:"
+
+// RedisLimiter
+// Keeps buckets in redis, so the limit is shared by all replicas. While redis
+// is unavailable each replica limits requests in memory
+type RedisLimiter struct {
+	cr       *repo.CacheRepo
+	fallback *MemoryLimiter
+	l        *zap.SugaredLogger
+}
+
+func NewRedisLimiter(cr *repo.CacheRepo, l *zap.SugaredLogger) *RedisLimiter {
+	return &RedisLimiter{
+		cr:       cr,
+		fallback: NewMemoryLimiter(),
+		l:        l,
+	}
+}
+
+func (rl *RedisLimiter) Allow(ctx context.Context, key string, lim Limit) (bool, time.Duration, error) {
+	allowed, wait, err := rl.cr.TakeToken(ctx, keyPrefix+key, lim.Rate, int(lim.burst()))
+	if err == nil {
+		return allowed, wait, nil
+	}
+
+	if !errors.Is(err, repo.ErrCircuitOpen) {
+		logging.For(ctx, rl.l).Warnf("rate limit: redis is unavailable, limiting in memory: %v", err)
+	}
+
+	return rl.fallback.Allow(ctx, key, lim)
+}
@@ -0,0 +1,287 @@
+package repo
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"time"
+)
+
+// GetExistingCatalogIds
+// Returns which of the features and tags exist in the tenant, both are checked in one query
+func (br *BannerRepository) GetExistingCatalogIds(ctx context.Context, tenant string, featureIds []int64, tagIds []int64) (map[int64]bool, map[int64]bool, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetExistingCatalogIds")
+	defer span.End()
+
+	var features, tags []int64
+	err := br.p.QueryRow(
+		ctx,
+		`SELECT COALESCE((SELECT array_agg(id) FROM features WHERE tenant = $1 AND id = ANY($2)), '{}'),
+			    COALESCE((SELECT array_agg(id) FROM tags WHERE tenant = $1 AND id = ANY($3)), '{}')`,
+		tenant,
+		featureIds,
+		tagIds,
+	).Scan(&features, &tags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return idSet(features), idSet(tags), nil
+}
+
+// GetPairBanners
+// Returns banners mapped to any of the pairs, pairs are given by tenant, environment,
+// feature and tag. Every returned pair has the id of the banner it belongs to
+func (br *BannerRepository) GetPairBanners(ctx context.Context, tenant string, pairs []models.BannerModel) ([]models.BannerModel, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetPairBanners")
+	defer span.End()
+
+	envs := make([]string, len(pairs))
+	featureIds := make([]int64, len(pairs))
+	tagIds := make([]int64, len(pairs))
+	for i, pair := range pairs {
+		envs[i], featureIds[i], tagIds[i] = pair.Environment, pair.FeatureId, pair.TagId
+	}
+
+	rows, err := br.p.Query(
+		ctx,
+		`SELECT b.id,
+			    b.environment,
+			    b.feature_id,
+			    bt.tag_id
+			 FROM banners b
+			 JOIN banners_tags bt ON b.id = bt.banner_id
+			 JOIN unnest($2::text[], $3::bigint[], $4::bigint[]) AS p(environment, feature_id, tag_id)
+			   ON b.environment = p.environment AND b.feature_id = p.feature_id AND bt.tag_id = p.tag_id
+			 WHERE b.tenant = $1`,
+		tenant,
+		envs,
+		featureIds,
+		tagIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []models.BannerModel
+	for rows.Next() {
+		owner := models.BannerModel{Tenant: tenant}
+		if err := rows.Scan(&owner.Id, &owner.Environment, &owner.FeatureId, &owner.TagId); err != nil {
+			return nil, err
+		}
+		owners = append(owners, owner)
+	}
+
+	return owners, rows.Err()
+}
+
+// GetDefaultBanners
+// Returns default banners of any of the features, features are given by tenant, environment
+// and feature id
+func (br *BannerRepository) GetDefaultBanners(ctx context.Context, tenant string, features []models.BannerModel) ([]models.BannerModel, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetDefaultBanners")
+	defer span.End()
+
+	envs := make([]string, len(features))
+	featureIds := make([]int64, len(features))
+	for i, feature := range features {
+		envs[i], featureIds[i] = feature.Environment, feature.FeatureId
+	}
+
+	rows, err := br.p.Query(
+		ctx,
+		`SELECT DISTINCT b.id,
+			    b.environment,
+			    b.feature_id
+			 FROM banners b
+			 JOIN unnest($2::text[], $3::bigint[]) AS f(environment, feature_id)
+			   ON b.environment = f.environment AND b.feature_id = f.feature_id
+			 WHERE b.tenant = $1
+			   AND b.is_default`,
+		tenant,
+		envs,
+		featureIds,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defaults []models.BannerModel
+	for rows.Next() {
+		banner := models.BannerModel{Tenant: tenant}
+		if err := rows.Scan(&banner.Id, &banner.Environment, &banner.FeatureId); err != nil {
+			return nil, err
+		}
+		defaults = append(defaults, banner)
+	}
+
+	return defaults, rows.Err()
+}
+
+// GetBannersByIds
+// Returns banners of the tenant and environment with their tags, missing ids are skipped
+func (br *BannerRepository) GetBannersByIds(ctx context.Context, tenant string, env string, ids []int64) ([]models.BannerTagsModel, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetBannersByIds")
+	defer span.End()
+
+	rows, err := br.p.Query(
+		ctx,
+		`SELECT b.id,
+			    b.feature_id,
+			    b.content,
+			    b.locales,
+			    b.rule,
+			    b.is_active,
+			    b.priority,
+			    b.is_default,
+			    b.created_at,
+			    b.updated_at,
+			    b.last_revision,
+			    b.to_delete,
+			    COALESCE(b.external_key, ''),
+			    b.environment,
+			    COALESCE(array_agg(bt.tag_id ORDER BY bt.tag_id) FILTER (WHERE bt.tag_id IS NOT NULL), '{}')
+			 FROM banners b
+			 LEFT JOIN banners_tags bt ON b.id = bt.banner_id
+			 WHERE b.tenant = $1
+			   AND b.id = ANY($2)
+			   AND b.environment = $3
+			 GROUP BY b.id`,
+		tenant,
+		ids,
+		env,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var banners []models.BannerTagsModel
+	for rows.Next() {
+		banner := models.BannerTagsModel{Tenant: tenant}
+		err := rows.Scan(
+			&banner.Id,
+			&banner.FeatureId,
+			&banner.Content,
+			&banner.Locales,
+			&banner.Rule,
+			&banner.IsActive,
+			&banner.Priority,
+			&banner.IsDefault,
+			&banner.CreatedAt,
+			&banner.UpdatedAt,
+			&banner.LastRevision,
+			&banner.ToDelete,
+			&banner.ExternalKey,
+			&banner.Environment,
+			&banner.TagIds,
+		)
+		if err != nil {
+			return nil, err
+		}
+		banners = append(banners, banner)
+	}
+
+	return banners, rows.Err()
+}
+
+// GetUsedExternalKeys
+// Returns which of the external keys are used by banners of the tenant and environment
+func (br *BannerRepository) GetUsedExternalKeys(ctx context.Context, tenant string, env string, keys []string) (map[string]bool, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetUsedExternalKeys")
+	defer span.End()
+
+	rows, err := br.p.Query(
+		ctx,
+		"SELECT external_key FROM banners WHERE tenant = $1 AND environment = $2 AND external_key = ANY($3)",
+		tenant,
+		env,
+		keys,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]bool, len(used))
+	for _, key := range used {
+		set[key] = true
+	}
+
+	return set, nil
+}
+
+// CreateBanners
+// Creates the banners of the tenant in one transaction, either all of them are created or none.
+// None is created if the tenant would have more than maxBanners banners, 0 is unlimited
+func (br *BannerRepository) CreateBanners(ctx context.Context, tenant string, banners []*models.BannerTagsModel, maxBanners int64) ([]int64, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.CreateBanners")
+	defer span.End()
+
+	ids := make([]int64, len(banners))
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		if err := reserveBanners(ctx, tx, tenant, int64(len(banners)), maxBanners); err != nil {
+			return err
+		}
+
+		for i, banner := range banners {
+			id, err := br.insertBanner(ctx, tx, banner)
+			if err != nil {
+				return err
+			}
+			ids[i] = id
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, br.pairConflict(ctx, err, banners...)
+	}
+
+	br.log(ctx).Infof("%d banners are created", len(banners))
+
+	return ids, nil
+}
+
+// ChangeBanners
+// Stores the changed banners as their next versions in one transaction,
+// either all of them are changed or none
+func (br *BannerRepository) ChangeBanners(ctx context.Context, banners []*models.BannerTagsModel) error {
+	ctx, span := tracing.Start(ctx, "BannerRepository.ChangeBanners")
+	defer span.End()
+
+	now := time.Now()
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		for _, banner := range banners {
+			banner.UpdatedAt = now
+			if err := br.saveRevision(ctx, tx, banner.Id, banner); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return br.pairConflict(ctx, err, banners...)
+	}
+
+	br.log(ctx).Infof("%d banners are updated", len(banners))
+
+	return nil
+}
+
+func idSet(ids []int64) map[int64]bool {
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+
+	return set
+}
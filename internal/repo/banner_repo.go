@@ -8,7 +8,9 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
 	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
 	"github.com/mBayzigitov/dynamic-content-service/internal/util"
 	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
 	"go.uber.org/zap"
@@ -22,20 +24,25 @@ type BannerRepository struct {
 	l *zap.SugaredLogger
 }
 
-func NewBannerRepository(p *pgxpool.Pool) *BannerRepository {
-	logger, _ := zap.NewDevelopment()
-
+func NewBannerRepository(p *pgxpool.Pool, l *zap.SugaredLogger) *BannerRepository {
 	return &BannerRepository{
 		p: p,
-		l: logger.Sugar(),
+		l: l,
 	}
 }
 
-func (br *BannerRepository) DoesFeatureExist(featureID int64) (bool, error) {
-	query := "SELECT COUNT(*) FROM features WHERE id = $1"
+func (br *BannerRepository) log(ctx context.Context) *zap.SugaredLogger {
+	return logging.For(ctx, br.l)
+}
+
+func (br *BannerRepository) DoesFeatureExist(ctx context.Context, tenant string, featureID int64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.DoesFeatureExist")
+	defer span.End()
+
+	query := "SELECT COUNT(*) FROM features WHERE id = $1 AND tenant = $2"
 
 	var count int
-	err := br.p.QueryRow(context.Background(), query, featureID).Scan(&count)
+	err := br.p.QueryRow(ctx, query, featureID, tenant).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -44,10 +51,13 @@ func (br *BannerRepository) DoesFeatureExist(featureID int64) (bool, error) {
 	return count > 0, nil
 }
 
-func (br *BannerRepository) DoTagsExist(tagsIds []int64) (bool, error) {
+func (br *BannerRepository) DoTagsExist(ctx context.Context, tenant string, tagsIds []int64) (bool, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.DoTagsExist")
+	defer span.End()
+
 	// idea is to compare count of tags from db and the actual slice len
 	// if count(*) from tags where... = len(tagsIds) -> return true
-	query := "SELECT COUNT(*) FROM tags WHERE id IN ("
+	query := "SELECT COUNT(*) FROM tags WHERE tenant = $1 AND id IN ("
 	params := make([]string, len(tagsIds))
 	for i, id := range tagsIds {
 		params[i] = strconv.FormatInt(id, 10)
@@ -55,7 +65,7 @@ func (br *BannerRepository) DoTagsExist(tagsIds []int64) (bool, error) {
 	query += strings.Join(params, ",") + ")"
 
 	var count int
-	err := br.p.QueryRow(context.Background(), query).Scan(&count)
+	err := br.p.QueryRow(ctx, query, tenant).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -64,84 +74,115 @@ func (br *BannerRepository) DoTagsExist(tagsIds []int64) (bool, error) {
 	return count == len(tagsIds), nil
 }
 
-func (br *BannerRepository) DeleteMarkedBanners() error {
-	// start a transaction
-	tx, err := br.p.Begin(context.Background())
-	if err != nil {
-		br.l.Fatal(err)
-		return err
-	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if err != nil {
-			br.l.Fatal(err)
-			tx.Rollback(context.Background())
-		} else {
-			err = tx.Commit(context.Background())
-		}
-	}()
+func (br *BannerRepository) DeleteMarkedBanners(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "BannerRepository.DeleteMarkedBanners")
+	defer span.End()
 
-	// delete banners marked as to_delete
-	_, err = tx.Exec(context.Background(), `
-		DELETE FROM banners
-		WHERE to_delete = true
-	`)
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		// delete banners marked as to_delete
+		_, err := tx.Exec(ctx, `
+			DELETE FROM banners
+			WHERE to_delete = true
+		`)
+		return err
+	})
 	if err != nil {
-		br.l.Fatal(err)
+		br.log(ctx).Errorf("unable to delete banners marked as to_delete: %v", err)
 		return err
 	}
 
-	br.l.Info("Banners marked as to_delete have been cleaned up successfully")
+	br.log(ctx).Info("Banners marked as to_delete have been cleaned up successfully")
 
 	return nil
 }
 
-// CheckIfDuplicates
-// Method that checks if a bunch of key (banner_id-feature_id-tag_id) already
-// exists to satisfy the condition of unambigious definition
-func (br *BannerRepository) CheckIfDuplicates(featureId int64, tagsIds []int64) (bool, error) {
-	query := `
-		SELECT COUNT(*)
-		FROM banners b
-			 JOIN public.banners_tags bt on b.id = bt.banner_id
-		WHERE
-		     b.feature_id = $1
-		 and bt.tag_id IN (`
+// FindPairOwner
+// Returns the pair of the feature and any of the tags that already belongs to another banner
+// of the tenant and environment, nil if pairs are free. Pairs of the banner with exceptId
+// are not counted, so the banner being changed doesn't clash with itself
+func (br *BannerRepository) FindPairOwner(ctx context.Context, tenant string, env string, featureId int64, tagsIds []int64, exceptId int64) (*models.BannerModel, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.FindPairOwner")
+	defer span.End()
 
-	params := make([]string, len(tagsIds))
-	for i, id := range tagsIds {
-		params[i] = strconv.FormatInt(id, 10)
+	owner := models.BannerModel{Tenant: tenant, Environment: env, FeatureId: featureId}
+	err := br.p.QueryRow(
+		ctx,
+		`SELECT b.id, bt.tag_id
+			 FROM banners b
+			 JOIN banners_tags bt on b.id = bt.banner_id
+			 WHERE b.feature_id = $1
+			   AND b.id <> $2
+			   AND b.environment = $3
+			   AND b.tenant = $4
+			   AND bt.tag_id = ANY($5)
+			 ORDER BY bt.tag_id
+			 LIMIT 1`,
+		featureId,
+		exceptId,
+		env,
+		tenant,
+		tagsIds,
+	).Scan(&owner.Id, &owner.TagId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
-	query += strings.Join(params, ",") + ")"
 
-	var count int
+	return &owner, nil
+}
+
+// FindDefaultOwner
+// Returns id of the default banner of the feature in the tenant and environment, 0 if the feature
+// has none. The banner with exceptId is not counted, so the banner being changed doesn't clash with itself
+func (br *BannerRepository) FindDefaultOwner(ctx context.Context, tenant string, env string, featureId int64, exceptId int64) (int64, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.FindDefaultOwner")
+	defer span.End()
+
+	var id int64
 	err := br.p.QueryRow(
-		context.Background(),
-		query,
+		ctx,
+		`SELECT id
+			 FROM banners
+			 WHERE feature_id = $1
+			   AND id <> $2
+			   AND environment = $3
+			   AND tenant = $4
+			   AND is_default`,
 		featureId,
-	).Scan(&count)
-	if err != nil {
-		return false, err
+		exceptId,
+		env,
+		tenant,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
 	}
 
-	// check if at least one record exists
-	return count > 0, nil
+	return id, err
 }
 
-func (br *BannerRepository) GetBannerByTagAndFeature(tagId int64, featureId int64) (models.BannerModel, error) {
-	var banner models.BannerModel
+func (br *BannerRepository) GetBannerByTagAndFeature(ctx context.Context, tenant string, env string, tagId int64, featureId int64) (models.BannerModel, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetBannerByTagAndFeature")
+	defer span.End()
 
-	// query with JOIN to select banner based on tagId, featureId, is_active=true, and to_delete=false
+	banner := models.BannerModel{Tenant: tenant, Environment: env}
+
+	// query with JOIN to select banner based on tagId, featureId and to_delete=false
+	// inactive banners are selected too, whether to show them is decided by the caller
 	query := `
 		SELECT 
 			b.id,
 			b.content,
+			b.locales,
+			b.rule,
 			b.feature_id,
 			b.is_active,
+			b.priority,
+			b.is_default,
 			b.created_at,
-			b.updated_at
+			b.updated_at,
+			b.last_revision
 		FROM 
 			banners b
 		JOIN 
@@ -149,22 +190,30 @@ func (br *BannerRepository) GetBannerByTagAndFeature(tagId int64, featureId int6
 		WHERE 
 			bt.tag_id = $1
 			AND b.feature_id = $2 
-			AND b.is_active = true 
+			AND b.environment = $3
+			AND b.tenant = $4
 			AND b.to_delete = false
 	`
 
 	err := br.p.QueryRow(
-		context.Background(),
+		ctx,
 		query,
 		tagId,
 		featureId,
+		env,
+		tenant,
 	).Scan(
 		&banner.Id,
 		&banner.Content,
+		&banner.Locales,
+		&banner.Rule,
 		&banner.FeatureId,
 		&banner.IsActive,
+		&banner.Priority,
+		&banner.IsDefault,
 		&banner.CreatedAt,
 		&banner.UpdatedAt,
+		&banner.LastRevision,
 	)
 	if err != nil {
 		return models.BannerModel{}, err
@@ -173,31 +222,87 @@ func (br *BannerRepository) GetBannerByTagAndFeature(tagId int64, featureId int6
 	return banner, nil
 }
 
-func (br *BannerRepository) CreateBanner(banner *models.BannerTagsModel) (int64, error) {
-	// start a transaction
-	tx, err := br.p.Begin(context.Background())
+// GetDefaultBanner
+// Returns the default banner of the feature in the tenant and environment, pgx.ErrNoRows if the
+// feature has none. Inactive banners are selected too, whether to show them is decided by the caller
+func (br *BannerRepository) GetDefaultBanner(ctx context.Context, tenant string, env string, featureId int64) (models.BannerModel, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetDefaultBanner")
+	defer span.End()
+
+	banner := models.BannerModel{Tenant: tenant, Environment: env}
+	err := br.p.QueryRow(
+		ctx,
+		`SELECT id, content, locales, rule, feature_id, is_active, priority, is_default, created_at, updated_at, last_revision
+			 FROM banners
+			 WHERE feature_id = $1
+			   AND environment = $2
+			   AND tenant = $3
+			   AND is_default
+			   AND to_delete = false`,
+		featureId,
+		env,
+		tenant,
+	).Scan(
+		&banner.Id,
+		&banner.Content,
+		&banner.Locales,
+		&banner.Rule,
+		&banner.FeatureId,
+		&banner.IsActive,
+		&banner.Priority,
+		&banner.IsDefault,
+		&banner.CreatedAt,
+		&banner.UpdatedAt,
+		&banner.LastRevision,
+	)
 	if err != nil {
-		return 0, err
+		return models.BannerModel{}, err
 	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if err != nil {
-			tx.Rollback(context.Background())
-		} else {
-			err = tx.Commit(context.Background())
+
+	return banner, nil
+}
+
+// CreateBanner
+// Creates the banner if the tenant has less than maxBanners banners, 0 maxBanners is unlimited
+func (br *BannerRepository) CreateBanner(ctx context.Context, banner *models.BannerTagsModel, maxBanners int64) (int64, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.CreateBanner")
+	defer span.End()
+
+	var bannerID int64
+	err := br.inTx(ctx, func(tx pgx.Tx) (err error) {
+		if err := reserveBanners(ctx, tx, banner.Tenant, 1, maxBanners); err != nil {
+			return err
 		}
-	}()
 
+		bannerID, err = br.insertBanner(ctx, tx, banner)
+		return err
+	})
+	if err != nil {
+		return 0, br.pairConflict(ctx, err, banner)
+	}
+
+	return bannerID, nil
+}
+
+// insertBanner
+// Inserts the banner with its first version and tags
+func (br *BannerRepository) insertBanner(ctx context.Context, q querier, banner *models.BannerTagsModel) (int64, error) {
 	// insert into banners table
 	var bannerID int64
 	var createdAt time.Time
-	err = tx.QueryRow(
-		context.Background(),
-		"INSERT INTO banners(content, feature_id) VALUES ($1, $2) RETURNING id, created_at",
+	err := q.QueryRow(
+		ctx,
+		"INSERT INTO banners(content, feature_id, is_active, external_key, environment, tenant, locales, priority, is_default, rule) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10) RETURNING id, created_at",
 		banner.Content,
 		banner.FeatureId,
+		banner.IsActive,
+		banner.ExternalKey,
+		banner.Environment,
+		banner.Tenant,
+		locales(banner.Locales),
+		banner.Priority,
+		banner.IsDefault,
+		banner.Rule,
 	).Scan(&bannerID, &createdAt)
 	if err != nil {
 		return 0, err
@@ -206,95 +311,117 @@ func (br *BannerRepository) CreateBanner(banner *models.BannerTagsModel) (int64,
 	// insert into banner_versions table
 	tags, _ := json.Marshal(banner.TagIds)
 	fTags := strings.Trim(string(tags), "[]")
-	_, err = tx.Exec(
-		context.Background(),
-		"INSERT INTO banner_version(feature_id, banner_id, version, content, created_at, tags) VALUES ($1, $2, $3, $4, $5, $6)",
+	_, err = q.Exec(
+		ctx,
+		"INSERT INTO banner_version(feature_id, banner_id, version, content, created_at, tags, locales, rule) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
 		banner.FeatureId,
 		bannerID,
 		1, // Version 1
 		banner.Content,
 		createdAt,
 		fTags,
+		locales(banner.Locales),
+		banner.Rule,
 	)
 	if err != nil {
 		return 0, err
 	}
 
 	// map created banner with every tag id specified
-	for _, tagID := range banner.TagIds {
-		_, err = tx.Exec(
-			context.Background(),
-			"INSERT INTO banners_tags(banner_id, tag_id) VALUES ($1, $2)",
-			bannerID,
-			tagID,
+	return bannerID, br.insertBannerTags(ctx, q, bannerID, banner.TagIds)
+}
+
+func (br *BannerRepository) DeleteBanner(ctx context.Context, tenant string, env string, bannerId int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerRepository.DeleteBanner")
+	defer span.End()
+
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		// perform the update operation to set to_delete=true
+		result, err := tx.Exec(
+			ctx,
+			"UPDATE banners SET to_delete=true WHERE id = $1 AND tenant = $2 AND environment = $3 AND is_active = true",
+			bannerId,
+			tenant,
+			env,
 		)
 		if err != nil {
-			return 0, err
+			return err
 		}
-	}
 
-	return bannerID, nil
-}
+		// check if the update affected any rows
+		if result.RowsAffected() == 0 {
+			// no rows were affected, indicating that the banner with the given id doesn't exist
+			return serverr.BannerNotFoundError
+		}
 
-func (br *BannerRepository) DeleteBanner(bannerId int64) *serverr.ApiError {
-	// start a transaction
-	tx, err := br.p.Begin(context.Background())
+		return nil
+	})
 	if err != nil {
-		br.l.Fatal(err)
-		return serverr.StorageError
+		return br.toApiError(ctx, err)
 	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if err != nil {
-			tx.Rollback(context.Background())
-		} else {
-			err = tx.Commit(context.Background())
+
+	br.log(ctx).Infof("Banner [id=%d] has been marked as deleted successfully", bannerId)
+
+	return nil
+}
+
+// RestoreBanner
+// Unmarks the banner marked as to_delete, it's possible until marked banners are purged.
+// The restored banner counts against maxBanners of the tenant, 0 is unlimited
+func (br *BannerRepository) RestoreBanner(ctx context.Context, tenant string, env string, bannerId int64, maxBanners int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerRepository.RestoreBanner")
+	defer span.End()
+
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		if err := reserveBanners(ctx, tx, tenant, 1, maxBanners); err != nil {
+			return err
 		}
-	}()
 
-	// perform the update operation to set to_delete=true
-	result, err := tx.Exec(
-		context.Background(),
-		"UPDATE banners SET to_delete=true WHERE id = $1 AND is_active = true",
-		bannerId,
-	)
-	if err != nil {
-		br.l.Error(err)
-		return serverr.StorageError
-	}
+		result, err := tx.Exec(
+			ctx,
+			"UPDATE banners SET to_delete=false WHERE id = $1 AND tenant = $2 AND environment = $3 AND to_delete = true",
+			bannerId,
+			tenant,
+			env,
+		)
+		if err != nil {
+			return err
+		}
 
-	// check if the update affected any rows
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		// no rows were affected, indicating that the banner with the given id doesn't exist
-		return serverr.BannerNotFoundError
+		if result.RowsAffected() == 0 {
+			// either there is no such banner or it's not deleted
+			return serverr.BannerNotFoundError
+		}
+
+		return nil
+	})
+	if err != nil {
+		return br.toApiError(ctx, err)
 	}
 
-	br.l.Infof("Banner [id=%d] has been marked as deleted successfully", bannerId)
+	br.log(ctx).Infof("Banner [id=%d] has been restored successfully", bannerId)
 
 	return nil
 }
 
-func (br *BannerRepository) ChangeBannerByRequest(bannerId int64, chban dto.ChangeBannerDto) *serverr.ApiError {
+func (br *BannerRepository) ChangeBannerByRequest(ctx context.Context, tenant string, env string, bannerId int64, chban dto.ChangeBannerDto) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerRepository.ChangeBannerByRequest")
+	defer span.End()
+
 	// key idea is to get existing banner and use it as pattern for changes
 	// check if banner is present, if it is -> get banner pattern, change updated_at
-	bannerPattern, err := br.GetBannerById(bannerId)
-	if err != nil {
-		fmt.Println(err)
-		return err
+	bannerPattern, apierr := br.GetBannerById(ctx, tenant, env, bannerId)
+	if apierr != nil {
+		return apierr
 	}
 
 	bannerPattern.UpdatedAt = time.Now()
-	fmt.Println(*bannerPattern)
 
 	// if featureId NOT NULL -> check featureId, if exists -> change it in banner pattern
 	if chban.FeatureId != nil {
-		featExists, err := br.DoesFeatureExist(*chban.FeatureId)
+		featExists, err := br.DoesFeatureExist(ctx, tenant, *chban.FeatureId)
 		if err != nil {
-			br.l.Error(err.Error())
-			return serverr.StorageError
+			return br.toApiError(ctx, err)
 		}
 
 		if featExists {
@@ -306,10 +433,9 @@ func (br *BannerRepository) ChangeBannerByRequest(bannerId int64, chban dto.Chan
 
 	// if tagIds NOT NULL -> check whether tagIds exist, if exists -> change it in banner pattern
 	if len(chban.TagIds) != 0 {
-		tagsExist, err := br.DoTagsExist(chban.TagIds)
+		tagsExist, err := br.DoTagsExist(ctx, tenant, chban.TagIds)
 		if err != nil {
-			br.l.Error(err.Error())
-			return serverr.StorageError
+			return br.toApiError(ctx, err)
 		}
 
 		if tagsExist {
@@ -324,91 +450,154 @@ func (br *BannerRepository) ChangeBannerByRequest(bannerId int64, chban dto.Chan
 		bannerPattern.Content = *chban.Content
 	}
 
+	// if locales NOT NULL -> replace all variants, {} removes them
+	if chban.Locales != nil {
+		bannerPattern.Locales = chban.Locales
+	}
+
+	// if rule NOT NULL -> replace it, "" targets every user
+	if chban.Rule != nil {
+		bannerPattern.Rule = *chban.Rule
+	}
+
 	// if is_active NOT NULL -> assign value, else keep value
 	if chban.IsActive != nil {
 		bannerPattern.IsActive = *chban.IsActive
 	}
 
-	// start transaction, commit through defer
-	tx, txerr := br.p.Begin(context.Background())
-	if txerr != nil {
-		return serverr.StorageError
+	if chban.Priority != nil {
+		bannerPattern.Priority = *chban.Priority
 	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if err != nil {
-			tx.Rollback(context.Background())
-		} else {
-			txerr = tx.Commit(context.Background())
+
+	if chban.IsDefault != nil {
+		bannerPattern.IsDefault = *chban.IsDefault
+	}
+
+	owner, err := br.FindPairOwner(ctx, tenant, bannerPattern.Environment, bannerPattern.FeatureId, bannerPattern.TagIds, bannerId)
+	if err != nil {
+		return br.toApiError(ctx, err)
+	}
+
+	if owner != nil {
+		return serverr.NewPairConflictError(owner.FeatureId, owner.TagId, owner.Id)
+	}
+
+	if bannerPattern.IsDefault {
+		defaultId, err := br.FindDefaultOwner(ctx, tenant, bannerPattern.Environment, bannerPattern.FeatureId, bannerId)
+		if err != nil {
+			return br.toApiError(ctx, err)
 		}
-	}()
+
+		if defaultId != 0 {
+			return serverr.NewDefaultConflictError(bannerPattern.FeatureId, defaultId)
+		}
+	}
+
+	// new version, tags and the banner itself are changed in one transaction
+	err = br.inTx(ctx, func(tx pgx.Tx) error {
+		return br.saveRevision(ctx, tx, bannerId, bannerPattern)
+	})
+	if err != nil {
+		return br.toApiError(ctx, br.pairConflict(ctx, err, bannerPattern))
+	}
+
+	br.log(ctx).Infof("Banner [id=%d] is updated successfully", bannerId)
+
+	return nil
+}
+
+// saveRevision
+// Stores the changed banner as its next version, LastRevision of the banner is the one the change
+// is based on. The banner row is locked, a change based on an older revision is a conflict
+func (br *BannerRepository) saveRevision(ctx context.Context, q querier, bannerId int64, banner *models.BannerTagsModel) error {
+	// concurrent changes of the banner wait for each other here, the later one would
+	// otherwise overwrite the earlier with stale fields
+	var revision int64
+	err := q.QueryRow(ctx, "SELECT last_revision FROM banners WHERE id = $1 FOR UPDATE", bannerId).Scan(&revision)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// purged since it was read
+		return serverr.BannerNotFoundError
+	}
+	if err != nil {
+		return err
+	}
+
+	if revision != banner.LastRevision {
+		return serverr.NewConflictError("Баннер изменён другим запросом, повторите запрос")
+	}
 
 	// create new version, get last revision param
-	tags, _ := json.Marshal(bannerPattern.TagIds)
+	tags, _ := json.Marshal(banner.TagIds)
 	fTags := strings.Trim(string(tags), "[]")
-	_, txerr = tx.Exec(
-		context.Background(),
-		"INSERT INTO banner_version(feature_id, banner_id, version, content, created_at, tags) VALUES ($1, $2, $3, $4, $5, $6)",
-		bannerPattern.FeatureId,
+	_, err = q.Exec(
+		ctx,
+		"INSERT INTO banner_version(feature_id, banner_id, version, content, created_at, tags, locales, rule) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		banner.FeatureId,
 		bannerId,
-		bannerPattern.LastRevision+1,
-		bannerPattern.Content,
-		bannerPattern.UpdatedAt, // because version is created when main banner is updated
+		banner.LastRevision+1,
+		banner.Content,
+		banner.UpdatedAt, // because version is created when main banner is updated
 		fTags,
+		locales(banner.Locales),
+		banner.Rule,
 	)
-
-	// delete mapped tags, map new tags
-	err = br.RewriteBannerTags(bannerId, bannerPattern.TagIds)
 	if err != nil {
 		return err
 	}
 
-	// change the banner itself
-	bannerPattern.LastRevision = bannerPattern.LastRevision + 1
-	err = br.ChangeBanner(bannerId, bannerPattern)
-	if err != nil {
+	// delete mapped tags, map new tags
+	if err := br.rewriteBannerTags(ctx, q, bannerId, banner.TagIds); err != nil {
 		return err
 	}
 
-	br.l.Infof("Banner [id=%d] is updated successfully", bannerId)
-
-	return nil
+	// change the banner itself
+	banner.LastRevision = banner.LastRevision + 1
+	return br.updateBanner(ctx, q, bannerId, banner)
 }
 
-func (br *BannerRepository) GetBannerById(bannerId int64) (*models.BannerTagsModel, *serverr.ApiError) {
+func (br *BannerRepository) GetBannerById(ctx context.Context, tenant string, env string, bannerId int64) (*models.BannerTagsModel, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetBannerById")
+	defer span.End()
+
 	// query to get banner details from the banners table
 	row := br.p.QueryRow(
-		context.Background(),
-		"SELECT feature_id, content, is_active, created_at, updated_at, last_revision, to_delete FROM banners WHERE id = $1",
+		ctx,
+		"SELECT feature_id, content, locales, rule, is_active, priority, is_default, created_at, updated_at, last_revision, to_delete, COALESCE(external_key, ''), environment FROM banners WHERE id = $1 AND tenant = $2 AND environment = $3",
 		bannerId,
+		tenant,
+		env,
 	)
 
 	// Initialize variables to store banner details
-	var banner models.BannerTagsModel
+	banner := models.BannerTagsModel{Id: bannerId, Tenant: tenant}
 
 	// Scan the banner details into the struct
 	err := row.Scan(
 		&banner.FeatureId,
 		&banner.Content,
+		&banner.Locales,
+		&banner.Rule,
 		&banner.IsActive,
+		&banner.Priority,
+		&banner.IsDefault,
 		&banner.CreatedAt,
 		&banner.UpdatedAt,
 		&banner.LastRevision,
 		&banner.ToDelete,
+		&banner.ExternalKey,
+		&banner.Environment,
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, serverr.BannerNotFoundError
 		}
-		br.l.Error(err)
+		br.log(ctx).Error(err)
 		return nil, serverr.StorageError
 	}
 
 	// get tag IDs associated with the banner from the banners_tags table
 	rows, err := br.p.Query(
-		context.Background(),
+		ctx,
 		`SELECT tag_id 
 			 FROM banners_tags
 			 WHERE banner_id = $1`,
@@ -423,7 +612,7 @@ func (br *BannerRepository) GetBannerById(bannerId int64) (*models.BannerTagsMod
 	for rows.Next() {
 		var tagID int64
 		if err := rows.Scan(&tagID); err != nil {
-			br.l.Error(err)
+			br.log(ctx).Error(err)
 			return nil, serverr.StorageError
 		}
 		banner.TagIds = append(banner.TagIds, tagID)
@@ -431,88 +620,91 @@ func (br *BannerRepository) GetBannerById(bannerId int64) (*models.BannerTagsMod
 
 	// check for errors during row iteration
 	if err := rows.Err(); err != nil {
-		br.l.Error(err)
+		br.log(ctx).Error(err)
 		return nil, serverr.StorageError
 	}
 
 	return &banner, nil
 }
 
-func (br *BannerRepository) RewriteBannerTags(bannerId int64, tagIds []int64) *serverr.ApiError {
-	// start a transaction
-	tx, txerr := br.p.Begin(context.Background())
-	if txerr != nil {
-		br.l.Error(txerr)
-		return serverr.StorageError
-	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if txerr != nil {
-			tx.Rollback(context.Background())
-		} else {
-			txerr = tx.Commit(context.Background())
-		}
-	}()
+func (br *BannerRepository) RewriteBannerTags(ctx context.Context, bannerId int64, tagIds []int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerRepository.RewriteBannerTags")
+	defer span.End()
+
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		return br.rewriteBannerTags(ctx, tx, bannerId, tagIds)
+	})
+
+	return br.toApiError(ctx, err)
+}
 
+func (br *BannerRepository) rewriteBannerTags(ctx context.Context, q querier, bannerId int64, tagIds []int64) error {
 	// delete existing banners_tags records for the given bannerId
-	_, txerr = tx.Exec(
-		context.Background(),
+	_, err := q.Exec(
+		ctx,
 		"DELETE FROM banners_tags WHERE banner_id = $1",
 		bannerId,
 	)
-	if txerr != nil {
-		br.l.Error(txerr)
-		return serverr.StorageError
+	if err != nil {
+		return err
 	}
 
 	// insert new banners_tags records
+	return br.insertBannerTags(ctx, q, bannerId, tagIds)
+}
+
+func (br *BannerRepository) insertBannerTags(ctx context.Context, q querier, bannerId int64, tagIds []int64) error {
 	for _, tagId := range tagIds {
-		_, txerr = tx.Exec(
-			context.Background(),
+		_, err := q.Exec(
+			ctx,
 			"INSERT INTO banners_tags (banner_id, tag_id) VALUES ($1, $2)",
 			bannerId,
 			tagId,
 		)
-		if txerr != nil {
-			br.l.Error(txerr)
-			return serverr.StorageError
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (br *BannerRepository) ChangeBanner(bannerId int64, chban *models.BannerTagsModel) *serverr.ApiError {
-	// start a transaction
-	tx, txerr := br.p.Begin(context.Background())
-	if txerr != nil {
-		br.l.Error(txerr)
-		return serverr.StorageError
+func (br *BannerRepository) ChangeBanner(ctx context.Context, bannerId int64, chban *models.BannerTagsModel) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerRepository.ChangeBanner")
+	defer span.End()
+
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		return br.updateBanner(ctx, tx, bannerId, chban)
+	})
+
+	return br.toApiError(ctx, err)
+}
+
+// locales
+// Banners without content variants are stored with an empty object, not null
+func locales(l models.Locales) models.Locales {
+	if l == nil {
+		return models.Locales{}
 	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if txerr != nil {
-			br.l.Error(txerr)
-			tx.Rollback(context.Background())
-		} else {
-			txerr = tx.Commit(context.Background())
-		}
-	}()
 
+	return l
+}
+
+func (br *BannerRepository) updateBanner(ctx context.Context, q querier, bannerId int64, chban *models.BannerTagsModel) error {
 	// update the fields in the banners table
-	_, txerr = tx.Exec(
-		context.Background(),
+	_, err := q.Exec(
+		ctx,
 		`UPDATE banners 
 			 SET content = $1, 
 			     feature_id = $2, 
 			     is_active = $3, 
 			     updated_at = $4, 
 			     to_delete = $5,
-			     last_revision = $6
+			     last_revision = $6,
+			     locales = $8,
+			     priority = $9,
+			     is_default = $10,
+			     rule = $11
 			 WHERE id = $7`,
 		chban.Content,
 		chban.FeatureId,
@@ -521,16 +713,19 @@ func (br *BannerRepository) ChangeBanner(bannerId int64, chban *models.BannerTag
 		chban.ToDelete,
 		chban.LastRevision,
 		bannerId,
+		locales(chban.Locales),
+		chban.Priority,
+		chban.IsDefault,
+		chban.Rule,
 	)
-	if txerr != nil {
-		br.l.Error(txerr)
-		return serverr.StorageError
-	}
 
-	return nil
+	return err
 }
 
-func (br *BannerRepository) GetBannersByFilter(featureId int64, tagId int64, limit int64, offset int64) ([]models.BannerTagsModel, *serverr.ApiError) {
+func (br *BannerRepository) GetBannersByFilter(ctx context.Context, tenant string, env string, featureId int64, tagId int64, limit int64, offset int64) ([]models.BannerTagsModel, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetBannersByFilter")
+	defer span.End()
+
 	// construct query logic
 	var featureQp, andQp, tagIdQp string
 
@@ -560,16 +755,22 @@ func (br *BannerRepository) GetBannersByFilter(featureId int64, tagId int64, lim
 			   bt.tag_id,
 			   b.feature_id,
 			   b.content,
+			   b.locales,
+			   b.rule,
 			   b.is_active,
+			   b.priority,
+			   b.is_default,
 			   b.to_delete,
 			   b.created_at,
 			   b.updated_at
 		FROM banners b
 		JOIN banners_tags bt on b.id = bt.banner_id
 		WHERE
+		b.environment = $1 AND b.tenant = $2 AND (
 		%s
 		%s
 		%s
+		)
 		`,
 		featureQp,
 		andQp,
@@ -577,9 +778,9 @@ func (br *BannerRepository) GetBannersByFilter(featureId int64, tagId int64, lim
 	)
 
 	// exec query
-	rows, err := br.p.Query(context.Background(), query)
+	rows, err := br.p.Query(ctx, query, env, tenant)
 	if err != nil {
-		br.l.Error(err)
+		br.log(ctx).Error(err)
 		return nil, serverr.StorageError
 	}
 	defer rows.Close()
@@ -593,7 +794,11 @@ func (br *BannerRepository) GetBannersByFilter(featureId int64, tagId int64, lim
 			&banner.TagId,
 			&banner.FeatureId,
 			&banner.Content,
+			&banner.Locales,
+			&banner.Rule,
 			&banner.IsActive,
+			&banner.Priority,
+			&banner.IsDefault,
 			&banner.ToDelete,
 			&banner.CreatedAt,
 			&banner.UpdatedAt,
@@ -604,26 +809,38 @@ func (br *BannerRepository) GetBannersByFilter(featureId int64, tagId int64, lim
 
 		if curModel.Id == 0 {
 			curModel = models.BannerTagsModel{
-				Id:        banner.Id,
-				FeatureId: banner.FeatureId,
-				Content:   banner.Content,
-				IsActive:  banner.IsActive,
-				ToDelete:  banner.ToDelete,
-				CreatedAt: banner.CreatedAt,
-				UpdatedAt: banner.UpdatedAt,
+				Id:          banner.Id,
+				FeatureId:   banner.FeatureId,
+				Content:     banner.Content,
+				Locales:     banner.Locales,
+				Rule:        banner.Rule,
+				IsActive:    banner.IsActive,
+				Priority:    banner.Priority,
+				IsDefault:   banner.IsDefault,
+				ToDelete:    banner.ToDelete,
+				CreatedAt:   banner.CreatedAt,
+				UpdatedAt:   banner.UpdatedAt,
+				Environment: env,
+				Tenant:      tenant,
 			}
 			curModel.TagIds = append(curModel.TagIds, banner.TagId)
 		} else if banner.Id != curModel.Id {
 			banners = append(banners, curModel)
 
 			curModel = models.BannerTagsModel{
-				Id:        banner.Id,
-				FeatureId: banner.FeatureId,
-				Content:   banner.Content,
-				IsActive:  banner.IsActive,
-				ToDelete:  banner.ToDelete,
-				CreatedAt: banner.CreatedAt,
-				UpdatedAt: banner.UpdatedAt,
+				Id:          banner.Id,
+				FeatureId:   banner.FeatureId,
+				Content:     banner.Content,
+				Locales:     banner.Locales,
+				Rule:        banner.Rule,
+				IsActive:    banner.IsActive,
+				Priority:    banner.Priority,
+				IsDefault:   banner.IsDefault,
+				ToDelete:    banner.ToDelete,
+				CreatedAt:   banner.CreatedAt,
+				UpdatedAt:   banner.UpdatedAt,
+				Environment: env,
+				Tenant:      tenant,
 			}
 			curModel.TagIds = append(curModel.TagIds, banner.TagId)
 		} else {
@@ -661,31 +878,16 @@ func (br *BannerRepository) GetBannersByFilter(featureId int64, tagId int64, lim
 	return result, nil
 }
 
-func (br *BannerRepository) DeleteBannersByTagOrFeatureId(featureId int64, tagId int64) *serverr.ApiError {
-	tx, txerr := br.p.Begin(context.Background())
-	if txerr != nil {
-		br.l.Error(txerr)
-		return serverr.StorageError
-	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if txerr != nil {
-			br.l.Error(txerr)
-			tx.Rollback(context.Background())
-		} else {
-			txerr = tx.Commit(context.Background())
-		}
-	}()
+func (br *BannerRepository) DeleteBannersByTagOrFeatureId(ctx context.Context, tenant string, env string, featureId int64, tagId int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerRepository.DeleteBannersByTagOrFeatureId")
+	defer span.End()
 
 	var query string
 	var param int64
 	if featureId != 0 {
-		featureExists, err := br.DoesFeatureExist(featureId)
+		featureExists, err := br.DoesFeatureExist(ctx, tenant, featureId)
 		if err != nil {
-			br.l.Error(err)
-			return serverr.StorageError
+			return br.toApiError(ctx, err)
 		}
 
 		if !featureExists {
@@ -697,13 +899,13 @@ func (br *BannerRepository) DeleteBannersByTagOrFeatureId(featureId int64, tagId
 			UPDATE banners
         	SET to_delete = true
         	WHERE feature_id = $1
+        	  AND environment = $2
+        	  AND tenant = $3
         `
-		defer br.l.Infof("Banners with feature_id=%d were marked as deleted", param)
 	} else {
-		tagsExist, err := br.DoTagsExist([]int64{tagId})
+		tagsExist, err := br.DoTagsExist(ctx, tenant, []int64{tagId})
 		if err != nil {
-			br.l.Error(err.Error())
-			return serverr.StorageError
+			return br.toApiError(ctx, err)
 		}
 
 		if !tagsExist {
@@ -718,31 +920,48 @@ func (br *BannerRepository) DeleteBannersByTagOrFeatureId(featureId int64, tagId
 					SELECT banner_id
 					FROM banners_tags
 					WHERE tag_id = $1
-        		)`
-		defer br.l.Infof("Banners with tag_id=%d were marked as deleted", param)
+        		)
+        	  AND environment = $2
+        	  AND tenant = $3`
 	}
 
-	_, txerr = tx.Exec(context.Background(), query, param)
-	if txerr != nil {
-		br.l.Error(txerr)
-		return serverr.StorageError
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query, param, env, tenant)
+		return err
+	})
+	if err != nil {
+		return br.toApiError(ctx, err)
+	}
+
+	if featureId != 0 {
+		br.log(ctx).Infof("Banners with feature_id=%d were marked as deleted in %s", param, env)
+	} else {
+		br.log(ctx).Infof("Banners with tag_id=%d were marked as deleted in %s", param, env)
 	}
 
 	return nil
 }
 
-func (br *BannerRepository) GetBannerVersions(bannerId int64) ([]models.BannerVersion, *serverr.ApiError) {
+func (br *BannerRepository) GetBannerVersions(ctx context.Context, tenant string, env string, bannerId int64) ([]models.BannerVersion, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetBannerVersions")
+	defer span.End()
+
 	rows, err := br.p.Query(
-		context.Background(),
+		ctx,
 		`SELECT bv.banner_id,
        				bv.version,
        				bv.feature_id,
        				bv.tags,
        				bv.content,
+       				bv.locales,
+       				bv.rule,
        				bv.created_at
 			 FROM banner_version bv
-			 WHERE banner_id = $1`,
+			 	  JOIN banners b on bv.banner_id = b.id
+			 WHERE bv.banner_id = $1 AND b.tenant = $2 AND b.environment = $3`,
 		bannerId,
+		tenant,
+		env,
 	)
 	if err != nil {
 		return nil, serverr.StorageError
@@ -752,8 +971,8 @@ func (br *BannerRepository) GetBannerVersions(bannerId int64) ([]models.BannerVe
 	var versions []models.BannerVersion
 	for rows.Next() {
 		var c models.BannerVersion
-		if err := rows.Scan(&c.BannerId, &c.Version, &c.FeatureId, &c.Tags, &c.Content, &c.CreatedAt); err != nil {
-			br.l.Error(err)
+		if err := rows.Scan(&c.BannerId, &c.Version, &c.FeatureId, &c.Tags, &c.Content, &c.Locales, &c.Rule, &c.CreatedAt); err != nil {
+			br.log(ctx).Error(err)
 			return nil, serverr.StorageError
 		}
 		versions = append(versions, c)
@@ -766,8 +985,9 @@ func (br *BannerRepository) GetBannerVersions(bannerId int64) ([]models.BannerVe
 	return versions, nil
 }
 
-func (br *BannerRepository) SetBannerVersion(bannerId int64, versionId int64) *serverr.ApiError {
-	fmt.Println(bannerId, versionId)
+func (br *BannerRepository) SetBannerVersion(ctx context.Context, tenant string, env string, bannerId int64, versionId int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerRepository.SetBannerVersion")
+	defer span.End()
 
 	// check if the specified version of the banner exists
 	query := `
@@ -776,109 +996,210 @@ func (br *BannerRepository) SetBannerVersion(bannerId int64, versionId int64) *s
                bv.feature_id,
                bv.tags,
                bv.content,
+               bv.locales,
+               bv.rule,
                bv.created_at,
                b.is_active,
+               b.priority,
+               b.is_default,
                b.to_delete,
                b.id,
-               b.created_at
+               b.created_at,
+               b.environment
         FROM banner_version bv
         	 JOIN banners b on bv.banner_id = b.id
-        WHERE banner_id = $1 AND version = $2
+        WHERE banner_id = $1 AND version = $2 AND b.tenant = $3 AND b.environment = $4
     `
 	var version models.BannerVersion
 	var chban models.BannerTagsModel
 	err := br.p.QueryRow(
-		context.Background(),
+		ctx,
 		query,
 		bannerId,
-		versionId).Scan(
-			&version.BannerId,
-			&version.Version,
-			&version.FeatureId,
-			&version.Tags,
-			&version.Content,
-			&version.CreatedAt,
-			&chban.IsActive,
-			&chban.ToDelete,
-			&chban.Id,
-			&chban.CreatedAt)
+		versionId,
+		tenant,
+		env).Scan(
+		&version.BannerId,
+		&version.Version,
+		&version.FeatureId,
+		&version.Tags,
+		&version.Content,
+		&version.Locales,
+		&version.Rule,
+		&version.CreatedAt,
+		&chban.IsActive,
+		&chban.Priority,
+		&chban.IsDefault,
+		&chban.ToDelete,
+		&chban.Id,
+		&chban.CreatedAt,
+		&chban.Environment)
 	if err != nil {
-		br.l.Error(err)
+		br.log(ctx).Error(err)
 		return serverr.BannerNotFoundError
 	}
 
-	tx, txerr := br.p.Begin(context.Background())
-	if txerr != nil {
-		br.l.Error(txerr)
-		return serverr.StorageError
-	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if txerr != nil {
-			br.l.Error(txerr)
-			tx.Rollback(context.Background())
-		} else {
-			txerr = tx.Commit(context.Background())
-		}
-	}()
-
 	// retrieve a slice from string of tagIds
 	chban.TagIds, err = util.StringToIntSlice(version.Tags)
+	if err != nil {
+		br.log(ctx).Error(err)
+		return serverr.StorageError
+	}
 
 	chban.Content = version.Content
+	chban.Locales = version.Locales
+	chban.Rule = version.Rule
 	chban.FeatureId = version.FeatureId
 
 	// change updated_at because technically its updated now
 	chban.UpdatedAt = time.Now()
 	chban.LastRevision = versionId
+	chban.Tenant = tenant
 
-	apierr := br.ChangeBanner(bannerId, &chban)
-	if apierr != nil {
-		return serverr.StorageError
+	// pairs of the version may be taken by another banner since
+	owner, err := br.FindPairOwner(ctx, tenant, chban.Environment, chban.FeatureId, chban.TagIds, bannerId)
+	if err != nil {
+		return br.toApiError(ctx, err)
 	}
 
-	apierr = br.RewriteBannerTags(bannerId, chban.TagIds)
-	if apierr != nil {
-		return serverr.StorageError
+	if owner != nil {
+		return serverr.NewPairConflictError(owner.FeatureId, owner.TagId, owner.Id)
 	}
 
-	apierr = br.DeleteVersionsGreaterThan(versionId)
-	if apierr != nil {
-		return apierr
-	}
+	// banner, its tags and versions are changed in one transaction
+	err = br.inTx(ctx, func(tx pgx.Tx) error {
+		if err := br.updateBanner(ctx, tx, bannerId, &chban); err != nil {
+			return err
+		}
 
-	return nil
+		if err := br.rewriteBannerTags(ctx, tx, bannerId, chban.TagIds); err != nil {
+			return err
+		}
+
+		return br.deleteVersionsGreaterThan(ctx, tx, bannerId, versionId)
+	})
+
+	return br.toApiError(ctx, br.pairConflict(ctx, err, &chban))
 }
 
-func (br *BannerRepository) DeleteVersionsGreaterThan(versionId int64) *serverr.ApiError {
-	tx, txerr := br.p.Begin(context.Background())
-	if txerr != nil {
-		br.l.Error(txerr)
-		return serverr.StorageError
-	}
-	defer func() {
-		if pm := recover(); pm != nil {
-			tx.Rollback(context.Background())
-			panic(pm)
-		} else if txerr != nil {
-			br.l.Error(txerr)
-			tx.Rollback(context.Background())
-		} else {
-			txerr = tx.Commit(context.Background())
-		}
-	}()
+func (br *BannerRepository) DeleteVersionsGreaterThan(ctx context.Context, bannerId int64, versionId int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerRepository.DeleteVersionsGreaterThan")
+	defer span.End()
 
+	err := br.inTx(ctx, func(tx pgx.Tx) error {
+		return br.deleteVersionsGreaterThan(ctx, tx, bannerId, versionId)
+	})
+
+	return br.toApiError(ctx, err)
+}
+
+func (br *BannerRepository) deleteVersionsGreaterThan(ctx context.Context, q querier, bannerId int64, versionId int64) error {
 	sqlStatement := `
         DELETE FROM banner_version
-        WHERE version > $1
+        WHERE banner_id = $1 AND version > $2
     `
 
-	_, txerr = tx.Exec(context.Background(), sqlStatement, versionId)
-	if txerr != nil {
-		return serverr.StorageError
+	_, err := q.Exec(ctx, sqlStatement, bannerId, versionId)
+
+	return err
+}
+
+// GetActivePairs
+// Returns feature-tag pairs of active banners of every tenant and environment, the most
+// recently updated go first. If limit is 0 all pairs are returned
+func (br *BannerRepository) GetActivePairs(ctx context.Context, limit int) ([]models.BannerModel, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetActivePairs")
+	defer span.End()
+
+	query := `
+		SELECT b.tenant,
+			   b.environment,
+			   b.feature_id,
+			   bt.tag_id
+		FROM banners b
+			 JOIN banners_tags bt on b.id = bt.banner_id
+		WHERE b.is_active = true
+		  AND b.to_delete = false
+		ORDER BY b.updated_at DESC
+	`
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+
+	return br.queryPairs(ctx, query)
+}
+
+// GetPairsByFeatureOrTag
+// Returns feature-tag pairs of banners of the tenant and environment with the feature_id or the tag_id
+func (br *BannerRepository) GetPairsByFeatureOrTag(ctx context.Context, tenant string, env string, featureId int64, tagId int64) ([]models.BannerModel, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetPairsByFeatureOrTag")
+	defer span.End()
+
+	query := `
+		SELECT b.tenant,
+			   b.environment,
+			   b.feature_id,
+			   bt.tag_id
+		FROM banners b
+			 JOIN banners_tags bt on b.id = bt.banner_id
+		WHERE b.environment = $3
+		  AND b.tenant = $4
+		  AND (b.feature_id = $1
+		   OR b.id IN (
+				SELECT banner_id
+				FROM banners_tags
+				WHERE tag_id = $2
+		   ))
+	`
+
+	return br.queryPairs(ctx, query, featureId, tagId, env, tenant)
+}
+
+func (br *BannerRepository) queryPairs(ctx context.Context, query string, args ...any) ([]models.BannerModel, error) {
+	rows, err := br.p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs []models.BannerModel
+	for rows.Next() {
+		var pair models.BannerModel
+		if err := rows.Scan(&pair.Tenant, &pair.Environment, &pair.FeatureId, &pair.TagId); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, rows.Err()
+}
+
+// CheckSchema
+// Checks that every migration up to the version is applied, a newer schema is accepted
+// so that replicas of the previous release stay ready during rolling update
+func (br *BannerRepository) CheckSchema(ctx context.Context, version int64) error {
+	ctx, span := tracing.Start(ctx, "BannerRepository.CheckSchema")
+	defer span.End()
+
+	var migrated bool
+	err := br.p.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&migrated)
+	if err != nil {
+		return err
+	}
+
+	if !migrated {
+		return errors.New("schema_migrations table is missing")
+	}
+
+	var current int64
+	err = br.p.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	if current < version {
+		return fmt.Errorf("schema version is %d, expected %d", current, version)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
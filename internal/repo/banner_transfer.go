@@ -0,0 +1,183 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+)
+
+// ExportBanners
+// Streams banners of the tenant and environment that are not deleted ordered by id, featureId = 0 exports banners of every feature.
+// Versions are passed only if withVersions is set, emit error stops the export and is returned
+func (br *BannerRepository) ExportBanners(
+	ctx context.Context,
+	tenant string,
+	env string,
+	featureId int64,
+	withVersions bool,
+	emit func(banner models.BannerTagsModel, versions []models.BannerVersion) error,
+) error {
+	ctx, span := tracing.Start(ctx, "BannerRepository.ExportBanners")
+	defer span.End()
+
+	// versions are aggregated into json with the keys of models.BannerVersion,
+	// so the whole history is read with the banner in one query
+	rows, err := br.p.Query(
+		ctx,
+		`SELECT b.id,
+			    COALESCE(b.external_key, ''),
+			    b.feature_id,
+			    b.content,
+			    b.locales,
+			    b.rule,
+			    b.is_active,
+			    b.priority,
+			    b.is_default,
+			    b.created_at,
+			    b.updated_at,
+			    array_agg(bt.tag_id ORDER BY bt.tag_id),
+			    CASE WHEN $1 THEN (
+			        SELECT json_agg(json_build_object(
+			                   'banner_id', bv.banner_id::text,
+			                   'version', bv.version,
+			                   'feature_id', bv.feature_id,
+			                   'tags', bv.tags,
+			                   'content', bv.content,
+			                   'locales', NULLIF(bv.locales, '{}'),
+			                   'rule', NULLIF(bv.rule, ''),
+			                   'created_at', bv.created_at AT TIME ZONE 'UTC'
+			               ) ORDER BY bv.version)
+			        FROM banner_version bv
+			        WHERE bv.banner_id = b.id
+			    ) END
+			 FROM banners b
+			 JOIN banners_tags bt ON b.id = bt.banner_id
+			 WHERE b.to_delete = false
+			   AND b.environment = $3
+			   AND b.tenant = $4
+			   AND ($2::bigint = 0 OR b.feature_id = $2)
+			 GROUP BY b.id
+			 ORDER BY b.id`,
+		withVersions,
+		featureId,
+		env,
+		tenant,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		banner := models.BannerTagsModel{Environment: env, Tenant: tenant}
+		var rawVersions []byte
+		err := rows.Scan(
+			&banner.Id,
+			&banner.ExternalKey,
+			&banner.FeatureId,
+			&banner.Content,
+			&banner.Locales,
+			&banner.Rule,
+			&banner.IsActive,
+			&banner.Priority,
+			&banner.IsDefault,
+			&banner.CreatedAt,
+			&banner.UpdatedAt,
+			&banner.TagIds,
+			&rawVersions,
+		)
+		if err != nil {
+			return err
+		}
+
+		var versions []models.BannerVersion
+		if rawVersions != nil {
+			if err := json.Unmarshal(rawVersions, &versions); err != nil {
+				return err
+			}
+		}
+
+		if err := emit(banner, versions); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetBannerByExternalKey
+// Returns id of the banner of the tenant and environment with the external key and whether it's marked
+// as deleted, id is 0 if there is no such banner
+func (br *BannerRepository) GetBannerByExternalKey(ctx context.Context, tenant string, env string, key string) (int64, bool, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetBannerByExternalKey")
+	defer span.End()
+
+	var id int64
+	var toDelete bool
+	err := br.p.QueryRow(
+		ctx,
+		"SELECT id, to_delete FROM banners WHERE environment = $1 AND external_key = $2 AND tenant = $3",
+		env,
+		key,
+		tenant,
+	).Scan(&id, &toDelete)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return id, toDelete, nil
+}
+
+// GetOverlappingBanners
+// Returns ids of the banners of the tenant and environment that are mapped to any of the feature-tag pairs
+func (br *BannerRepository) GetOverlappingBanners(ctx context.Context, tenant string, env string, featureId int64, tagIds []int64) ([]int64, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.GetOverlappingBanners")
+	defer span.End()
+
+	rows, err := br.p.Query(
+		ctx,
+		`SELECT DISTINCT b.id
+			 FROM banners b
+			 JOIN banners_tags bt ON b.id = bt.banner_id
+			 WHERE b.environment = $1
+			   AND b.feature_id = $2
+			   AND bt.tag_id = ANY($3)
+			   AND b.tenant = $4
+			 ORDER BY b.id`,
+		env,
+		featureId,
+		tagIds,
+		tenant,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// CountBanners
+// Returns the number of banners of the tenant in every environment, banners marked as deleted aren't counted
+func (br *BannerRepository) CountBanners(ctx context.Context, tenant string) (int64, error) {
+	ctx, span := tracing.Start(ctx, "BannerRepository.CountBanners")
+	defer span.End()
+
+	return countBanners(ctx, br.p, tenant)
+}
+
+func countBanners(ctx context.Context, q querier, tenant string) (int64, error) {
+	var count int64
+	err := q.QueryRow(
+		ctx,
+		"SELECT COUNT(*) FROM banners WHERE tenant = $1 AND to_delete = false",
+		tenant,
+	).Scan(&count)
+
+	return count, err
+}
@@ -0,0 +1,154 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 10 * time.Second
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+var ErrCircuitOpen = errors.New("redis: circuit breaker is open")
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type BreakerConfig struct {
+	// FailureThreshold is a number of consecutive failures that opens the circuit
+	FailureThreshold int `toml:"failure_threshold"`
+	// OpenTimeout is a time after which a single probe call is let through
+	OpenTimeout time.Duration `toml:"open_timeout"`
+}
+
+// CircuitBreaker
+// Stops calling redis after several consecutive failures, so requests are not
+// slowed down by timeouts while redis is unavailable. After OpenTimeout one call
+// is let through, if it succeeds the circuit is closed again
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	// gen changes with every state change, results of calls made in an older one are stale
+	gen uint64
+
+	threshold   int
+	openTimeout time.Duration
+	onChange    func(from BreakerState, to BreakerState)
+}
+
+func NewCircuitBreaker(bc BreakerConfig, onChange func(from BreakerState, to BreakerState)) *CircuitBreaker {
+	if bc.FailureThreshold <= 0 {
+		bc.FailureThreshold = defaultFailureThreshold
+	}
+	if bc.OpenTimeout <= 0 {
+		bc.OpenTimeout = defaultOpenTimeout
+	}
+
+	return &CircuitBreaker{
+		threshold:   bc.FailureThreshold,
+		openTimeout: bc.OpenTimeout,
+		onChange:    onChange,
+	}
+}
+
+// Allow
+// Returns ErrCircuitOpen if the call must not be made, otherwise
+// the generation of the state the call is made in, it's passed to Report
+func (cb *CircuitBreaker) Allow() (uint64, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if time.Since(cb.openedAt) < cb.openTimeout {
+			return 0, ErrCircuitOpen
+		}
+		cb.setState(BreakerHalfOpen)
+		cb.probing = true
+		return cb.gen, nil
+	case BreakerHalfOpen:
+		// only one probe call at a time
+		if cb.probing {
+			return 0, ErrCircuitOpen
+		}
+		cb.probing = true
+		return cb.gen, nil
+	default:
+		return cb.gen, nil
+	}
+}
+
+// Report
+// Records the result of the call made in the generation, a cache miss is not a failure.
+// Results of calls made before the state changed are ignored, as well as cancelled
+// and timed out requests, they say nothing about redis
+func (cb *CircuitBreaker) Report(gen uint64, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if gen != cb.gen {
+		return
+	}
+
+	// the call is done, another probe may be let through
+	cb.probing = false
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+
+	if err == nil || errors.Is(err, redis.Nil) {
+		cb.failures = 0
+		cb.setState(BreakerClosed)
+		return
+	}
+
+	cb.failures++
+	if cb.state == BreakerHalfOpen || cb.failures >= cb.threshold {
+		cb.openedAt = time.Now()
+		cb.setState(BreakerOpen)
+	}
+}
+
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
+
+func (cb *CircuitBreaker) setState(state BreakerState) {
+	if cb.state == state {
+		return
+	}
+
+	from := cb.state
+	cb.state = state
+	cb.gen++
+	if cb.onChange != nil {
+		cb.onChange(from, state)
+	}
+}
@@ -4,34 +4,69 @@ import (
 	"context"
 	"errors"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"strings"
 	"time"
 )
 
+var ErrCacheMiss = errors.New("redis: error while getting content")
+
 type CacheRepo struct {
 	redcli *redis.Client
-	c      context.Context
+	cb     *CircuitBreaker
+	l      *zap.SugaredLogger
 }
 
-func NewCacheRepo(client *redis.Client) *CacheRepo {
-	return &CacheRepo{
+func NewCacheRepo(client *redis.Client, bc BreakerConfig, l *zap.SugaredLogger) *CacheRepo {
+	cr := &CacheRepo{
 		redcli: client,
-		c:      context.Background(),
+		l:      l,
 	}
+	cr.cb = NewCircuitBreaker(bc, func(from BreakerState, to BreakerState) {
+		cr.l.Warnf("redis: circuit breaker state changed %s -> %s", from, to)
+	})
+
+	return cr
+}
+
+// Degraded
+// Reports whether redis calls are currently skipped because of failures
+func (cr *CacheRepo) Degraded() bool {
+	return cr.cb.State() != BreakerClosed
+}
+
+func (cr *CacheRepo) BreakerState() BreakerState {
+	return cr.cb.State()
 }
 
-func (cr *CacheRepo) Set(key string, content string, ttl time.Duration) error {
-	if err := cr.redcli.Set(cr.c, key, content, ttl).Err(); err != nil {
+// call
+// Runs the redis call through circuit breaker
+func (cr *CacheRepo) call(f func() error) error {
+	gen, err := cr.cb.Allow()
+	if err != nil {
 		return err
 	}
 
-	return nil
+	err = f()
+	cr.cb.Report(gen, err)
+
+	return err
 }
 
-func (cr *CacheRepo) Get(key string) (string, error) {
+func (cr *CacheRepo) Set(ctx context.Context, key string, content string, ttl time.Duration) error {
+	return cr.call(func() error {
+		return cr.redcli.Set(ctx, key, content, ttl).Err()
+	})
+}
+
+func (cr *CacheRepo) Get(ctx context.Context, key string) (string, error) {
 	var content string
-	if err := cr.redcli.Get(cr.c, key).Scan(&content); err != nil {
+	err := cr.call(func() error {
+		return cr.redcli.Get(ctx, key).Scan(&content)
+	})
+	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			err = errors.New("redis: error while getting content")
+			err = ErrCacheMiss
 		}
 
 		return "", err
@@ -39,3 +74,102 @@ func (cr *CacheRepo) Get(key string) (string, error) {
 
 	return content, nil
 }
+
+// GetFieldsWithTtl
+// Returns values of the hash fields, empty for missing ones, together with the remaining
+// time to live of the key, both are read in a single round-trip. A key of another type,
+// e.g. left by a previous version of the service, is a miss
+func (cr *CacheRepo) GetFieldsWithTtl(ctx context.Context, key string, fields ...string) ([]string, time.Duration, error) {
+	var get *redis.SliceCmd
+	var pttl *redis.DurationCmd
+
+	err := cr.call(func() error {
+		_, err := cr.redcli.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			get = pipe.HMGet(ctx, key, fields...)
+			pttl = pipe.PTTL(ctx, key)
+			return nil
+		})
+		if err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE") {
+			return redis.Nil
+		}
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			err = ErrCacheMiss
+		}
+
+		return nil, 0, err
+	}
+
+	values := make([]string, len(fields))
+	for i, v := range get.Val() {
+		values[i], _ = v.(string)
+	}
+
+	return values, pttl.Val(), nil
+}
+
+// SetFields
+// Replaces the hash with the fields, the key expires after ttl
+func (cr *CacheRepo) SetFields(ctx context.Context, key string, fields map[string]string, ttl time.Duration) error {
+	values := make(map[string]interface{}, len(fields))
+	for field, value := range fields {
+		values[field] = value
+	}
+
+	return cr.call(func() error {
+		_, err := cr.redcli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			pipe.HSet(ctx, key, values)
+			pipe.PExpire(ctx, key, ttl)
+			return nil
+		})
+		return err
+	})
+}
+
+func (cr *CacheRepo) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return cr.call(func() error {
+		return cr.redcli.Del(ctx, keys...).Err()
+	})
+}
+
+// IncrScore
+// Increments the score of the member in sorted set, used to count requests per key.
+// Only maxMembers members with the highest score are kept, the set expires ttl after
+// it's created, so the scores are counted in windows of ttl
+func (cr *CacheRepo) IncrScore(ctx context.Context, set string, member string, maxMembers int64, ttl time.Duration) error {
+	return cr.call(func() error {
+		_, err := cr.redcli.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZIncrBy(ctx, set, 1, member)
+			pipe.ZRemRangeByRank(ctx, set, 0, -maxMembers-1)
+			pipe.ExpireNX(ctx, set, ttl)
+			return nil
+		})
+		return err
+	})
+}
+
+// TopMembers
+// Returns up to n members of sorted set with the highest score
+func (cr *CacheRepo) TopMembers(ctx context.Context, set string, n int) ([]string, error) {
+	var members []string
+	err := cr.call(func() error {
+		var err error
+		members, err = cr.redcli.ZRevRange(ctx, set, 0, int64(n-1)).Result()
+		return err
+	})
+
+	return members, err
+}
+
+// Ping
+// Checks redis directly, bypassing circuit breaker
+func (cr *CacheRepo) Ping(ctx context.Context) error {
+	return cr.redcli.Ping(ctx).Err()
+}
@@ -0,0 +1,174 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"go.uber.org/zap"
+)
+
+// catalog
+// Table of features or tags together with the query checking whether banners use the item
+type catalog struct {
+	table    string
+	inUse    string
+	inUseErr string
+	notFound *serverr.ApiError
+}
+
+var (
+	featureCatalog = catalog{
+		table:    "features",
+		inUse:    "SELECT EXISTS(SELECT 1 FROM banners WHERE feature_id = $1)",
+		inUseErr: "Фича используется баннерами",
+		notFound: serverr.FeatureNotFoundError,
+	}
+	tagCatalog = catalog{
+		table:    "tags",
+		inUse:    "SELECT EXISTS(SELECT 1 FROM banners_tags WHERE tag_id = $1)",
+		inUseErr: "Тег используется баннерами",
+		notFound: serverr.TagNotFoundError,
+	}
+)
+
+// CatalogRepository
+// Manages features and tags, banners are bound to them
+type CatalogRepository struct {
+	p *pgxpool.Pool
+	l *zap.SugaredLogger
+}
+
+func NewCatalogRepository(p *pgxpool.Pool, l *zap.SugaredLogger) *CatalogRepository {
+	return &CatalogRepository{
+		p: p,
+		l: l,
+	}
+}
+
+func (cr *CatalogRepository) log(ctx context.Context) *zap.SugaredLogger {
+	return logging.For(ctx, cr.l)
+}
+
+func (cr *CatalogRepository) GetFeatures(ctx context.Context, tenant string, limit int64, offset int64) ([]models.CatalogItem, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "CatalogRepository.GetFeatures")
+	defer span.End()
+
+	return cr.list(ctx, featureCatalog, tenant, limit, offset)
+}
+
+func (cr *CatalogRepository) GetTags(ctx context.Context, tenant string, limit int64, offset int64) ([]models.CatalogItem, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "CatalogRepository.GetTags")
+	defer span.End()
+
+	return cr.list(ctx, tagCatalog, tenant, limit, offset)
+}
+
+func (cr *CatalogRepository) CreateFeature(ctx context.Context, tenant string, name string) (int64, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "CatalogRepository.CreateFeature")
+	defer span.End()
+
+	return cr.create(ctx, featureCatalog, tenant, name)
+}
+
+func (cr *CatalogRepository) CreateTag(ctx context.Context, tenant string, name string) (int64, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "CatalogRepository.CreateTag")
+	defer span.End()
+
+	return cr.create(ctx, tagCatalog, tenant, name)
+}
+
+func (cr *CatalogRepository) DeleteFeature(ctx context.Context, tenant string, id int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "CatalogRepository.DeleteFeature")
+	defer span.End()
+
+	return cr.delete(ctx, featureCatalog, tenant, id)
+}
+
+func (cr *CatalogRepository) DeleteTag(ctx context.Context, tenant string, id int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "CatalogRepository.DeleteTag")
+	defer span.End()
+
+	return cr.delete(ctx, tagCatalog, tenant, id)
+}
+
+func (cr *CatalogRepository) list(ctx context.Context, c catalog, tenant string, limit int64, offset int64) ([]models.CatalogItem, *serverr.ApiError) {
+	// limit = 0 means no limit
+	query := "SELECT id, name FROM " + c.table + " WHERE tenant = $3 ORDER BY id LIMIT NULLIF($1, 0) OFFSET $2"
+
+	rows, err := cr.p.Query(ctx, query, limit, offset, tenant)
+	if err != nil {
+		return nil, toApiError(cr.log(ctx), err)
+	}
+	defer rows.Close()
+
+	items := make([]models.CatalogItem, 0)
+	for rows.Next() {
+		var item models.CatalogItem
+		var name *string
+		if err := rows.Scan(&item.Id, &name); err != nil {
+			return nil, toApiError(cr.log(ctx), err)
+		}
+		if name != nil {
+			item.Name = *name
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, toApiError(cr.log(ctx), err)
+	}
+
+	return items, nil
+}
+
+func (cr *CatalogRepository) create(ctx context.Context, c catalog, tenant string, name string) (int64, *serverr.ApiError) {
+	var id int64
+	err := cr.p.QueryRow(ctx, "INSERT INTO "+c.table+"(name, tenant) VALUES ($1, $2) RETURNING id", name, tenant).Scan(&id)
+	if err != nil {
+		return 0, toApiError(cr.log(ctx), err)
+	}
+
+	cr.log(ctx).Infof("%s: [id=%d] is created for tenant %s", c.table, id, tenant)
+
+	return id, nil
+}
+
+// delete
+// Deletes the item of the tenant if no banner uses it, otherwise banners would silently lose it.
+// The item is locked before the check, so banners can't start using it until the delete is committed
+func (cr *CatalogRepository) delete(ctx context.Context, c catalog, tenant string, id int64) *serverr.ApiError {
+	err := inTx(ctx, cr.p, cr.log(ctx), func(tx pgx.Tx) error {
+		var locked int64
+		err := tx.QueryRow(ctx, "SELECT id FROM "+c.table+" WHERE id = $1 AND tenant = $2 FOR UPDATE", id, tenant).Scan(&locked)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.notFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var inUse bool
+		if err := tx.QueryRow(ctx, c.inUse, id).Scan(&inUse); err != nil {
+			return err
+		}
+
+		if inUse {
+			return serverr.NewConflictError(c.inUseErr)
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM "+c.table+" WHERE id = $1", id)
+		return err
+	})
+	if err != nil {
+		return toApiError(cr.log(ctx), err)
+	}
+
+	cr.log(ctx).Infof("%s: [id=%d] is deleted", c.table, id)
+
+	return nil
+}
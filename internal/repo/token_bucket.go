@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// takeTokenScript
+// Token bucket kept in a hash with the number of tokens and the time of the last
+// request. Redis time is used, so replicas with skewed clocks share the bucket.
+// Returns {1, 0} if a token is taken, otherwise {0, milliseconds to wait}
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+
+return {allowed, wait}
+`)
+
+// TakeToken
+// Takes a token from the bucket stored at key, rate is a number of tokens
+// added per second up to burst. If the bucket is empty returns the time to wait
+func (cr *CacheRepo) TakeToken(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	var res []int64
+	err := cr.call(func() error {
+		var err error
+		res, err = takeTokenScript.Run(ctx, cr.redcli, []string{key}, rate, burst).Int64Slice()
+		return err
+	})
+	if err != nil {
+		return false, 0, err
+	}
+
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,180 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"go.uber.org/zap"
+	"time"
+)
+
+// rollbackTimeout bounds the rollback, it runs even if the request is cancelled
+const rollbackTimeout = 5 * time.Second
+
+const (
+	uniqueViolation = "23505"
+	// pairConstraint keeps feature-tag pairs of banners unique within the tenant and environment
+	pairConstraint = "banners_tags_pair_key"
+	// defaultConstraint keeps one default banner per feature within the tenant and environment
+	defaultConstraint = "banners_default_key"
+)
+
+// quotaLockClass is the class of postgres advisory locks taken per tenant while banners are added
+const quotaLockClass int32 = 1
+
+// querier is implemented by both pool and transaction,
+// so statements can be run inside or outside a transaction
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// inTx
+// Runs f in a transaction. The transaction is rolled back if f returns an error,
+// panics or ctx is cancelled before commit, so a client disconnect never leaves
+// a half-applied change
+func (br *BannerRepository) inTx(ctx context.Context, f func(tx pgx.Tx) error) error {
+	return inTx(ctx, br.p, br.log(ctx), f)
+}
+
+func inTx(ctx context.Context, p *pgxpool.Pool, l *zap.SugaredLogger, f func(tx pgx.Tx) error) (err error) {
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if pm := recover(); pm != nil {
+			rollback(ctx, l, tx)
+			panic(pm)
+		} else if err != nil {
+			rollback(ctx, l, tx)
+		} else if err = ctx.Err(); err != nil {
+			l.Infof("transaction is rolled back: %v", err)
+			rollback(ctx, l, tx)
+		} else {
+			err = tx.Commit(ctx)
+		}
+	}()
+
+	return f(tx)
+}
+
+// rollback is not cancelled with the request, it may be cancelled already
+func rollback(ctx context.Context, l *zap.SugaredLogger, tx pgx.Tx) {
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	if err := tx.Rollback(rctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		l.Error(err)
+	}
+}
+
+// reserveBanners
+// Checks in the transaction that the tenant may have added more banners, 0 maxBanners is unlimited.
+// Transactions adding banners of the tenant take the tenant lock held until they end,
+// so two of them can't both pass the check on the same count
+func reserveBanners(ctx context.Context, tx pgx.Tx, tenant string, added int64, maxBanners int64) error {
+	if maxBanners <= 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, hashtext($2))", quotaLockClass, tenant); err != nil {
+		return err
+	}
+
+	count, err := countBanners(ctx, tx, tenant)
+	if err != nil {
+		return err
+	}
+
+	if count+added > maxBanners {
+		return serverr.NewBannersQuotaError(maxBanners)
+	}
+
+	return nil
+}
+
+// pairConflict
+// Converts violation of feature-tag pair or default banner uniqueness into conflict error naming
+// the banner owning the pair, banners are the ones written by the failed transaction.
+// Other errors are returned as is
+func (br *BannerRepository) pairConflict(ctx context.Context, err error, banners ...*models.BannerTagsModel) error {
+	var pgerr *pgconn.PgError
+	if !errors.As(err, &pgerr) || pgerr.Code != uniqueViolation {
+		return err
+	}
+
+	if pgerr.ConstraintName == defaultConstraint {
+		return br.defaultConflict(ctx, banners...)
+	}
+
+	if pgerr.ConstraintName != pairConstraint {
+		return err
+	}
+
+	for _, banner := range banners {
+		owner, ferr := br.FindPairOwner(ctx, banner.Tenant, banner.Environment, banner.FeatureId, banner.TagIds, banner.Id)
+		if ferr != nil {
+			br.log(ctx).Error(ferr)
+			break
+		}
+
+		if owner != nil {
+			return serverr.NewPairConflictError(owner.FeatureId, owner.TagId, owner.Id)
+		}
+	}
+
+	// the owner is not found, e.g. it was purged right after the failure
+	return serverr.NewConflictError("Пара feature_id-tag_id принадлежит другому баннеру")
+}
+
+// defaultConflict
+// Returns conflict error naming the default banner of the feature one of the banners clashes with
+func (br *BannerRepository) defaultConflict(ctx context.Context, banners ...*models.BannerTagsModel) error {
+	for _, banner := range banners {
+		if !banner.IsDefault {
+			continue
+		}
+
+		id, ferr := br.FindDefaultOwner(ctx, banner.Tenant, banner.Environment, banner.FeatureId, banner.Id)
+		if ferr != nil {
+			br.log(ctx).Error(ferr)
+			break
+		}
+
+		if id != 0 {
+			return serverr.NewDefaultConflictError(banner.FeatureId, id)
+		}
+	}
+
+	return serverr.NewConflictError("У фичи уже есть баннер по умолчанию")
+}
+
+// toApiError
+// Converts an error returned from transaction into api error
+func (br *BannerRepository) toApiError(ctx context.Context, err error) *serverr.ApiError {
+	return toApiError(br.log(ctx), err)
+}
+
+func toApiError(l *zap.SugaredLogger, err error) *serverr.ApiError {
+	var apierr *serverr.ApiError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &apierr):
+		return apierr
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		l.Info(err)
+		return serverr.RequestTimeoutError
+	default:
+		l.Error(err)
+		return serverr.StorageError
+	}
+}
@@ -0,0 +1,461 @@
+package service
+
+import (
+	"context"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+)
+
+const (
+	// BulkAtomic applies the batch in one transaction, nothing is applied if any item fails
+	BulkAtomic = "atomic"
+	// BulkBestEffort applies valid items one by one, failed items are reported and skipped
+	BulkBestEffort = "best_effort"
+)
+
+// CreateBanners
+// Creates the batch of banners in the environment of the tenant. Features and tags of the whole
+// batch are checked in one query, feature-tag pairs must be unique across the batch too
+func (bs *BannerService) CreateBanners(ctx context.Context, tenant string, env string, items []dto.BulkCreateItem, mode string) (*dto.BulkResponseDto, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.CreateBanners")
+	defer span.End()
+
+	resp := newBulkResponse(mode, len(items))
+	banners := make([]*models.BannerTagsModel, len(items))
+	for i, item := range items {
+		if item.Error != nil {
+			resp.Items[i].Err = item.Error
+			continue
+		}
+
+		banners[i] = item.Banner.ToModel()
+		banners[i].Tenant, banners[i].Environment = tenant, env
+	}
+
+	if apierr := bs.checkExternalKeys(ctx, tenant, env, banners, resp); apierr != nil {
+		return nil, apierr
+	}
+
+	if apierr := bs.checkBatch(ctx, tenant, banners, resp, true); apierr != nil {
+		return nil, apierr
+	}
+
+	if !canApplyBulk(resp) {
+		return finishBulk(resp), nil
+	}
+
+	var keys []string
+	for _, part := range bulkParts(mode, banners) {
+		ids, err := bs.br.CreateBanners(ctx, tenant, part.banners, bs.maxBanners(tenant))
+		if err != nil {
+			apierr := bs.storageError(ctx, err)
+			if mode == BulkAtomic {
+				return nil, apierr
+			}
+			resp.Items[part.index].Err = apierr
+			continue
+		}
+
+		for i, id := range ids {
+			banner := part.banners[i]
+			resp.Items[part.index+part.offsets[i]].BannerId = id
+			resp.Applied++
+			keys = append(keys, pairKeys(tenant, env, banner.FeatureId, banner.TagIds)...)
+		}
+	}
+
+	bs.invalidate(ctx, keys...)
+	bs.log(ctx).Infof("Bulk creation in %s [mode=%s]: %d of %d banners are created", env, mode, resp.Applied, len(items))
+
+	return finishBulk(resp), nil
+}
+
+// ChangeBanners
+// Applies the batch of changes to banners of the tenant, every changed banner gets a new version.
+// Banners are read and checked with a few queries for the whole batch
+func (bs *BannerService) ChangeBanners(ctx context.Context, tenant string, env string, items []dto.BulkChangeItem, mode string) (*dto.BulkResponseDto, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.ChangeBanners")
+	defer span.End()
+
+	resp := newBulkResponse(mode, len(items))
+
+	seen := make(map[int64]int)
+	var ids []int64
+	for i, item := range items {
+		if item.Error != nil {
+			resp.Items[i].Err = item.Error
+			continue
+		}
+
+		id := item.Change.BannerId
+		resp.Items[i].BannerId = id
+		if prev, ok := seen[id]; ok {
+			resp.Items[i].Err = serverr.NewInvalidRequestErrorf("banner_id повторяется в элементе %d", prev)
+			continue
+		}
+		seen[id] = i
+		ids = append(ids, id)
+	}
+
+	current, err := bs.br.GetBannersByIds(ctx, tenant, env, ids)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return nil, serverr.StorageError
+	}
+
+	byId := make(map[int64]models.BannerTagsModel, len(current))
+	for _, banner := range current {
+		byId[banner.Id] = banner
+	}
+
+	// banners are changed the same way the single PATCH does, absent fields are kept
+	banners := make([]*models.BannerTagsModel, len(items))
+	oldKeys := make([][]string, len(items))
+	for i, item := range items {
+		if resp.Items[i].Err != nil {
+			continue
+		}
+
+		banner, ok := byId[item.Change.BannerId]
+		if !ok {
+			resp.Items[i].Err = serverr.BannerNotFoundError
+			continue
+		}
+		oldKeys[i] = pairKeys(tenant, banner.Environment, banner.FeatureId, banner.TagIds)
+
+		chban := item.Change.ChangeBannerDto
+		if chban.FeatureId != nil {
+			banner.FeatureId = *chban.FeatureId
+		}
+		if len(chban.TagIds) != 0 {
+			banner.TagIds = chban.TagIds
+		}
+		if chban.Content != nil {
+			banner.Content = *chban.Content
+		}
+		if chban.Locales != nil {
+			banner.Locales = chban.Locales
+		}
+		if chban.IsActive != nil {
+			banner.IsActive = *chban.IsActive
+		}
+		if chban.Rule != nil {
+			banner.Rule = *chban.Rule
+		}
+		if chban.Priority != nil {
+			banner.Priority = *chban.Priority
+		}
+		if chban.IsDefault != nil {
+			banner.IsDefault = *chban.IsDefault
+		}
+		banners[i] = &banner
+	}
+
+	if apierr := bs.checkBatch(ctx, tenant, banners, resp, false); apierr != nil {
+		return nil, apierr
+	}
+
+	if !canApplyBulk(resp) {
+		return finishBulk(resp), nil
+	}
+
+	var keys []string
+	for _, part := range bulkParts(mode, banners) {
+		if err := bs.br.ChangeBanners(ctx, part.banners); err != nil {
+			apierr := bs.storageError(ctx, err)
+			if mode == BulkAtomic {
+				return nil, apierr
+			}
+			resp.Items[part.index].Err = apierr
+			continue
+		}
+
+		for i, banner := range part.banners {
+			resp.Applied++
+			keys = append(keys, oldKeys[part.index+part.offsets[i]]...)
+			keys = append(keys, pairKeys(tenant, banner.Environment, banner.FeatureId, banner.TagIds)...)
+		}
+	}
+
+	bs.invalidate(ctx, keys...)
+	bs.log(ctx).Infof("Bulk change [mode=%s]: %d of %d banners are updated", mode, resp.Applied, len(items))
+
+	return finishBulk(resp), nil
+}
+
+// checkBatch
+// Checks features, tags, quota, uniqueness of feature-tag pairs and default banners of the batch,
+// nil banners have failed already. The first item wins a pair or a default, so the items are
+// checked in order. A failed item is reported in resp and its banner is set to nil
+func (bs *BannerService) checkBatch(ctx context.Context, tenant string, banners []*models.BannerTagsModel, resp *dto.BulkResponseDto, created bool) *serverr.ApiError {
+	var featureIds, tagIds []int64
+	var pairs, defaultFeatures []models.BannerModel
+	for _, banner := range banners {
+		if banner == nil {
+			continue
+		}
+
+		featureIds = append(featureIds, banner.FeatureId)
+		if banner.IsDefault {
+			defaultFeatures = append(defaultFeatures, models.BannerModel{Environment: banner.Environment, FeatureId: banner.FeatureId})
+		}
+		tagIds = append(tagIds, banner.TagIds...)
+		for _, tagId := range banner.TagIds {
+			pairs = append(pairs, models.BannerModel{Environment: banner.Environment, FeatureId: banner.FeatureId, TagId: tagId})
+		}
+	}
+
+	if len(featureIds) == 0 {
+		return nil
+	}
+
+	features, tags, err := bs.br.GetExistingCatalogIds(ctx, tenant, featureIds, tagIds)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return serverr.StorageError
+	}
+
+	owned, err := bs.br.GetPairBanners(ctx, tenant, pairs)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return serverr.StorageError
+	}
+
+	owners := make(map[string][]int64, len(owned))
+	for _, pair := range owned {
+		key := cacheKey(tenant, pair.Environment, pair.FeatureId, pair.TagId)
+		owners[key] = append(owners[key], pair.Id)
+	}
+
+	var defaults []models.BannerModel
+	if len(defaultFeatures) > 0 {
+		if defaults, err = bs.br.GetDefaultBanners(ctx, tenant, defaultFeatures); err != nil {
+			bs.log(ctx).Error(err)
+			return serverr.StorageError
+		}
+	}
+
+	defaultOwners := make(map[string][]int64, len(defaults))
+	for _, banner := range defaults {
+		key := defaultKey(tenant, banner.Environment, banner.FeatureId)
+		defaultOwners[key] = append(defaultOwners[key], banner.Id)
+	}
+
+	// the quota is enforced again when the banners are created, the count here
+	// reports the items beyond it before anything is written
+	left := int64(-1)
+	if created {
+		var apierr *serverr.ApiError
+		if left, apierr = bs.bannersLeft(ctx, tenant); apierr != nil {
+			return apierr
+		}
+	}
+
+	taken := make(map[string]int)
+	takenDefaults := make(map[string]int)
+	for i, banner := range banners {
+		if banner == nil {
+			continue
+		}
+
+		if apierr := checkBatchItem(tenant, banner, features, tags, owners, taken); apierr != nil {
+			resp.Items[i].Err = apierr
+		} else if apierr := checkBatchDefault(tenant, banner, defaultOwners, takenDefaults); apierr != nil {
+			resp.Items[i].Err = apierr
+		} else if apierr := bs.checkContentQuota(tenant, banner.Content, banner.Locales); apierr != nil {
+			resp.Items[i].Err = apierr
+		} else if left == 0 {
+			resp.Items[i].Err = bs.bannersQuotaError(tenant)
+		}
+
+		if resp.Items[i].Err != nil {
+			banners[i] = nil
+			continue
+		}
+
+		for _, tagId := range banner.TagIds {
+			taken[cacheKey(tenant, banner.Environment, banner.FeatureId, tagId)] = i
+		}
+		if banner.IsDefault {
+			takenDefaults[defaultKey(tenant, banner.Environment, banner.FeatureId)] = i
+		}
+		if left > 0 {
+			left--
+		}
+	}
+
+	return nil
+}
+
+// checkExternalKeys
+// Checks that external keys of created banners are not used yet and don't repeat in the batch
+func (bs *BannerService) checkExternalKeys(ctx context.Context, tenant string, env string, banners []*models.BannerTagsModel, resp *dto.BulkResponseDto) *serverr.ApiError {
+	var keys []string
+	for _, banner := range banners {
+		if banner != nil && banner.ExternalKey != "" {
+			keys = append(keys, banner.ExternalKey)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	used, err := bs.br.GetUsedExternalKeys(ctx, tenant, env, keys)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return serverr.StorageError
+	}
+
+	seen := make(map[string]int)
+	for i, banner := range banners {
+		if banner == nil || banner.ExternalKey == "" {
+			continue
+		}
+
+		if prev, ok := seen[banner.ExternalKey]; ok {
+			resp.Items[i].Err = serverr.NewInvalidRequestErrorf("external_key повторяется в элементе %d", prev)
+		} else if used[banner.ExternalKey] {
+			resp.Items[i].Err = serverr.NewConflictError("Указанный external_key уже используется")
+		} else {
+			seen[banner.ExternalKey] = i
+			continue
+		}
+
+		banners[i] = nil
+	}
+
+	return nil
+}
+
+func checkBatchItem(tenant string, banner *models.BannerTagsModel, features map[int64]bool, tags map[int64]bool, owners map[string][]int64, taken map[string]int) *serverr.ApiError {
+	if !features[banner.FeatureId] {
+		return serverr.NewInvalidRequestError("Указанный feature_id не существует")
+	}
+
+	itemTags := make(map[int64]bool, len(banner.TagIds))
+	for _, tagId := range banner.TagIds {
+		if !tags[tagId] {
+			return serverr.NewInvalidRequestError("Все/некоторые tag_id не существуют")
+		}
+		if itemTags[tagId] {
+			return serverr.NewInvalidRequestErrorf("tag_id %d указан несколько раз", tagId)
+		}
+		itemTags[tagId] = true
+	}
+
+	for _, tagId := range banner.TagIds {
+		key := cacheKey(tenant, banner.Environment, banner.FeatureId, tagId)
+
+		// the changed banner doesn't clash with itself
+		for _, owner := range owners[key] {
+			if owner != banner.Id {
+				return serverr.NewPairConflictError(banner.FeatureId, tagId, owner)
+			}
+		}
+
+		if prev, ok := taken[key]; ok {
+			return serverr.NewInvalidRequestErrorf(
+				"Пара feature_id=%d tag_id=%d повторяется в элементе %d", banner.FeatureId, tagId, prev,
+			)
+		}
+	}
+
+	return nil
+}
+
+// checkBatchDefault
+// Checks that the feature has no other default banner, neither stored nor earlier in the batch
+func checkBatchDefault(tenant string, banner *models.BannerTagsModel, owners map[string][]int64, taken map[string]int) *serverr.ApiError {
+	if !banner.IsDefault {
+		return nil
+	}
+
+	key := defaultKey(tenant, banner.Environment, banner.FeatureId)
+
+	// the changed banner doesn't clash with itself
+	for _, owner := range owners[key] {
+		if owner != banner.Id {
+			return serverr.NewDefaultConflictError(banner.FeatureId, owner)
+		}
+	}
+
+	if prev, ok := taken[key]; ok {
+		return serverr.NewInvalidRequestErrorf(
+			"Баннер по умолчанию фичи %d повторяется в элементе %d", banner.FeatureId, prev,
+		)
+	}
+
+	return nil
+}
+
+// canApplyBulk
+// Reports whether anything is left to apply, an atomic batch is applied only if every item is valid
+func canApplyBulk(resp *dto.BulkResponseDto) bool {
+	valid := 0
+	for _, item := range resp.Items {
+		if item.Err == nil {
+			valid++
+		}
+	}
+
+	if resp.Mode == BulkAtomic {
+		return valid == len(resp.Items)
+	}
+
+	return valid > 0
+}
+
+// bulkPart
+// Banners written in one transaction, offsets are positions of the banners relative to index
+type bulkPart struct {
+	index   int
+	offsets []int
+	banners []*models.BannerTagsModel
+}
+
+// bulkParts
+// Splits valid banners into transactions: the whole batch in atomic mode, one per banner otherwise
+func bulkParts(mode string, banners []*models.BannerTagsModel) []bulkPart {
+	var parts []bulkPart
+	for i, banner := range banners {
+		if banner == nil {
+			continue
+		}
+
+		if mode == BulkAtomic && len(parts) > 0 {
+			parts[0].offsets = append(parts[0].offsets, i-parts[0].index)
+			parts[0].banners = append(parts[0].banners, banner)
+			continue
+		}
+
+		parts = append(parts, bulkPart{index: i, offsets: []int{0}, banners: []*models.BannerTagsModel{banner}})
+	}
+
+	return parts
+}
+
+func newBulkResponse(mode string, n int) *dto.BulkResponseDto {
+	resp := &dto.BulkResponseDto{
+		Mode:  mode,
+		Items: make([]dto.BulkItemResultDto, n),
+	}
+	for i := range resp.Items {
+		resp.Items[i].Index = i
+	}
+
+	return resp
+}
+
+// finishBulk counts failed items of the response
+func finishBulk(resp *dto.BulkResponseDto) *dto.BulkResponseDto {
+	for _, item := range resp.Items {
+		if item.Err != nil {
+			resp.Failed++
+		}
+	}
+
+	return resp
+}
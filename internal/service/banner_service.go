@@ -1,15 +1,25 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"github.com/jackc/pgx/v5"
 	"github.com/jasonlvhit/gocron"
 	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/metrics"
 	"github.com/mBayzigitov/dynamic-content-service/internal/models"
 	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/targeting"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util"
 	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
 	"go.uber.org/zap"
-	"log"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,91 +30,622 @@ type BannerService struct {
 	br    *repo.BannerRepository
 	redis *repo.CacheRepo
 	s     *gocron.Scheduler
+	cc    CacheConfig
+	ec    EnvironmentConfig
+	tc    TenantConfig
+	co    *Coalescer
+	lt    loadTimer
+	ready atomic.Bool
+
+	// background work, waited for on Stop
+	mu        sync.Mutex
+	bg        sync.WaitGroup
+	stopped   bool
+	schedStop chan struct{}
+	schedDone chan struct{}
 }
 
-func NewBannerService(br *repo.BannerRepository, redis *repo.CacheRepo) *BannerService {
-	loginst, _ := zap.NewDevelopment()
+func NewBannerService(br *repo.BannerRepository, redis *repo.CacheRepo, cc CacheConfig, ec EnvironmentConfig, tc TenantConfig, l *zap.SugaredLogger) *BannerService {
+	bs := &BannerService{
+		br:    br,
+		l:     l,
+		redis: redis,
+		cc:    cc,
+		ec:    ec,
+		tc:    tc,
+		co:    NewCoalescer(cc.LoadTimeout),
+	}
 
 	// create a new scheduler and start a sched task
 	// run every day at 3am to clean banners marked as to_delete
-	s := gocron.NewScheduler()
-	err := s.Every(1).Day().At("03:30").Do(br.DeleteMarkedBanners)
+	bs.s = gocron.NewScheduler()
+	err := bs.s.Every(1).Day().At("03:30").Do(func() {
+		bs.background(func() {
+			bs.purgeMarkedBanners(context.Background())
+		})
+	})
 	if err != nil {
-		log.Fatalln(err)
+		// the service still works, marked banners are just not purged
+		bs.l.Errorf("unable to schedule purge of marked banners: %v", err)
+	}
+
+	// run the scheduler in the background, it's stopped by closing schedStop
+	bs.schedStop = make(chan struct{})
+	bs.schedDone = make(chan struct{})
+	go bs.runScheduler()
+
+	return bs
+}
+
+// runScheduler
+// Runs pending jobs every second until schedStop is closed, schedDone is closed on return.
+// The scheduler is used by this goroutine only
+func (bs *BannerService) runScheduler() {
+	defer close(bs.schedDone)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			bs.s.RunPending()
+		case <-bs.schedStop:
+			return
+		}
+	}
+}
+
+func (bs *BannerService) log(ctx context.Context) *zap.SugaredLogger {
+	return logging.For(ctx, bs.l)
+}
+
+// storageError
+// Passes api errors returned by repository as is, e.g. pair conflicts, other errors are logged
+func (bs *BannerService) storageError(ctx context.Context, err error) *serverr.ApiError {
+	var apierr *serverr.ApiError
+	if errors.As(err, &apierr) {
+		return apierr
+	}
+
+	bs.log(ctx).Error(err)
+	return serverr.StorageError
+}
+
+// purgeMarkedBanners
+// Scheduled job deleting banners marked as to_delete
+func (bs *BannerService) purgeMarkedBanners(ctx context.Context) {
+	err := bs.br.DeleteMarkedBanners(ctx)
+	metrics.PurgeRuns.WithLabelValues(metrics.Outcome(err)).Inc()
+	if err == nil {
+		metrics.PurgeLastSuccess.SetToCurrentTime()
+	}
+}
+
+// background
+// Runs f in a goroutine that is waited for on Stop,
+// does nothing if the service is already stopped
+func (bs *BannerService) background(f func()) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bs.stopped {
+		return false
+	}
+
+	bs.bg.Add(1)
+	go func() {
+		defer bs.bg.Done()
+		// a failed job must not take the server down
+		defer func() {
+			if pv := recover(); pv != nil {
+				bs.l.Errorw("panic in background job", "panic", pv, "stack", string(debug.Stack()))
+			}
+		}()
+		f()
+	}()
+
+	return true
+}
+
+func (bs *BannerService) isStopped() bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	return bs.stopped
+}
+
+// Stop
+// Stops the scheduler and waits until background work (warm-up, cache
+// refreshes, purge of deleted banners) is finished or ctx is done
+func (bs *BannerService) Stop(ctx context.Context) error {
+	bs.mu.Lock()
+	if bs.stopped {
+		bs.mu.Unlock()
 		return nil
 	}
+	bs.stopped = true
+	bs.mu.Unlock()
+
+	// jobs started from now on do nothing, as the service is stopped
+	close(bs.schedStop)
 
-	// Start the scheduler in the background
+	done := make(chan struct{})
 	go func() {
-		<-s.Start()
+		<-bs.schedDone
+		bs.bg.Wait()
+		close(done)
 	}()
 
-	return &BannerService{
-		br:    br,
-		l:     loginst.Sugar(),
-		redis: redis,
-		s:     s,
+	select {
+	case <-done:
+		bs.log(ctx).Info("Background jobs are stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// BannerQuery
+// Describes the user a banner is resolved for
+type BannerQuery struct {
+	FeatureId       int64
+	TagIds          []int64
+	UseLastRevision bool
+	IsAdmin         bool
+	Locales         []string             // locales of content variants, see LocaleChain
+	Attributes      targeting.Attributes // attributes rules of banners are matched against
+	DryRun          bool                 // preview for admins, neither cache nor hits of pairs are changed
+}
+
+// GetBanner
+// Returns the banner of the tenant and environment for the feature and the tags of the user.
+// Of the banners mapped to the tags the one of the highest priority is served, of equal priorities
+// the one with the lowest id, the default banner of the feature is served if none of them is visible.
+// A banner is visible if its rule matches attributes of the user and it's active, inactive banners
+// are visible to admins too. Users get "banner not found" if nothing is visible.
+// Content is the variant of the first of the locales the banner has, see LocaleChain
+func (bs *BannerService) GetBanner(ctx context.Context, tenant string, env string, q BannerQuery) (models.BannerModel, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.GetBanner")
+	defer span.End()
+
+	res, apierr := bs.resolve(ctx, tenant, env, q, false)
+	if apierr != nil {
+		return models.BannerModel{}, apierr
+	}
+
+	if res.Banner.Id == 0 {
+		return models.BannerModel{}, serverr.BannerNotFoundError
 	}
+
+	return res.Banner, nil
 }
 
-func (bs *BannerService) GetBanner(tagId int64, featureId int64, useLastRevision bool) (models.BannerModel, *serverr.ApiError) {
+// PreviewBanner
+// Returns the banner a user that is not an admin would get, the current state of banners is used
+func (bs *BannerService) PreviewBanner(ctx context.Context, tenant string, env string, q BannerQuery) (models.BannerModel, *serverr.ApiError) {
+	q.UseLastRevision = true
+	q.IsAdmin = false
+	q.DryRun = true
+
+	return bs.GetBanner(ctx, tenant, env, q)
+}
+
+// ResolveBanner
+// Explains the choice among banners of the feature in env: returns the banner a user that is not
+// an admin would get, if any, with every banner considered and the reason it's chosen or excluded.
+// The feature and tags of the user are the ones of the banner unless q has them. The default banner is
+// listed even if a banner of the tags wins
+func (bs *BannerService) ResolveBanner(ctx context.Context, tenant string, env string, bannerId int64, q BannerQuery) (Resolution, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.ResolveBanner")
+	defer span.End()
+
+	banner, apierr := bs.br.GetBannerById(ctx, tenant, env, bannerId)
+	if apierr != nil {
+		return Resolution{}, apierr
+	}
+
+	if q.FeatureId == 0 {
+		q.FeatureId = banner.FeatureId
+	}
+	if len(q.TagIds) == 0 {
+		q.TagIds = banner.TagIds
+	}
+	q.UseLastRevision = true
+	q.IsAdmin = false
+	q.DryRun = true
+
+	return bs.resolve(ctx, tenant, env, q, true)
+}
+
+// Resolution
+// Banner chosen for the user, zero if there is none, and the banners considered
+type Resolution struct {
+	Banner     models.BannerModel
+	Candidates []models.ResolveCandidate
+}
+
+// resolve
+// Chooses the banner for the user, the default banner is looked up only if no banner of the tags
+// is visible unless explain is set
+func (bs *BannerService) resolve(ctx context.Context, tenant string, env string, q BannerQuery, explain bool) (Resolution, *serverr.ApiError) {
+	var res Resolution
+	chosen := -1 // index of the chosen candidate
+
+	for _, tagId := range q.TagIds {
+		key := cacheKey(tenant, env, q.FeatureId, tagId)
+		banner, apierr := bs.lookup(ctx, key, q.FeatureId, tagId, q.UseLastRevision || q.DryRun, q.Locales, bs.pairGetter(tenant, env, q.FeatureId, tagId))
+		if apierr == serverr.BannerNotFoundError {
+			res.Candidates = append(res.Candidates, models.ResolveCandidate{TagId: tagId, Outcome: models.OutcomeNoBanner})
+			continue
+		}
+		if apierr != nil {
+			return Resolution{}, apierr
+		}
+
+		// only pairs with a banner are counted, requests of missing pairs don't take places of warm-up
+		if !q.DryRun {
+			bs.countHit(ctx, key)
+		}
+
+		candidate := newCandidate(banner, bs.exclusion(ctx, banner, q))
+		res.Candidates = append(res.Candidates, candidate)
+		if candidate.Outcome != "" {
+			continue
+		}
+
+		if chosen < 0 || preferred(banner, res.Banner) {
+			res.Banner = banner
+			chosen = len(res.Candidates) - 1
+		}
+	}
+
+	if chosen >= 0 {
+		for i := range res.Candidates {
+			if res.Candidates[i].Outcome == "" {
+				res.Candidates[i].Outcome = models.OutcomeOutranked
+			}
+		}
+		res.Candidates[chosen].Outcome = models.OutcomeChosen
+
+		if !explain {
+			return res, nil
+		}
+	}
+
+	banner, apierr := bs.lookup(ctx, defaultKey(tenant, env, q.FeatureId), q.FeatureId, 0, q.UseLastRevision || q.DryRun, q.Locales, bs.defaultGetter(tenant, env, q.FeatureId))
+	if apierr == serverr.BannerNotFoundError {
+		return res, nil
+	}
+	if apierr != nil {
+		return Resolution{}, apierr
+	}
+
+	candidate := newCandidate(banner, bs.exclusion(ctx, banner, q))
+	candidate.IsDefault = true
+	switch {
+	case candidate.Outcome != "":
+	case chosen >= 0:
+		candidate.Outcome = models.OutcomeDefaultUnused
+	default:
+		// none of the tags has a banner the user can see
+		candidate.Outcome = models.OutcomeChosen
+		res.Banner = banner
+	}
+	res.Candidates = append(res.Candidates, candidate)
+
+	return res, nil
+}
+
+func newCandidate(banner models.BannerModel, outcome string) models.ResolveCandidate {
+	return models.ResolveCandidate{
+		BannerId:  banner.Id,
+		TagId:     banner.TagId,
+		Priority:  banner.Priority,
+		IsDefault: banner.IsDefault,
+		Rule:      banner.Rule,
+		Outcome:   outcome,
+	}
+}
+
+// exclusion
+// Returns the outcome excluding the banner from the ones served to the user, empty if it can be served
+func (bs *BannerService) exclusion(ctx context.Context, banner models.BannerModel, q BannerQuery) string {
+	if !banner.IsActive && !q.IsAdmin {
+		bs.log(ctx).Infof("Banner [%d] is inactive", banner.Id)
+		return models.OutcomeInactive
+	}
+
+	rule, err := targeting.Parse(banner.Rule)
+	if err != nil {
+		// rules are validated on save, so it's a banner written bypassing the api
+		bs.log(ctx).Errorf("Banner [%d] has invalid rule: %v", banner.Id, err)
+		return models.OutcomeInvalidRule
+	}
+
+	if !rule.Match(q.Attributes) {
+		bs.log(ctx).Infof("Banner [%d] doesn't target the user", banner.Id)
+		return models.OutcomeRuleMismatch
+	}
+
+	return ""
+}
+
+// preferred
+// Reports whether the banner is served instead of the other one, when both match tags of the user
+func preferred(banner models.BannerModel, other models.BannerModel) bool {
+	if banner.Priority != other.Priority {
+		return banner.Priority > other.Priority
+	}
+
+	return banner.Id < other.Id
+}
+
+// lookup
+// Returns the banner cached under the key, it's read from database with get
+// on a cache miss, if use_last_revision is set or redis is unavailable
+func (bs *BannerService) lookup(ctx context.Context, key string, featureId int64, tagId int64, useLastRevision bool, locales []string, get bannerGetter) (models.BannerModel, *serverr.ApiError) {
 	// check use_last_revision flag
 	// if TRUE -> get from database directly
 	// if FALSE -> try to get from redis cache, if fails -> get from database directly
-	key := fmt.Sprintf("%d_%d", featureId, tagId)
-
 	if !useLastRevision {
-		bc, err := bs.redis.Get(key)
+		entry, locale, ttl, err := bs.getCached(ctx, key, locales)
 
 		if err == nil {
-			resp := models.BannerModel{
-				Content: json.RawMessage(bc),
+			bs.log(ctx).Infof("get banner from cache with key '%s'", key)
+
+			// refresh the key in background before it expires,
+			// so that concurrent requests never see a miss
+			if ShouldRefreshEarly(ttl, bs.lt.value(), bs.cc.EarlyRefreshBeta) {
+				bs.log(ctx).Infof("early refresh of key '%s', ttl left: %s", key, ttl)
+				bs.background(func() {
+					bs.co.Load(context.WithoutCancel(ctx), key, bs.loader(key, get))
+				})
 			}
-			bs.l.Infof("get banner from cache with key '%s'", key)
 
-			return resp, nil // return if key in cache is present
-		} else {
+			if entry.NotFound {
+				metrics.CacheLookups.WithLabelValues(metrics.CacheNegative).Inc()
+				return models.BannerModel{}, serverr.BannerNotFoundError
+			}
+			metrics.CacheLookups.WithLabelValues(metrics.CacheHit).Inc()
+
+			banner := entry.toModel(featureId, tagId)
+			banner.Locale = locale
+
+			return banner, nil // return if key in cache is present
+		} else if errors.Is(err, repo.ErrCacheMiss) {
 			// just log if no such key found
-			bs.l.Infof("redis: no key '%s' in cache found", key)
+			metrics.CacheLookups.WithLabelValues(metrics.CacheMiss).Inc()
+			bs.log(ctx).Infof("redis: no key '%s' in cache found", key)
+		} else {
+			metrics.CacheLookups.WithLabelValues(metrics.CacheError).Inc()
+			// redis is unavailable, fall back to database
+			bs.log(ctx).Warnf("redis: unable to get key '%s': %v", key, err)
 		}
 	}
 
-	// get from database
-	banner, err := bs.br.GetBannerByTagAndFeature(
-		tagId,
-		featureId,
-	)
+	var banner models.BannerModel
+	var err error
+	if useLastRevision {
+		// the current state is read, it's neither shared with other lookups nor cached
+		banner, err = bs.readBanner(ctx, get)
+	} else {
+		// concurrent lookups of the same key share one query
+		var shared bool
+		banner, shared, err = bs.co.Load(ctx, key, bs.loader(key, get))
+		if shared {
+			bs.log(ctx).Infof("database lookup for key '%s' is shared", key)
+		}
+	}
 	if err != nil {
-		bs.l.Info(err.Error())
+		return models.BannerModel{}, bs.lookupError(ctx, err)
+	}
+
+	banner.TagId = tagId
+	banner.Content, banner.Locale = localize(banner.Content, banner.Locales, locales)
+
+	return banner, nil
+}
+
+// bannerGetter reads the banner cached under one key from database
+type bannerGetter func(ctx context.Context) (models.BannerModel, error)
+
+// pairGetter
+// Reads the banner of the feature-tag pair
+func (bs *BannerService) pairGetter(tenant string, env string, featureId int64, tagId int64) bannerGetter {
+	return func(ctx context.Context) (models.BannerModel, error) {
+		return bs.br.GetBannerByTagAndFeature(ctx, tenant, env, tagId, featureId)
+	}
+}
+
+// defaultGetter
+// Reads the default banner of the feature
+func (bs *BannerService) defaultGetter(tenant string, env string, featureId int64) bannerGetter {
+	return func(ctx context.Context) (models.BannerModel, error) {
+		return bs.br.GetDefaultBanner(ctx, tenant, env, featureId)
+	}
+}
+
+// lookupError
+// Converts an error of database lookup into api error
+func (bs *BannerService) lookupError(ctx context.Context, err error) *serverr.ApiError {
+	var apierr *serverr.ApiError
+
+	switch {
+	case errors.As(err, &apierr):
+		return apierr
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		bs.log(ctx).Infof("lookup is abandoned: %v", err)
+		return serverr.RequestTimeoutError
+	default:
+		return serverr.StorageError
+	}
+}
+
+// readBanner
+// Gets the banner from database, "banner not found" if there is none
+func (bs *BannerService) readBanner(ctx context.Context, get bannerGetter) (models.BannerModel, error) {
+	start := time.Now()
+	banner, err := get(ctx)
+	bs.lt.observe(time.Since(start))
+	if errors.Is(err, pgx.ErrNoRows) {
 		return models.BannerModel{}, serverr.BannerNotFoundError
 	}
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return models.BannerModel{}, err
+	}
 
 	if banner.Id == 0 {
-		bs.l.Info(serverr.BannerNotFoundError)
+		bs.log(ctx).Info(serverr.BannerNotFoundError)
 		return banner, serverr.BannerNotFoundError
 	}
 
-	if !useLastRevision {
-		err = bs.redis.Set(
-			key,
-			string(banner.Content),
-			RedisTtl,
-		)
+	return banner, nil
+}
+
+// loader
+// Returns the load of the key for Coalescer, it gets the banner from database and puts it into cache
+func (bs *BannerService) loader(key string, get bannerGetter) bannerGetter {
+	return func(ctx context.Context) (models.BannerModel, error) {
+		return bs.loadBanner(ctx, key, get)
+	}
+}
+
+// loadBanner
+// Gets the banner from database and puts it into cache,
+// must be called through Coalescer to coalesce lookups
+func (bs *BannerService) loadBanner(ctx context.Context, key string, get bannerGetter) (models.BannerModel, error) {
+	banner, err := bs.readBanner(ctx, get)
+	if err == serverr.BannerNotFoundError {
+		bs.cacheNotFound(ctx, key)
+		return models.BannerModel{}, err
+	}
+	if err != nil {
+		// transient failure, nothing is cached so the next request retries
+		return models.BannerModel{}, err
+	}
+
+	// cache is optional, the banner is served even if redis is unavailable
+	err = bs.setCached(ctx, key, newCacheEntries(banner), bs.cc.ttl())
+	if err != nil {
+		bs.log(ctx).Warnf("redis: unable to cache banner [%d]: %v", banner.Id, err)
+		return banner, nil
+	}
+
+	bs.log(ctx).Infof("Banner [%d] is cached, key: %s", banner.Id, key)
+
+	return banner, nil
+}
+
+// getCached
+// Returns the cached entry of the first of the locales the banner has together with the locale,
+// the entry of the default content and empty locale if the banner has none of them
+func (bs *BannerService) getCached(ctx context.Context, key string, locales []string) (CacheEntry, string, time.Duration, error) {
+	var entry CacheEntry
+
+	fields := append(slices.Clone(locales), defaultField)
+	values, ttl, err := bs.redis.GetFieldsWithTtl(ctx, key, fields...)
+	if err != nil {
+		return entry, "", 0, err
+	}
+
+	// variants are cached together with the default content, nothing is cached without it
+	if values[len(values)-1] == "" {
+		return entry, "", 0, repo.ErrCacheMiss
+	}
+
+	for i, raw := range values {
+		if raw == "" {
+			continue
+		}
+
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			return entry, "", 0, err
+		}
+
+		if fields[i] == defaultField {
+			return entry, "", ttl, nil
+		}
+
+		return entry, fields[i], ttl, nil
+	}
+
+	return entry, "", ttl, nil
+}
+
+// setCached
+// Replaces cached entries of the pair, entries are keyed by hash field
+func (bs *BannerService) setCached(ctx context.Context, key string, entries map[string]CacheEntry, ttl time.Duration) error {
+	fields := make(map[string]string, len(entries))
+	for field, entry := range entries {
+		raw, err := json.Marshal(entry)
 		if err != nil {
-			bs.l.Fatal(err)
+			return err
 		}
+		fields[field] = string(raw)
+	}
+
+	return bs.redis.SetFields(ctx, key, fields, ttl)
+}
 
-		bs.l.Infof("Banner [%d] is cached, key: %s", banner.Id, key)
+// cacheNotFound
+// Remembers for a short time that there is no banner for the key,
+// so that requests for missing pairs don't reach the database
+func (bs *BannerService) cacheNotFound(ctx context.Context, key string) {
+	if bs.cc.NegativeTtl <= 0 {
+		return
 	}
 
-	return banner, nil
+	if err := bs.setCached(ctx, key, map[string]CacheEntry{defaultField: {NotFound: true}}, bs.cc.NegativeTtl); err != nil {
+		bs.log(ctx).Error(err)
+		return
+	}
+
+	bs.log(ctx).Infof("Negative entry is cached, key: %s", key)
+}
+
+// bannerKeys
+// Returns cache keys of every feature-tag pair the banner is mapped to
+func (bs *BannerService) bannerKeys(ctx context.Context, tenant string, env string, bannerId int64) []string {
+	banner, apierr := bs.br.GetBannerById(ctx, tenant, env, bannerId)
+	if apierr != nil {
+		bs.log(ctx).Error(apierr)
+		return nil
+	}
+
+	return pairKeys(banner.Tenant, banner.Environment, banner.FeatureId, banner.TagIds)
 }
 
-func (bs *BannerService) CreateBanner(banner *models.BannerTagsModel) (int64, *serverr.ApiError) {
+// invalidate
+// Drops cached entries, negative entries included, so the next request sees the change
+func (bs *BannerService) invalidate(ctx context.Context, keys ...string) {
+	if err := bs.redis.Delete(ctx, keys...); err != nil {
+		bs.log(ctx).Error(err)
+	}
+}
+
+// CacheDegraded
+// Reports whether banners are served bypassing the cache
+func (bs *BannerService) CacheDegraded() bool {
+	return bs.redis.Degraded()
+}
+
+func (bs *BannerService) CreateBanner(ctx context.Context, tenant string, env string, banner *models.BannerTagsModel) (int64, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.CreateBanner")
+	defer span.End()
+
+	banner.Tenant = tenant
+	banner.Environment = env
+
+	if apierr := bs.checkContentQuota(tenant, banner.Content, banner.Locales); apierr != nil {
+		return -1, apierr
+	}
+
 	// check if feature is present
-	featExists, err := bs.br.DoesFeatureExist(banner.FeatureId)
+	featExists, err := bs.br.DoesFeatureExist(ctx, tenant, banner.FeatureId)
 	if err != nil {
-		bs.l.Error(err.Error())
+		bs.log(ctx).Error(err.Error())
 		return -1, serverr.StorageError
 	}
 
@@ -113,9 +654,9 @@ func (bs *BannerService) CreateBanner(banner *models.BannerTagsModel) (int64, *s
 	}
 
 	// check if tags are present
-	tagsExist, err := bs.br.DoTagsExist(banner.TagIds)
+	tagsExist, err := bs.br.DoTagsExist(ctx, tenant, banner.TagIds)
 	if err != nil {
-		bs.l.Error(err.Error())
+		bs.log(ctx).Error(err.Error())
 		return -1, serverr.StorageError
 	}
 
@@ -123,38 +664,129 @@ func (bs *BannerService) CreateBanner(banner *models.BannerTagsModel) (int64, *s
 		return -1, serverr.NewInvalidRequestError("Все/некоторые tag_id не существуют")
 	}
 
-	duplicates, err := bs.br.CheckIfDuplicates(banner.FeatureId, banner.TagIds)
+	owner, err := bs.br.FindPairOwner(ctx, tenant, env, banner.FeatureId, banner.TagIds, 0)
 	if err != nil {
-		bs.l.Error(err.Error())
+		bs.log(ctx).Error(err.Error())
 		return -1, serverr.StorageError
 	}
 
-	if duplicates {
-		bs.l.Info("duplicates error")
-		return -1, serverr.NewInvalidRequestError("Указаны дублирующиеся feature_id-tag_id")
+	if owner != nil {
+		bs.log(ctx).Info("duplicates error")
+		return -1, serverr.NewPairConflictError(owner.FeatureId, owner.TagId, owner.Id)
+	}
+
+	if banner.IsDefault {
+		defaultId, err := bs.br.FindDefaultOwner(ctx, tenant, env, banner.FeatureId, 0)
+		if err != nil {
+			bs.log(ctx).Error(err.Error())
+			return -1, serverr.StorageError
+		}
+
+		if defaultId != 0 {
+			return -1, serverr.NewDefaultConflictError(banner.FeatureId, defaultId)
+		}
+	}
+
+	if banner.ExternalKey != "" {
+		existingId, _, err := bs.br.GetBannerByExternalKey(ctx, tenant, env, banner.ExternalKey)
+		if err != nil {
+			bs.log(ctx).Error(err.Error())
+			return -1, serverr.StorageError
+		}
+
+		if existingId != 0 {
+			return -1, serverr.NewConflictError("Указанный external_key уже используется")
+		}
 	}
 
-	createdId, err := bs.br.CreateBanner(banner)
+	createdId, err := bs.br.CreateBanner(ctx, banner, bs.maxBanners(tenant))
 	if err != nil {
-		bs.l.Error(err.Error())
-		return -1, serverr.StorageError
+		return -1, bs.storageError(ctx, err)
 	}
 
+	bs.invalidate(ctx, pairKeys(tenant, env, banner.FeatureId, banner.TagIds)...)
+
 	return createdId, nil
 }
 
-func (bs *BannerService) DeleteBanner(bannerId int64) *serverr.ApiError {
-	return bs.br.DeleteBanner(bannerId)
+func (bs *BannerService) DeleteBanner(ctx context.Context, tenant string, env string, bannerId int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerService.DeleteBanner")
+	defer span.End()
+
+	if apierr := bs.br.DeleteBanner(ctx, tenant, env, bannerId); apierr != nil {
+		return apierr
+	}
+
+	bs.invalidate(ctx, bs.bannerKeys(ctx, tenant, env, bannerId)...)
+
+	return nil
 }
 
-func (bs *BannerService) ChangeBanner(bannerId int64, chban dto.ChangeBannerDto) *serverr.ApiError {
-	return bs.br.ChangeBannerByRequest(bannerId, chban)
+// RestoreBanner
+// Restores the deleted banner, pairs are invalidated because
+// "not found" entries may be cached for them. The restored banner counts against the quota
+func (bs *BannerService) RestoreBanner(ctx context.Context, tenant string, env string, bannerId int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerService.RestoreBanner")
+	defer span.End()
+
+	if apierr := bs.br.RestoreBanner(ctx, tenant, env, bannerId, bs.maxBanners(tenant)); apierr != nil {
+		return apierr
+	}
+
+	bs.invalidate(ctx, bs.bannerKeys(ctx, tenant, env, bannerId)...)
+
+	return nil
 }
 
-func (bs *BannerService) GetBannersByFilter(featureId int64, tagId int64, limit int64, offset int64) ([]dto.FilterBannersResponseDto, *serverr.ApiError) {
-	list, err := bs.br.GetBannersByFilter(featureId, tagId, limit, offset)
+// GetBannerById
+// Returns the banner with its tags, deleted banners are returned too
+func (bs *BannerService) GetBannerById(ctx context.Context, tenant string, env string, bannerId int64) (*dto.FilterBannersResponseDto, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.GetBannerById")
+	defer span.End()
+
+	banner, apierr := bs.br.GetBannerById(ctx, tenant, env, bannerId)
+	if apierr != nil {
+		return nil, apierr
+	}
+
+	resp := dto.NewFilterBannersResponseDto(*banner)
+
+	return &resp, nil
+}
+
+func (bs *BannerService) ChangeBanner(ctx context.Context, tenant string, env string, bannerId int64, chban dto.ChangeBannerDto) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerService.ChangeBanner")
+	defer span.End()
+
+	if chban.Content != nil || chban.Locales != nil {
+		var content json.RawMessage
+		if chban.Content != nil {
+			content = *chban.Content
+		}
+
+		if apierr := bs.checkQuota(ctx, tenant, 0, content, chban.Locales); apierr != nil {
+			return apierr
+		}
+	}
+
+	// pairs before and after the change are invalidated
+	keys := bs.bannerKeys(ctx, tenant, env, bannerId)
+	if apierr := bs.br.ChangeBannerByRequest(ctx, tenant, env, bannerId, chban); apierr != nil {
+		return apierr
+	}
+
+	bs.invalidate(ctx, append(keys, bs.bannerKeys(ctx, tenant, env, bannerId)...)...)
+
+	return nil
+}
+
+func (bs *BannerService) GetBannersByFilter(ctx context.Context, tenant string, env string, featureId int64, tagId int64, limit int64, offset int64) ([]dto.FilterBannersResponseDto, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.GetBannersByFilter")
+	defer span.End()
+
+	list, err := bs.br.GetBannersByFilter(ctx, tenant, env, featureId, tagId, limit, offset)
 	if err != nil {
-		bs.l.Info(err)
+		bs.log(ctx).Info(err)
 		return nil, err
 	}
 
@@ -166,14 +798,106 @@ func (bs *BannerService) GetBannersByFilter(featureId int64, tagId int64, limit
 	return resp, nil
 }
 
-func (bs *BannerService) DeleteByFeatureOrTagId(featureId int64, tagId int64) *serverr.ApiError {
-	return bs.br.DeleteBannersByTagOrFeatureId(featureId, tagId)
+func (bs *BannerService) DeleteByFeatureOrTagId(ctx context.Context, tenant string, env string, featureId int64, tagId int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerService.DeleteByFeatureOrTagId")
+	defer span.End()
+
+	pairs, err := bs.br.GetPairsByFeatureOrTag(ctx, tenant, env, featureId, tagId)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return serverr.StorageError
+	}
+
+	if apierr := bs.br.DeleteBannersByTagOrFeatureId(ctx, tenant, env, featureId, tagId); apierr != nil {
+		return apierr
+	}
+
+	// drop deleted banners from cache and fill it again in background
+	keys := make([]string, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = cacheKey(pair.Tenant, pair.Environment, pair.FeatureId, pair.TagId)
+	}
+	for _, pair := range pairs {
+		if key := defaultKey(pair.Tenant, pair.Environment, pair.FeatureId); !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	bs.invalidate(ctx, keys...)
+
+	if bs.cc.WarmUp.Enabled {
+		bs.background(func() {
+			bs.WarmUp(context.WithoutCancel(ctx))
+		})
+	}
+
+	return nil
+}
+
+func (bs *BannerService) GetVersions(ctx context.Context, tenant string, env string, bannerId int64) ([]models.BannerVersion, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.GetVersions")
+	defer span.End()
+
+	return bs.br.GetBannerVersions(ctx, tenant, env, bannerId)
 }
 
-func (bs *BannerService) GetVersions(bannerId int64) ([]models.BannerVersion, *serverr.ApiError) {
-	return bs.br.GetBannerVersions(bannerId)
+// PreviewVersion
+// Returns content of the version of the banner in the first of the locales it has, the current
+// version if version is 0. Inactive and deleted banners are previewed too, activity isn't versioned,
+// so it's the current one of the banner
+func (bs *BannerService) PreviewVersion(ctx context.Context, tenant string, env string, bannerId int64, version int64, locales []string) (*dto.VersionPreviewResponseDto, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.PreviewVersion")
+	defer span.End()
+
+	banner, apierr := bs.br.GetBannerById(ctx, tenant, env, bannerId)
+	if apierr != nil {
+		return nil, apierr
+	}
+
+	current := banner.LastRevision
+	if version != 0 && version != current {
+		versions, apierr := bs.br.GetBannerVersions(ctx, tenant, env, bannerId)
+		if apierr != nil {
+			return nil, apierr
+		}
+
+		i := slices.IndexFunc(versions, func(v models.BannerVersion) bool { return v.Version == version })
+		if i < 0 {
+			return nil, serverr.BannerNotFoundError
+		}
+
+		tagIds, err := util.StringToIntSlice(versions[i].Tags)
+		if err != nil {
+			bs.log(ctx).Error(err)
+			return nil, serverr.StorageError
+		}
+
+		banner.LastRevision = version
+		banner.FeatureId = versions[i].FeatureId
+		banner.TagIds = tagIds
+		banner.Content = versions[i].Content
+		banner.Locales = versions[i].Locales
+		banner.Rule = versions[i].Rule
+	}
+
+	content, locale := localize(banner.Content, banner.Locales, locales)
+	resp := dto.NewVersionPreviewResponse(*banner, content, locale)
+	resp.Current = banner.LastRevision == current
+
+	return &resp, nil
 }
 
-func (bs *BannerService) SetVersion(bannerId int64, versionId int64) *serverr.ApiError {
-	return bs.br.SetBannerVersion(bannerId, versionId)
-}
\ No newline at end of file
+func (bs *BannerService) SetVersion(ctx context.Context, tenant string, env string, bannerId int64, versionId int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerService.SetVersion")
+	defer span.End()
+
+	keys := bs.bannerKeys(ctx, tenant, env, bannerId)
+	if apierr := bs.br.SetBannerVersion(ctx, tenant, env, bannerId, versionId); apierr != nil {
+		metrics.VersionRollbacks.WithLabelValues(metrics.OutcomeFailure).Inc()
+		return apierr
+	}
+	metrics.VersionRollbacks.WithLabelValues(metrics.OutcomeSuccess).Inc()
+
+	bs.invalidate(ctx, append(keys, bs.bannerKeys(ctx, tenant, env, bannerId)...)...)
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+)
+
+const (
+	// ImportCreate creates every line, lines with a known external key fail
+	ImportCreate = "create"
+	// ImportUpsert rewrites the banner with the same external key, other lines are created
+	ImportUpsert = "upsert"
+
+	importActionCreate = "create"
+	importActionUpdate = "update"
+)
+
+// ExportBanners
+// Passes banners of the tenant and environment that are not deleted to emit one by one,
+// so the export isn't held in memory
+func (bs *BannerService) ExportBanners(ctx context.Context, tenant string, env string, featureId int64, withVersions bool, emit func(dto.BannerLineDto) error) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "BannerService.ExportBanners")
+	defer span.End()
+
+	err := bs.br.ExportBanners(ctx, tenant, env, featureId, withVersions, func(banner models.BannerTagsModel, versions []models.BannerVersion) error {
+		return emit(dto.NewBannerLine(banner, versions))
+	})
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return serverr.StorageError
+	}
+
+	return nil
+}
+
+// ImportBanners
+// Validates and applies lines to the environment of the tenant one by one, a failed line doesn't
+// stop the import. Lines are checked against each other and the quota too, so a dry run reports
+// the same errors the real import would
+func (bs *BannerService) ImportBanners(ctx context.Context, tenant string, env string, lines []dto.ImportLine, mode string, dryRun bool) *dto.ImportBannersResponseDto {
+	ctx, span := tracing.Start(ctx, "BannerService.ImportBanners")
+	defer span.End()
+
+	imp := bannerImport{
+		bs:       bs,
+		tenant:   tenant,
+		env:      env,
+		mode:     mode,
+		dryRun:   dryRun,
+		pairs:    make(map[string]int),
+		keys:     make(map[string]int),
+		defaults: make(map[string]int),
+	}
+
+	resp := &dto.ImportBannersResponseDto{
+		DryRun: dryRun,
+		Lines:  make([]dto.ImportLineResultDto, 0, len(lines)),
+	}
+
+	for _, line := range lines {
+		res := dto.ImportLineResultDto{
+			Line:        line.Number,
+			ExternalKey: line.Banner.ExternalKey,
+		}
+
+		if line.Error != nil {
+			res.Err = line.Error
+		} else if apierr := imp.apply(ctx, line, &res); apierr != nil {
+			res.Err = apierr
+		}
+
+		switch {
+		case res.Err != nil:
+			res.Action = ""
+			resp.Failed++
+		case res.Action == importActionCreate:
+			resp.Created++
+		default:
+			resp.Updated++
+		}
+
+		resp.Lines = append(resp.Lines, res)
+	}
+
+	bs.log(ctx).Infof(
+		"Banners import to %s of %s is done [dry_run=%t, mode=%s]: created=%d, updated=%d, failed=%d",
+		env, tenant, dryRun, mode, resp.Created, resp.Updated, resp.Failed,
+	)
+
+	return resp
+}
+
+// bannerImport
+// State of a single import, remembers pairs, keys and default banners of the lines already accepted
+type bannerImport struct {
+	bs       *BannerService
+	tenant   string
+	env      string
+	mode     string
+	dryRun   bool
+	pairs    map[string]int // cache key of feature-tag pair -> line
+	keys     map[string]int // external key -> line
+	defaults map[string]int // default key of feature -> line
+	created  int64          // banners created by the lines already accepted
+}
+
+func (imp *bannerImport) apply(ctx context.Context, line dto.ImportLine, res *dto.ImportLineResultDto) *serverr.ApiError {
+	bs := imp.bs
+	banner := line.Banner
+
+	if prev, ok := imp.keys[banner.ExternalKey]; ok {
+		return serverr.NewInvalidRequestErrorf("external_key повторяется в строке %d", prev)
+	}
+
+	for _, tagId := range banner.TagIds {
+		if prev, ok := imp.pairs[cacheKey(imp.tenant, imp.env, banner.FeatureId, tagId)]; ok {
+			return serverr.NewInvalidRequestErrorf(
+				"Пара feature_id=%d tag_id=%d повторяется в строке %d", banner.FeatureId, tagId, prev,
+			)
+		}
+	}
+
+	if prev, ok := imp.defaults[defaultKey(imp.tenant, imp.env, banner.FeatureId)]; ok && banner.IsDefault {
+		return serverr.NewInvalidRequestErrorf(
+			"Баннер по умолчанию фичи %d повторяется в строке %d", banner.FeatureId, prev,
+		)
+	}
+
+	featExists, err := bs.br.DoesFeatureExist(ctx, imp.tenant, banner.FeatureId)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return serverr.StorageError
+	}
+
+	if !featExists {
+		return serverr.NewInvalidRequestError("Указанный feature_id не существует")
+	}
+
+	tagsExist, err := bs.br.DoTagsExist(ctx, imp.tenant, banner.TagIds)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return serverr.StorageError
+	}
+
+	if !tagsExist {
+		return serverr.NewInvalidRequestError("Все/некоторые tag_id не существуют")
+	}
+
+	// banner with the same key is rewritten in upsert mode
+	var existingId int64
+	if banner.ExternalKey != "" {
+		var toDelete bool
+		existingId, toDelete, err = bs.br.GetBannerByExternalKey(ctx, imp.tenant, imp.env, banner.ExternalKey)
+		if err != nil {
+			bs.log(ctx).Error(err)
+			return serverr.StorageError
+		}
+
+		if existingId != 0 && imp.mode != ImportUpsert {
+			return serverr.NewConflictError("Указанный external_key уже используется")
+		}
+
+		if toDelete {
+			return serverr.NewInvalidRequestError("Баннер с указанным external_key удалён")
+		}
+	}
+
+	owner, err := bs.br.FindPairOwner(ctx, imp.tenant, imp.env, banner.FeatureId, banner.TagIds, existingId)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return serverr.StorageError
+	}
+
+	if owner != nil {
+		return serverr.NewPairConflictError(owner.FeatureId, owner.TagId, owner.Id)
+	}
+
+	if banner.IsDefault {
+		defaultId, err := bs.br.FindDefaultOwner(ctx, imp.tenant, imp.env, banner.FeatureId, existingId)
+		if err != nil {
+			bs.log(ctx).Error(err)
+			return serverr.StorageError
+		}
+
+		if defaultId != 0 {
+			return serverr.NewDefaultConflictError(banner.FeatureId, defaultId)
+		}
+	}
+
+	// banners created by a dry run are not stored, so they are counted here
+	var added int64
+	if existingId != 0 {
+		res.Action, res.BannerId = importActionUpdate, existingId
+	} else {
+		res.Action, added = importActionCreate, 1
+		if imp.dryRun {
+			added += imp.created
+		}
+	}
+
+	if apierr := bs.checkQuota(ctx, imp.tenant, added, banner.Content, banner.Locales); apierr != nil {
+		return apierr
+	}
+
+	if !imp.dryRun {
+		if apierr := imp.write(ctx, banner, res); apierr != nil {
+			return apierr
+		}
+	}
+
+	if res.Action == importActionCreate {
+		imp.created++
+	}
+	if banner.ExternalKey != "" {
+		imp.keys[banner.ExternalKey] = line.Number
+	}
+	for _, tagId := range banner.TagIds {
+		imp.pairs[cacheKey(imp.tenant, imp.env, banner.FeatureId, tagId)] = line.Number
+	}
+	if banner.IsDefault {
+		imp.defaults[defaultKey(imp.tenant, imp.env, banner.FeatureId)] = line.Number
+	}
+
+	return nil
+}
+
+func (imp *bannerImport) write(ctx context.Context, banner dto.BannerLineDto, res *dto.ImportLineResultDto) *serverr.ApiError {
+	bs := imp.bs
+
+	if res.Action == importActionUpdate {
+		return bs.ChangeBanner(ctx, imp.tenant, imp.env, res.BannerId, dto.ChangeBannerDto{
+			TagIds:    banner.TagIds,
+			FeatureId: &banner.FeatureId,
+			Content:   &banner.Content,
+			Locales:   allLocales(banner.Locales),
+			Rule:      &banner.Rule,
+			IsActive:  &banner.IsActive,
+			Priority:  &banner.Priority,
+			IsDefault: &banner.IsDefault,
+		})
+	}
+
+	model := banner.ToModel()
+	model.Tenant = imp.tenant
+	model.Environment = imp.env
+
+	createdId, err := bs.br.CreateBanner(ctx, model, bs.maxBanners(imp.tenant))
+	if err != nil {
+		return bs.storageError(ctx, err)
+	}
+	res.BannerId = createdId
+
+	bs.invalidate(ctx, pairKeys(imp.tenant, imp.env, banner.FeatureId, banner.TagIds)...)
+
+	return nil
+}
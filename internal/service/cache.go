@@ -0,0 +1,147 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+type CacheConfig struct {
+	// Ttl of a cached banner, RedisTtl is used if not set
+	Ttl time.Duration `toml:"ttl"`
+	// EarlyRefreshBeta scales the probability of refreshing a key before
+	// it expires, 0 disables early refresh, 1 is a reasonable default
+	EarlyRefreshBeta float64 `toml:"early_refresh_beta"`
+	// LoadTimeout bounds a database load shared by concurrent lookups, DefaultLoadTimeout is used if not set
+	LoadTimeout time.Duration `toml:"load_timeout"`
+	// NegativeTtl of a "banner not found" entry, 0 disables negative caching
+	NegativeTtl time.Duration `toml:"negative_ttl"`
+	// WarmUp preloads banners into cache at startup and after bulk changes
+	WarmUp WarmUpConfig `toml:"warm_up"`
+}
+
+// defaultField is the hash field of the default content, it can't clash with a locale
+const defaultField = "default"
+
+// CacheEntry
+// Value stored in redis for a feature-tag pair. NotFound marks a negative
+// entry, that is stored when there is no banner for the pair. Pair is cached
+// as a hash with an entry per content variant, keyed by locale
+type CacheEntry struct {
+	BannerId int64           `json:"banner_id,omitempty"`
+	Revision int64           `json:"revision,omitempty"`
+	IsActive bool            `json:"is_active"`
+	Priority int             `json:"priority,omitempty"`
+	Rule     string          `json:"rule,omitempty"`
+	Content  json.RawMessage `json:"content,omitempty"`
+	NotFound bool            `json:"not_found,omitempty"`
+}
+
+func newCacheEntry(banner models.BannerModel) CacheEntry {
+	return CacheEntry{
+		BannerId: banner.Id,
+		Revision: banner.LastRevision,
+		IsActive: banner.IsActive,
+		Priority: banner.Priority,
+		Rule:     banner.Rule,
+		Content:  banner.Content,
+	}
+}
+
+// newCacheEntries
+// Returns entries of the default content and of every content variant of the banner by hash field
+func newCacheEntries(banner models.BannerModel) map[string]CacheEntry {
+	entries := map[string]CacheEntry{defaultField: newCacheEntry(banner)}
+	for locale, variant := range banner.Locales {
+		entry := newCacheEntry(banner)
+		entry.Content = variant
+		entries[locale] = entry
+	}
+
+	return entries
+}
+
+func (ce CacheEntry) toModel(featureId int64, tagId int64) models.BannerModel {
+	return models.BannerModel{
+		Id:           ce.BannerId,
+		TagId:        tagId,
+		FeatureId:    featureId,
+		Content:      ce.Content,
+		IsActive:     ce.IsActive,
+		Priority:     ce.Priority,
+		Rule:         ce.Rule,
+		LastRevision: ce.Revision,
+	}
+}
+
+// cacheKey
+// Key of the feature-tag pair, tenants and environments don't share cached banners
+func cacheKey(tenant string, env string, featureId int64, tagId int64) string {
+	return fmt.Sprintf("%s:%s:%d_%d", tenant, env, featureId, tagId)
+}
+
+// defaultKey
+// Key of the default banner of the feature
+func defaultKey(tenant string, env string, featureId int64) string {
+	return fmt.Sprintf("%s:%s:%d_default", tenant, env, featureId)
+}
+
+// pairKeys
+// Keys of the feature-tag pairs and of the default banner of the feature,
+// the changed banner may be or may have been the default one
+func pairKeys(tenant string, env string, featureId int64, tagIds []int64) []string {
+	keys := make([]string, len(tagIds), len(tagIds)+1)
+	for i, tagId := range tagIds {
+		keys[i] = cacheKey(tenant, env, featureId, tagId)
+	}
+
+	return append(keys, defaultKey(tenant, env, featureId))
+}
+
+func (cc CacheConfig) ttl() time.Duration {
+	if cc.Ttl <= 0 {
+		return RedisTtl
+	}
+
+	return cc.Ttl
+}
+
+// loadTimer
+// Keeps a moving average of database lookup duration, it is used
+// as the expected recomputation time in the early refresh formula
+type loadTimer struct {
+	avg atomic.Int64
+}
+
+func (lt *loadTimer) observe(d time.Duration) {
+	prev := lt.avg.Load()
+	if prev == 0 {
+		lt.avg.Store(int64(d))
+		return
+	}
+
+	// exponentially weighted, the last lookup contributes 20%
+	lt.avg.Store((prev*4 + int64(d)) / 5)
+}
+
+func (lt *loadTimer) value() time.Duration {
+	return time.Duration(lt.avg.Load())
+}
+
+// ShouldRefreshEarly
+// Probabilistic early expiration (XFetch): the closer the key is to its
+// expiry and the slower the database lookup, the more likely a single
+// request refreshes the key before it expires for everyone
+func ShouldRefreshEarly(remaining time.Duration, delta time.Duration, beta float64) bool {
+	if beta <= 0 || delta <= 0 || remaining <= 0 {
+		return false
+	}
+
+	gap := -float64(delta) * beta * math.Log(1-rand.Float64())
+
+	return gap >= float64(remaining)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+)
+
+// CatalogService
+// Manages features and tags of tenants. They are not cached, cached banners refer to them by id only
+type CatalogService struct {
+	cr *repo.CatalogRepository
+}
+
+func NewCatalogService(cr *repo.CatalogRepository) *CatalogService {
+	return &CatalogService{
+		cr: cr,
+	}
+}
+
+func (cs *CatalogService) GetFeatures(ctx context.Context, tenant string, limit int64, offset int64) ([]models.CatalogItem, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "CatalogService.GetFeatures")
+	defer span.End()
+
+	return cs.cr.GetFeatures(ctx, tenant, limit, offset)
+}
+
+func (cs *CatalogService) CreateFeature(ctx context.Context, tenant string, name string) (int64, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "CatalogService.CreateFeature")
+	defer span.End()
+
+	return cs.cr.CreateFeature(ctx, tenant, name)
+}
+
+func (cs *CatalogService) DeleteFeature(ctx context.Context, tenant string, id int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "CatalogService.DeleteFeature")
+	defer span.End()
+
+	return cs.cr.DeleteFeature(ctx, tenant, id)
+}
+
+func (cs *CatalogService) GetTags(ctx context.Context, tenant string, limit int64, offset int64) ([]models.CatalogItem, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "CatalogService.GetTags")
+	defer span.End()
+
+	return cs.cr.GetTags(ctx, tenant, limit, offset)
+}
+
+func (cs *CatalogService) CreateTag(ctx context.Context, tenant string, name string) (int64, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "CatalogService.CreateTag")
+	defer span.End()
+
+	return cs.cr.CreateTag(ctx, tenant, name)
+}
+
+func (cs *CatalogService) DeleteTag(ctx context.Context, tenant string, id int64) *serverr.ApiError {
+	ctx, span := tracing.Start(ctx, "CatalogService.DeleteTag")
+	defer span.End()
+
+	return cs.cr.DeleteTag(ctx, tenant, id)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"golang.org/x/sync/singleflight"
+	"time"
+)
+
+// DefaultLoadTimeout bounds a shared database load if CacheConfig.LoadTimeout is not set
+const DefaultLoadTimeout = 5 * time.Second
+
+// Coalescer
+// Shares one database load between concurrent lookups of the same key. The load is not
+// cancelled when the request that started it is done, other requests may wait for it,
+// so it's bounded by its own timeout instead
+type Coalescer struct {
+	sf      singleflight.Group
+	timeout time.Duration
+}
+
+func NewCoalescer(timeout time.Duration) *Coalescer {
+	if timeout <= 0 {
+		timeout = DefaultLoadTimeout
+	}
+
+	return &Coalescer{timeout: timeout}
+}
+
+// Load
+// Runs load once for concurrent calls with the key and reports whether the result is shared.
+// The caller stops waiting and gets ctx error once ctx is done, the load goes on for the others
+func (c *Coalescer) Load(ctx context.Context, key string, load func(ctx context.Context) (models.BannerModel, error)) (models.BannerModel, bool, error) {
+	ch := c.sf.DoChan(key, func() (interface{}, error) {
+		lctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		defer cancel()
+
+		return load(lctx)
+	})
+
+	select {
+	case res := <-ch:
+		banner, _ := res.Val.(models.BannerModel)
+		return banner, res.Shared, res.Err
+	case <-ctx.Done():
+		return models.BannerModel{}, false, ctx.Err()
+	}
+}
@@ -12,14 +12,14 @@ func TokenValidationMiddleware(next http.Handler) http.Handler {
 		// get token from header
 		token := r.Header.Get("X-Access-Token")
 		if token == "" {
-			http.Error(w, serverr.UserUnauthorizedError.JsonBody(), serverr.UserUnauthorizedError.HttpStatus)
+			serverr.UserUnauthorizedError.Write(w, r)
 			return
 		}
 
 		// validate token
 		isAdmin, err := auth.ValidateToken(token)
 		if err != nil {
-			http.Error(w, err.JsonBody(), err.HttpStatus)
+			err.Write(w, r)
 			return
 		}
 
@@ -29,3 +29,22 @@ func TokenValidationMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// AdminOnlyMiddleware
+// Lets through requests of admins only, must be used after TokenValidationMiddleware
+func AdminOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isAdmin, ok := r.Context().Value("isAdmin").(bool)
+		if !ok {
+			serverr.TokenParsingError.Write(w, r)
+			return
+		}
+
+		if !isAdmin {
+			serverr.ForbiddenAccessError.Write(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
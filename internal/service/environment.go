@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/auth"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"net/http"
+	"slices"
+)
+
+const (
+	EnvironmentHeader = "X-Environment"
+
+	EnvironmentProduction = "production"
+	EnvironmentStaging    = "staging"
+)
+
+type EnvironmentConfig struct {
+	// Names of the environments banners can be created in
+	Names []string `toml:"names"`
+	// Default environment of requests that don't specify one
+	Default string `toml:"default"`
+}
+
+type environmentKey struct{}
+
+func (ec EnvironmentConfig) withDefaults() EnvironmentConfig {
+	if len(ec.Names) == 0 {
+		ec.Names = []string{EnvironmentProduction, EnvironmentStaging}
+	}
+	if ec.Default == "" {
+		ec.Default = EnvironmentProduction
+	}
+
+	return ec
+}
+
+// Valid
+// Reports whether env is one of the configured environments
+func (ec EnvironmentConfig) Valid(env string) bool {
+	return slices.Contains(ec.withDefaults().Names, env)
+}
+
+func WithEnvironment(ctx context.Context, env string) context.Context {
+	return context.WithValue(ctx, environmentKey{}, env)
+}
+
+// Environment
+// Returns the environment of the request, production if it's not set
+func Environment(ctx context.Context) string {
+	if env, ok := ctx.Value(environmentKey{}).(string); ok {
+		return env
+	}
+
+	return EnvironmentProduction
+}
+
+// EnvironmentMiddleware
+// Puts the environment of the request into context. The environment the token is bound to
+// can't be overridden by the header, a request to another environment is forbidden
+func EnvironmentMiddleware(ec EnvironmentConfig) func(http.Handler) http.Handler {
+	ec = ec.withDefaults()
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			env := r.Header.Get(EnvironmentHeader)
+			if bound := auth.TokenEnvironment(r.Header.Get("X-Access-Token")); bound != "" {
+				if env != "" && env != bound {
+					serverr.ForbiddenAccessError.Write(w, r)
+					return
+				}
+				env = bound
+			}
+
+			if env == "" {
+				env = ec.Default
+			}
+
+			if !ec.Valid(env) {
+				apierr := serverr.NewInvalidRequestErrorf("Неизвестное окружение '%s'", env)
+				apierr.Write(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithEnvironment(r.Context(), env)))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	StatusOk          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+	StatusFail        = "fail"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+type HealthReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type HealthService struct {
+	p  *pgxpool.Pool
+	br *repo.BannerRepository
+	cr *repo.CacheRepo
+	bs *BannerService
+	// schemaVersion is the latest migration the service is built with
+	schemaVersion int64
+	l             *zap.SugaredLogger
+}
+
+func NewHealthService(p *pgxpool.Pool, br *repo.BannerRepository, cr *repo.CacheRepo, bs *BannerService,
+	schemaVersion int64, l *zap.SugaredLogger) *HealthService {
+	return &HealthService{
+		p:             p,
+		br:            br,
+		cr:            cr,
+		bs:            bs,
+		schemaVersion: schemaVersion,
+		l:             l,
+	}
+}
+
+// Liveness
+// The process is alive while background workers are not stopped,
+// dependencies are not checked so that restarts don't cascade
+func (hs *HealthService) Liveness() HealthReport {
+	report := HealthReport{
+		Status: StatusOk,
+		Checks: map[string]string{"workers": hs.workersState()},
+	}
+
+	if hs.bs.isStopped() {
+		report.Status = StatusUnavailable
+	}
+
+	return report
+}
+
+// Readiness
+// The service is ready when postgres is reachable, the schema is in place
+// and the startup warm-up is completed. Redis is optional: when it is down
+// the service is ready but degraded, banners are served from postgres
+func (hs *HealthService) Readiness(ctx context.Context) HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	report := HealthReport{
+		Status: StatusOk,
+		Checks: map[string]string{},
+	}
+
+	report.Checks["postgres"] = hs.check("postgres", hs.p.Ping(ctx))
+	report.Checks["schema"] = hs.check("schema", hs.br.CheckSchema(ctx, hs.schemaVersion))
+	if report.Checks["postgres"] != StatusOk || report.Checks["schema"] != StatusOk {
+		report.Status = StatusUnavailable
+	}
+
+	report.Checks["redis"] = hs.check("redis", hs.cr.Ping(ctx))
+	report.Checks["cache_breaker"] = hs.cr.BreakerState().String()
+
+	if report.Status == StatusOk && (report.Checks["redis"] != StatusOk || hs.bs.CacheDegraded()) {
+		report.Status = StatusDegraded
+	}
+
+	report.Checks["workers"] = hs.workersState()
+	if !hs.bs.Ready() || hs.bs.isStopped() {
+		report.Status = StatusUnavailable
+	}
+
+	return report
+}
+
+// check
+// Returns the status of the check, the error is only logged since health endpoints are public
+func (hs *HealthService) check(name string, err error) string {
+	if err != nil {
+		hs.l.Warnf("readiness: %s check failed: %v", name, err)
+		return StatusFail
+	}
+
+	return StatusOk
+}
+
+func (hs *HealthService) workersState() string {
+	switch {
+	case hs.bs.isStopped():
+		return "stopped"
+	case !hs.bs.Ready():
+		return "warming up"
+	default:
+		return "running"
+	}
+}
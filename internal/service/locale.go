@@ -0,0 +1,82 @@
+package service
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util"
+	"slices"
+	"strings"
+)
+
+// maxLocaleChain bounds the number of content variants looked up for one request
+const maxLocaleChain = 8
+
+// NormalizeLocale
+// Returns the locale as content variants are stored, en_US and en-US are en-us
+func NormalizeLocale(tag string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tag)), "_", "-")
+}
+
+// LocaleChain
+// Returns locales of content variants in the order they are looked up. Locale parameter goes
+// before the languages of Accept-Language, every locale is followed by its parents, e.g. pt-br
+// by pt. The default content is used if the banner has none of them
+func LocaleChain(locale string, acceptLanguage string) []string {
+	tags := util.AcceptedLanguages(acceptLanguage)
+	if locale != "" {
+		tags = append([]string{locale}, tags...)
+	}
+
+	var chain []string
+	for _, tag := range tags {
+		tag = NormalizeLocale(tag)
+		if !dto.ValidLocale(tag) {
+			continue
+		}
+
+		for ; tag != ""; tag = parentLocale(tag) {
+			if !slices.Contains(chain, tag) {
+				chain = append(chain, tag)
+			}
+		}
+	}
+
+	if len(chain) > maxLocaleChain {
+		chain = chain[:maxLocaleChain]
+	}
+
+	return chain
+}
+
+func parentLocale(tag string) string {
+	i := strings.LastIndex(tag, "-")
+	if i < 0 {
+		return ""
+	}
+
+	return tag[:i]
+}
+
+// localize
+// Returns the variant of the first locale of the chain the banner has together with the locale,
+// the default content and empty locale if the banner has none of them
+func localize(content json.RawMessage, locales models.Locales, chain []string) (json.RawMessage, string) {
+	for _, locale := range chain {
+		if variant, ok := locales[locale]; ok {
+			return variant, locale
+		}
+	}
+
+	return content, ""
+}
+
+// allLocales
+// Variants of a banner that is rewritten as a whole, variants it doesn't have are removed
+func allLocales(locales models.Locales) models.Locales {
+	if locales == nil {
+		return models.Locales{}
+	}
+
+	return locales
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+)
+
+// PromoteBanner
+// Copies the current version of the banner to another environment. The counterpart there is
+// the banner with the same external key, or the banner mapped to the same feature-tag pairs
+// if the banner has no key. The counterpart gets a new version, if there is none it's created.
+// Banners are promoted within the tenant, the banner is looked up in env
+func (bs *BannerService) PromoteBanner(ctx context.Context, tenant string, env string, bannerId int64, to string) (*dto.PromoteBannerResponseDto, *serverr.ApiError) {
+	ctx, span := tracing.Start(ctx, "BannerService.PromoteBanner")
+	defer span.End()
+
+	if !bs.ec.Valid(to) {
+		return nil, serverr.NewInvalidRequestErrorf("Неизвестное окружение '%s'", to)
+	}
+
+	src, apierr := bs.br.GetBannerById(ctx, tenant, env, bannerId)
+	if apierr != nil {
+		return nil, apierr
+	}
+
+	if src.ToDelete {
+		return nil, serverr.BannerNotFoundError
+	}
+
+	if src.Environment == to {
+		return nil, serverr.NewInvalidRequestErrorf("Баннер уже находится в окружении '%s'", to)
+	}
+
+	target, apierr := bs.promotionTarget(ctx, src, to)
+	if apierr != nil {
+		return nil, apierr
+	}
+
+	var targetId int64
+	if target != nil {
+		targetId = target.Id
+	}
+
+	owner, err := bs.br.FindPairOwner(ctx, tenant, to, src.FeatureId, src.TagIds, targetId)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return nil, serverr.StorageError
+	}
+
+	if owner != nil {
+		return nil, serverr.NewPairConflictError(owner.FeatureId, owner.TagId, owner.Id)
+	}
+
+	resp := &dto.PromoteBannerResponseDto{Environment: to}
+
+	if target != nil {
+		apierr := bs.ChangeBanner(ctx, tenant, to, target.Id, dto.ChangeBannerDto{
+			TagIds:    src.TagIds,
+			FeatureId: &src.FeatureId,
+			Content:   &src.Content,
+			Locales:   allLocales(src.Locales),
+			Rule:      &src.Rule,
+			IsActive:  &src.IsActive,
+			Priority:  &src.Priority,
+			IsDefault: &src.IsDefault,
+		})
+		if apierr != nil {
+			return nil, apierr
+		}
+
+		resp.BannerId, resp.Version = target.Id, target.LastRevision+1
+	} else {
+		if apierr := bs.checkContentQuota(tenant, src.Content, src.Locales); apierr != nil {
+			return nil, apierr
+		}
+
+		createdId, err := bs.br.CreateBanner(ctx, &models.BannerTagsModel{
+			TagIds:      src.TagIds,
+			FeatureId:   src.FeatureId,
+			Content:     src.Content,
+			Locales:     src.Locales,
+			Rule:        src.Rule,
+			IsActive:    src.IsActive,
+			Priority:    src.Priority,
+			IsDefault:   src.IsDefault,
+			ExternalKey: src.ExternalKey,
+			Environment: to,
+			Tenant:      tenant,
+		}, bs.maxBanners(tenant))
+		if err != nil {
+			return nil, bs.storageError(ctx, err)
+		}
+
+		bs.invalidate(ctx, pairKeys(tenant, to, src.FeatureId, src.TagIds)...)
+
+		resp.BannerId, resp.Version, resp.Created = createdId, 1, true
+	}
+
+	bs.log(ctx).Infof(
+		"Banner [id=%d] is promoted from %s to %s as banner [id=%d], version %d",
+		bannerId, src.Environment, to, resp.BannerId, resp.Version,
+	)
+
+	return resp, nil
+}
+
+// promotionTarget
+// Returns the counterpart of the banner in the environment, nil if there is none
+func (bs *BannerService) promotionTarget(ctx context.Context, src *models.BannerTagsModel, to string) (*models.BannerTagsModel, *serverr.ApiError) {
+	var targetId int64
+
+	if src.ExternalKey != "" {
+		id, _, err := bs.br.GetBannerByExternalKey(ctx, src.Tenant, to, src.ExternalKey)
+		if err != nil {
+			bs.log(ctx).Error(err)
+			return nil, serverr.StorageError
+		}
+		targetId = id
+	} else {
+		ids, err := bs.br.GetOverlappingBanners(ctx, src.Tenant, to, src.FeatureId, src.TagIds)
+		if err != nil {
+			bs.log(ctx).Error(err)
+			return nil, serverr.StorageError
+		}
+
+		if len(ids) > 1 {
+			return nil, serverr.NewInvalidRequestErrorf(
+				"В окружении '%s' баннеру соответствует несколько баннеров, укажите external_key", to,
+			)
+		}
+
+		if len(ids) == 1 {
+			targetId = ids[0]
+		}
+	}
+
+	if targetId == 0 {
+		return nil, nil
+	}
+
+	target, apierr := bs.br.GetBannerById(ctx, src.Tenant, to, targetId)
+	if apierr != nil {
+		return nil, apierr
+	}
+
+	// rewriting keeps the mark, so the promoted version would never be shown
+	if target.ToDelete {
+		return nil, serverr.NewInvalidRequestErrorf("Баннер в окружении '%s' удалён, восстановите его", to)
+	}
+
+	return target, nil
+}
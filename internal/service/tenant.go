@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/auth"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"net/http"
+	"regexp"
+)
+
+// DefaultTenant owns banners created before tenants and requests with tokens without tenant
+const DefaultTenant = "default"
+
+// tenant is a part of cache keys, so it must not contain ":"
+var tenantPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+type TenantConfig struct {
+	// Quota of tenants that don't have their own one
+	Quota Quota `toml:"quota"`
+	// Quotas of particular tenants
+	Quotas map[string]Quota `toml:"quotas"`
+}
+
+type Quota struct {
+	// MaxBanners of the tenant in all environments, deleted banners are not counted, 0 is unlimited
+	MaxBanners int64 `toml:"max_banners"`
+	// MaxContentSize of a banner in bytes, 0 is unlimited
+	MaxContentSize int `toml:"max_content_size"`
+}
+
+type tenantKey struct{}
+
+// QuotaOf
+// Returns the quota of the tenant
+func (tc TenantConfig) QuotaOf(tenant string) Quota {
+	if q, ok := tc.Quotas[tenant]; ok {
+		return q
+	}
+
+	return tc.Quota
+}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant
+// Returns the tenant of the request, the default one if it's not set
+func Tenant(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		return tenant
+	}
+
+	return DefaultTenant
+}
+
+// TenantMiddleware
+// Puts the tenant of the token into context, admins manage banners of their tenant only
+func TenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := auth.TokenTenant(r.Header.Get("X-Access-Token"))
+		if tenant == "" {
+			tenant = DefaultTenant
+		}
+
+		if !tenantPattern.MatchString(tenant) {
+			apierr := serverr.NewInvalidRequestErrorf("Некорректный tenant '%s'", tenant)
+			apierr.Write(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), tenant)))
+	})
+}
+
+// checkQuota
+// Checks that the tenant may have added more banners and that the content and its variants fit the quota.
+// The number of banners is checked again when they are added, this check only fails early
+func (bs *BannerService) checkQuota(ctx context.Context, tenant string, added int64, content json.RawMessage, locales models.Locales) *serverr.ApiError {
+	if apierr := bs.checkContentQuota(tenant, content, locales); apierr != nil {
+		return apierr
+	}
+
+	if added <= 0 {
+		return nil
+	}
+
+	left, apierr := bs.bannersLeft(ctx, tenant)
+	if apierr != nil {
+		return apierr
+	}
+
+	if left >= 0 && added > left {
+		return bs.bannersQuotaError(tenant)
+	}
+
+	return nil
+}
+
+// checkContentQuota
+// Checks size of the content and of every content variant
+func (bs *BannerService) checkContentQuota(tenant string, content json.RawMessage, locales models.Locales) *serverr.ApiError {
+	quota := bs.tc.QuotaOf(tenant)
+	if quota.MaxContentSize <= 0 {
+		return nil
+	}
+
+	tooLarge := len(content) > quota.MaxContentSize
+	for _, variant := range locales {
+		tooLarge = tooLarge || len(variant) > quota.MaxContentSize
+	}
+
+	if tooLarge {
+		return serverr.NewQuotaExceededErrorf(
+			"Размер content превышает квоту в %d байт", quota.MaxContentSize,
+		)
+	}
+
+	return nil
+}
+
+// bannersLeft
+// Returns how many banners the tenant may create, -1 if the number is unlimited
+func (bs *BannerService) bannersLeft(ctx context.Context, tenant string) (int64, *serverr.ApiError) {
+	quota := bs.tc.QuotaOf(tenant)
+	if quota.MaxBanners <= 0 {
+		return -1, nil
+	}
+
+	count, err := bs.br.CountBanners(ctx, tenant)
+	if err != nil {
+		bs.log(ctx).Error(err)
+		return 0, serverr.StorageError
+	}
+
+	return max(quota.MaxBanners-count, 0), nil
+}
+
+func (bs *BannerService) bannersQuotaError(tenant string) *serverr.ApiError {
+	return serverr.NewBannersQuotaError(bs.maxBanners(tenant))
+}
+
+// maxBanners
+// Returns the banner quota of the tenant, it's enforced by the repository
+// in the transaction adding banners. 0 is unlimited
+func (bs *BannerService) maxBanners(tenant string) int64 {
+	return max(bs.tc.QuotaOf(tenant).MaxBanners, 0)
+}
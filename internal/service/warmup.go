@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/tracing"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HitsSet is a redis sorted set counting requests per cache key
+const HitsSet = "banner_hits"
+
+const defaultWarmUpConcurrency = 8
+
+const (
+	// hitsPerWarmUpPair is the number of counted keys per pair preloaded by warm-up
+	hitsPerWarmUpPair = 10
+	// hitsWindow is the period requests are counted in, counts are reset after it
+	hitsWindow = 24 * time.Hour
+)
+
+type WarmUpConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Limit of the most requested pairs to preload, 0 preloads all active pairs
+	Limit int `toml:"limit"`
+	// Concurrency is a number of simultaneous database lookups
+	Concurrency int `toml:"concurrency"`
+}
+
+// Ready
+// Reports whether the startup warm-up is completed
+func (bs *BannerService) Ready() bool {
+	return bs.ready.Load()
+}
+
+// StartWarmUp
+// Runs the warm-up in background, the service is not ready until it's done
+func (bs *BannerService) StartWarmUp() {
+	if !bs.cc.WarmUp.Enabled {
+		bs.ready.Store(true)
+		return
+	}
+
+	bs.background(func() {
+		bs.WarmUp(context.Background())
+		bs.ready.Store(true)
+	})
+}
+
+// WarmUp
+// Preloads the most requested feature-tag pairs into cache. If requests were not
+// counted yet (e.g. redis is flushed) the most recently updated active pairs are used
+func (bs *BannerService) WarmUp(ctx context.Context) {
+	ctx, span := tracing.Start(ctx, "BannerService.WarmUp")
+	defer span.End()
+
+	pairs, err := bs.warmUpPairs(ctx)
+	if err != nil {
+		bs.log(ctx).Errorf("warm-up: unable to get pairs: %v", err)
+		return
+	}
+
+	bs.warmPairs(ctx, pairs)
+}
+
+func (bs *BannerService) warmUpPairs(ctx context.Context) ([]models.BannerModel, error) {
+	limit := bs.cc.WarmUp.Limit
+
+	if limit > 0 {
+		keys, err := bs.redis.TopMembers(ctx, HitsSet, limit)
+		if err != nil {
+			bs.log(ctx).Error(err)
+		}
+
+		var pairs []models.BannerModel
+		for _, key := range keys {
+			if pair, ok := parseCacheKey(key); ok {
+				pairs = append(pairs, pair)
+			}
+		}
+
+		if len(pairs) > 0 {
+			return pairs, nil
+		}
+	}
+
+	return bs.br.GetActivePairs(ctx, limit)
+}
+
+// warmPairs
+// Loads pairs into cache with bounded concurrency, logs the progress
+func (bs *BannerService) warmPairs(ctx context.Context, pairs []models.BannerModel) {
+	concurrency := bs.cc.WarmUp.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultWarmUpConcurrency
+	}
+
+	total := len(pairs)
+	step := max(total/10, 1)
+	start := time.Now()
+	bs.log(ctx).Infof("warm-up: loading %d pairs", total)
+
+	var done atomic.Int64
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, pair := range pairs {
+		// don't hold the shutdown back
+		if bs.isStopped() {
+			bs.log(ctx).Infof("warm-up: interrupted, %d/%d pairs loaded", done.Load(), total)
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(tenant string, env string, featureId int64, tagId int64) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			key := cacheKey(tenant, env, featureId, tagId)
+			bs.co.Load(ctx, key, bs.loader(key, bs.pairGetter(tenant, env, featureId, tagId)))
+
+			if n := done.Add(1); n%int64(step) == 0 {
+				bs.log(ctx).Infof("warm-up: %d/%d pairs loaded", n, total)
+			}
+		}(pair.Tenant, pair.Environment, pair.FeatureId, pair.TagId)
+	}
+
+	wg.Wait()
+	bs.log(ctx).Infof("warm-up: completed in %s", time.Since(start))
+}
+
+// countHit
+// Counts the request of the key of a found banner, the most requested keys are warmed up first.
+// The number of counted keys is bounded, so requests of arbitrary pairs can't grow the set
+func (bs *BannerService) countHit(ctx context.Context, key string) {
+	if !bs.cc.WarmUp.Enabled || bs.cc.WarmUp.Limit <= 0 {
+		return
+	}
+
+	maxKeys := int64(bs.cc.WarmUp.Limit) * hitsPerWarmUpPair
+	if err := bs.redis.IncrScore(ctx, HitsSet, key, maxKeys, hitsWindow); err != nil && !errors.Is(err, repo.ErrCircuitOpen) {
+		bs.log(ctx).Error(err)
+	}
+}
+
+func parseCacheKey(key string) (models.BannerModel, bool) {
+	tenant, rest, found := strings.Cut(key, ":")
+	if !found {
+		return models.BannerModel{}, false
+	}
+
+	env, rest, found := strings.Cut(rest, ":")
+	if !found {
+		return models.BannerModel{}, false
+	}
+
+	f, t, found := strings.Cut(rest, "_")
+	if !found {
+		return models.BannerModel{}, false
+	}
+
+	featureId, err := strconv.ParseInt(f, 10, 64)
+	if err != nil {
+		return models.BannerModel{}, false
+	}
+
+	tagId, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return models.BannerModel{}, false
+	}
+
+	return models.BannerModel{Tenant: tenant, Environment: env, FeatureId: featureId, TagId: tagId}, true
+}
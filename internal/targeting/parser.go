@@ -0,0 +1,298 @@
+package targeting
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxDepth bounds nesting of parentheses and negations
+const maxDepth = 32
+
+// Parse
+// Parses the targeting expression, empty expression is a nil rule matching every user.
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = attribute ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) value
+//	           | attribute "in" "[" value { "," value } "]"
+//	value      = "string" | number
+//
+// Attributes are platform, app_version, country and custom ones starting with attr.
+func Parse(text string) (*Rule, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	root, err := p.expr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEnd {
+		return nil, p.unexpected(tok)
+	}
+
+	return &Rule{text: text, root: root}, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenAttr
+	tokenValue
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // position in the expression, from 1
+}
+
+func (t token) String() string {
+	if t.kind == tokenEnd {
+		return "end of expression"
+	}
+
+	return fmt.Sprintf("'%s' at %d", t.text, t.pos)
+}
+
+// operators, longer ones first so that <= is not read as <
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			value, n, err := readString(runes[i:], pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenValue, text: value, pos: pos})
+			i += n
+		case unicode.IsDigit(r):
+			n := readWhile(runes[i:], func(r rune) bool { return unicode.IsDigit(r) || r == '.' })
+			tokens = append(tokens, token{kind: tokenValue, text: string(runes[i : i+n]), pos: pos})
+			i += n
+		case r == '_' || unicode.IsLetter(r):
+			n := readWhile(runes[i:], func(r rune) bool { return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) })
+			name := string(runes[i : i+n])
+			if name == "in" {
+				tokens = append(tokens, token{kind: tokenOp, text: name, pos: pos})
+			} else {
+				tokens = append(tokens, token{kind: tokenAttr, text: name, pos: pos})
+			}
+			i += n
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(string(runes[i:min(i+2, len(runes))]), o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character '%c' at %d", r, pos)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: pos})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEnd, pos: len(runes) + 1}), nil
+}
+
+// readString reads the quoted string, \" and \\ are escapes
+func readString(runes []rune, pos int) (string, int, error) {
+	var sb strings.Builder
+	for i := 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '"':
+			return sb.String(), i + 1, nil
+		case '\\':
+			if i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+			}
+		}
+		sb.WriteRune(runes[i])
+	}
+
+	return "", 0, fmt.Errorf("unterminated string at %d", pos)
+}
+
+func readWhile(runes []rune, f func(r rune) bool) int {
+	n := 0
+	for n < len(runes) && f(runes[n]) {
+		n++
+	}
+
+	return n
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokenEnd {
+		p.i++
+	}
+
+	return tok
+}
+
+func (p *parser) isOp(text string) bool {
+	tok := p.peek()
+	return tok.kind == tokenOp && tok.text == text
+}
+
+func (p *parser) unexpected(tok token) error {
+	return fmt.Errorf("unexpected %s", tok)
+}
+
+func (p *parser) expr(depth int) (node, error) {
+	left, err := p.and(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("||") {
+		p.next()
+		right, err := p.and(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and(depth int) (node, error) {
+	left, err := p.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("&&") {
+		p.next()
+		right, err := p.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) unary(depth int) (node, error) {
+	if depth >= maxDepth {
+		return nil, fmt.Errorf("expression is nested deeper than %d levels", maxDepth)
+	}
+
+	switch {
+	case p.isOp("!"):
+		p.next()
+		operand, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	case p.isOp("("):
+		p.next()
+		inner, err := p.expr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.unexpected(p.peek())
+		}
+		p.next()
+		return inner, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	attr := p.next()
+	if attr.kind != tokenAttr {
+		return nil, p.unexpected(attr)
+	}
+
+	if !KnownAttribute(attr.text) {
+		return nil, fmt.Errorf("unknown attribute '%s' at %d", attr.text, attr.pos)
+	}
+
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, p.unexpected(op)
+	}
+
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		value := p.next()
+		if value.kind != tokenValue {
+			return nil, p.unexpected(value)
+		}
+		return compareNode{attr: attr.text, op: op.text, value: value.text}, nil
+	case "in":
+		values, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		return inNode{attr: attr.text, values: values}, nil
+	}
+
+	return nil, p.unexpected(op)
+}
+
+func (p *parser) list() ([]string, error) {
+	if !p.isOp("[") {
+		return nil, p.unexpected(p.peek())
+	}
+	p.next()
+
+	var values []string
+	for {
+		value := p.next()
+		if value.kind != tokenValue {
+			return nil, p.unexpected(value)
+		}
+		values = append(values, value.text)
+
+		if p.isOp("]") {
+			p.next()
+			return values, nil
+		}
+
+		if !p.isOp(",") {
+			return nil, p.unexpected(p.peek())
+		}
+		p.next()
+	}
+}
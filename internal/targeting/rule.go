@@ -0,0 +1,205 @@
+package targeting
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// built-in attributes of the request
+const (
+	AttrPlatform   = "platform"
+	AttrAppVersion = "app_version"
+	AttrCountry    = "country"
+)
+
+// CustomPrefix starts names of custom attributes, e.g. attr.segment
+const CustomPrefix = "attr."
+
+// Attributes
+// Attributes of the user the banner is resolved for by name
+type Attributes map[string]string
+
+// FromQuery
+// Returns built-in and custom attributes passed as request parameters
+func FromQuery(query url.Values) Attributes {
+	attrs := make(Attributes)
+	for name, values := range query {
+		if len(values) > 0 && KnownAttribute(name) {
+			attrs[name] = values[0]
+		}
+	}
+
+	return attrs
+}
+
+// KnownAttribute
+// Reports whether rules may use the attribute, it's built-in or custom
+func KnownAttribute(name string) bool {
+	switch name {
+	case AttrPlatform, AttrAppVersion, AttrCountry:
+		return true
+	}
+
+	return strings.HasPrefix(name, CustomPrefix) && len(name) > len(CustomPrefix)
+}
+
+// Rule
+// Parsed targeting expression of a banner, e.g. platform == "ios" && app_version >= "5.2".
+// Nil rule matches every user
+type Rule struct {
+	text string
+	root node
+}
+
+// String returns the expression the rule is parsed from
+func (r *Rule) String() string {
+	if r == nil {
+		return ""
+	}
+
+	return r.text
+}
+
+// Match
+// Reports whether the user with the attributes is targeted by the rule
+func (r *Rule) Match(attrs Attributes) bool {
+	if r == nil {
+		return true
+	}
+
+	return r.root.eval(attrs)
+}
+
+type node interface {
+	eval(attrs Attributes) bool
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n orNode) eval(attrs Attributes) bool {
+	return n.left.eval(attrs) || n.right.eval(attrs)
+}
+
+type andNode struct {
+	left, right node
+}
+
+func (n andNode) eval(attrs Attributes) bool {
+	return n.left.eval(attrs) && n.right.eval(attrs)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(attrs Attributes) bool {
+	return !n.operand.eval(attrs)
+}
+
+// compareNode compares the attribute with the value, it's false if the user has no such attribute
+type compareNode struct {
+	attr  string
+	op    string
+	value string
+}
+
+func (n compareNode) eval(attrs Attributes) bool {
+	actual, ok := attrs[n.attr]
+	if !ok {
+		return false
+	}
+
+	c := compare(actual, n.value)
+	switch n.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+
+	return false
+}
+
+// inNode is true if the attribute is equal to any of the values
+type inNode struct {
+	attr   string
+	values []string
+}
+
+func (n inNode) eval(attrs Attributes) bool {
+	actual, ok := attrs[n.attr]
+	if !ok {
+		return false
+	}
+
+	for _, value := range n.values {
+		if compare(actual, value) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compare
+// Compares versions like 5.10 and 5.2 by their numeric parts, 5.2 and 5.2.0 are equal.
+// Other values are compared as strings ignoring case
+func compare(a string, b string) int {
+	if va, ok := parseVersion(a); ok {
+		if vb, ok := parseVersion(b); ok {
+			return compareVersions(va, vb)
+		}
+	}
+
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func parseVersion(s string) ([]int64, bool) {
+	if s == "" {
+		return nil, false
+	}
+
+	parts := strings.Split(s, ".")
+	version := make([]int64, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || n < 0 || part[0] == '+' {
+			return nil, false
+		}
+		version[i] = n
+	}
+
+	return version, true
+}
+
+func compareVersions(a []int64, b []int64) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int64
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
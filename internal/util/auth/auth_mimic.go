@@ -2,6 +2,7 @@ package auth
 
 import (
 	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"strings"
 )
 
 const (
@@ -19,6 +20,11 @@ Returns true if token belongs to administrator, otherwise returns false
 */
 func ValidateToken(token string) (bool, *serverr.ApiError) {
 
+	// token is too short to carry a prefix
+	if len(token) < 3 {
+		return false, serverr.ForbiddenAccessError
+	}
+
 	prefix := token[:3]
 	switch prefix {
 
@@ -37,3 +43,37 @@ func ValidateToken(token string) (bool, *serverr.ApiError) {
 	}
 
 }
+
+// TokenEnvironment
+/*
+Imitates the environment claim of the token
+
+Token bound to an environment ends with "@<environment>", e.g. "aup_1@staging".
+Returns empty string if token is not bound
+*/
+func TokenEnvironment(token string) string {
+	i := strings.LastIndexByte(token, '@')
+	if i < 0 {
+		return ""
+	}
+
+	return token[i+1:]
+}
+
+// TokenTenant
+/*
+Imitates the tenant claim of the token
+
+Tenant follows "#" and goes before the environment claim, e.g. "aap_1#shop" or "aup_1#shop@staging".
+Returns empty string if token has no tenant
+*/
+func TokenTenant(token string) string {
+	_, tenant, found := strings.Cut(token, "#")
+	if !found {
+		return ""
+	}
+
+	tenant, _, _ = strings.Cut(tenant, "@")
+
+	return tenant
+}
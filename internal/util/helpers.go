@@ -1,6 +1,7 @@
 package util
 
 import (
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -20,3 +21,49 @@ func StringToIntSlice(s string) ([]int64, error) {
 
 	return result, nil
 }
+
+// AcceptedLanguages
+// Returns language tags of Accept-Language header in lower case, the most preferred first.
+// The wildcard and tags with zero weight are skipped
+func AcceptedLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if pq, err := strconv.ParseFloat(v, 64); err == nil {
+				q = pq
+			}
+		}
+
+		if tag != "" && tag != "*" && q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+
+	// tags of the same weight keep the order of the header
+	slices.SortStableFunc(tags, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	res := make([]string, len(tags))
+	for i, t := range tags {
+		res[i] = t.tag
+	}
+
+	return res
+}
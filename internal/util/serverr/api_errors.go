@@ -1,6 +1,11 @@
 package serverr
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"net/http"
+)
 
 // error types
 const (
@@ -9,66 +14,249 @@ const (
 	InvalidData      = "Некорректные данные"
 	ServerConflict   = "Внутреннняя ошибка сервера"
 	BannerNotFound   = "Баннер не найден"
+	FeatureNotFound  = "Фича не найдена"
+	TagNotFound      = "Тег не найден"
+	RequestTimeout   = "Превышено время обработки запроса"
+	TooManyRequests  = "Слишком много запросов"
+	QuotaExceeded    = "Превышена квота"
+	Conflict         = "Конфликт с существующими данными"
+)
+
+// error codes, stable values clients may rely on
+const (
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeBannerNotFound   = "banner_not_found"
+	CodeFeatureNotFound  = "feature_not_found"
+	CodeTagNotFound      = "tag_not_found"
+	CodeConflict         = "conflict"
+	CodePairConflict     = "pair_conflict"
+	CodeQuotaExceeded    = "quota_exceeded"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeRequestTimeout   = "request_timeout"
+	CodeStorageError     = "storage_error"
+	CodeTokenParsing     = "token_parsing_error"
+	CodeInternalError    = "internal_error"
 )
 
 // defined errors
 var (
 	UserUnauthorizedError = &ApiError{
+		Code:        CodeUnauthorized,
 		Description: UserUnauthorized,
 		HttpStatus:  401,
 	}
 	ForbiddenAccessError = &ApiError{
+		Code:        CodeForbidden,
 		Description: AccessRestricted,
 		HttpStatus:  403,
 	}
 	InvalidRequestError = &ApiError{
+		Code:        CodeInvalidRequest,
 		Description: InvalidData,
 		ErrType:     "Неверный формат запроса",
 		HttpStatus:  400,
 	}
 	StorageError = &ApiError{
+		Code:        CodeStorageError,
 		Description: ServerConflict,
 		ErrType:     "Ошибка хранилища данных",
 		HttpStatus:  500,
 	}
 	TokenParsingError = &ApiError{
+		Code:        CodeTokenParsing,
 		Description: ServerConflict,
 		ErrType:     "Ошибка парсинга токена",
 		HttpStatus:  500,
 	}
 	BannerNotFoundError = &ApiError{
+		Code:        CodeBannerNotFound,
 		Description: BannerNotFound,
 		HttpStatus:  404,
 	}
+	InternalError = &ApiError{
+		Code:        CodeInternalError,
+		Description: ServerConflict,
+		ErrType:     "Непредвиденная ошибка при обработке запроса",
+		HttpStatus:  500,
+	}
+	TooManyRequestsError = &ApiError{
+		Code:        CodeTooManyRequests,
+		Description: TooManyRequests,
+		ErrType:     "Превышен лимит запросов, повторите позже",
+		HttpStatus:  429,
+	}
+	FeatureNotFoundError = &ApiError{
+		Code:        CodeFeatureNotFound,
+		Description: FeatureNotFound,
+		HttpStatus:  404,
+	}
+	TagNotFoundError = &ApiError{
+		Code:        CodeTagNotFound,
+		Description: TagNotFound,
+		HttpStatus:  404,
+	}
+	RequestTimeoutError = &ApiError{
+		Code:        CodeRequestTimeout,
+		Description: RequestTimeout,
+		ErrType:     "Запрос отменён или превышено время ожидания",
+		HttpStatus:  504,
+	}
 )
 
 type ApiError struct {
-	Description string `json:"description"`
-	ErrType     string `json:"error"`
-	HttpStatus  int    `json:"-"`
+	Code        string       `json:"code"`
+	Description string       `json:"description"`
+	ErrType     string       `json:"error"`
+	Details     []FieldError `json:"details,omitempty"`
+	HttpStatus  int          `json:"-"`
+
+	// format and args ErrType is made of, the format is translated
+	format string
+	args   []any
 }
 
-func (apierr *ApiError) JsonBody() string {
-	var res []byte
+// FieldError
+// Describes the field of the request body that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
 
-	if apierr.ErrType != "" {
-		res, _ = json.Marshal(struct {
-			ErrType string `json:"error"`
-		}{
-			ErrType: apierr.ErrType,
-		})
+// JsonBody
+// Returns the error response body with messages in lang, requestId is omitted if empty
+func (apierr *ApiError) JsonBody(requestId string, lang string) string {
+	var details []FieldError
+	for _, d := range apierr.Details {
+		d.Message = translate(lang, fieldErrorFormat, d.Field, d.Rule)
+		details = append(details, d)
 	}
 
+	res, _ := json.Marshal(struct {
+		Code      string       `json:"code"`
+		ErrType   string       `json:"error"`
+		Details   []FieldError `json:"details,omitempty"`
+		RequestId string       `json:"request_id,omitempty"`
+	}{
+		Code:      apierr.Code,
+		ErrType:   apierr.Message(lang),
+		Details:   details,
+		RequestId: requestId,
+	})
+
 	return string(res)
 }
 
+// Message
+// Returns the message of the error in lang, the description is used if there is no message.
+// Messages without translation fall back to the translated description
+func (apierr *ApiError) Message(lang string) string {
+	format, args := apierr.format, apierr.args
+	if format == "" {
+		format = apierr.ErrType
+	}
+	if format == "" {
+		format = apierr.Description
+	}
+
+	if lang != LangRu && !translated(lang, format) && translated(lang, apierr.Description) {
+		format, args = apierr.Description, nil
+	}
+
+	return translate(lang, format, args...)
+}
+
+// Write
+// Writes the error response with the id of the request, messages are in the language of Accept-Language
+func (apierr *ApiError) Write(w http.ResponseWriter, r *http.Request) {
+	lang := Lang(r)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apierr.HttpStatus)
+	fmt.Fprintln(w, apierr.JsonBody(logging.RequestId(r.Context()), lang))
+}
+
 func NewInvalidRequestError(errm string) *ApiError {
 	return &ApiError{
-		ErrType:    errm,
-		HttpStatus: 400,
+		Code:        CodeInvalidRequest,
+		Description: InvalidData,
+		ErrType:     errm,
+		HttpStatus:  400,
+	}
+}
+
+// NewInvalidRequestErrorf
+// Returns the bad request error with the formatted message, the format is translated
+func NewInvalidRequestErrorf(format string, args ...any) *ApiError {
+	return newErrorf(CodeInvalidRequest, InvalidData, 400, format, args...)
+}
+
+// NewValidationError
+// Returns the bad request error listing the fields that failed validation
+func NewValidationError(details ...FieldError) *ApiError {
+	apierr := newErrorf(CodeValidationFailed, InvalidData, 400, "Некорректные данные запроса")
+	if len(details) > 0 {
+		apierr = newErrorf(CodeValidationFailed, InvalidData, 400, fieldErrorFormat, details[0].Field, details[0].Rule)
+	}
+	apierr.Details = details
+
+	return apierr
+}
+
+func NewQuotaExceededErrorf(format string, args ...any) *ApiError {
+	return newErrorf(CodeQuotaExceeded, QuotaExceeded, 403, format, args...)
+}
+
+// NewBannersQuotaError
+// Returns the error of the tenant having no banners left within the quota
+func NewBannersQuotaError(maxBanners int64) *ApiError {
+	return NewQuotaExceededErrorf("Превышена квота в %d баннеров", maxBanners)
+}
+
+func NewConflictError(errm string) *ApiError {
+	return &ApiError{
+		Code:        CodeConflict,
+		Description: Conflict,
+		ErrType:     errm,
+		HttpStatus:  409,
+	}
+}
+
+// NewPairConflictError
+// Returns the conflict naming the banner the feature-tag pair belongs to
+func NewPairConflictError(featureId int64, tagId int64, bannerId int64) *ApiError {
+	return newErrorf(
+		CodePairConflict, Conflict, 409,
+		"Пара feature_id=%d tag_id=%d уже принадлежит баннеру %d", featureId, tagId, bannerId,
+	)
+}
+
+// NewDefaultConflictError
+// Returns the conflict naming the default banner the feature already has
+func NewDefaultConflictError(featureId int64, bannerId int64) *ApiError {
+	return newErrorf(
+		CodeConflict, Conflict, 409,
+		"У фичи %d уже есть баннер по умолчанию %d", featureId, bannerId,
+	)
+}
+
+func newErrorf(code string, description string, status int, format string, args ...any) *ApiError {
+	return &ApiError{
+		Code:        code,
+		Description: description,
+		ErrType:     fmt.Sprintf(format, args...),
+		HttpStatus:  status,
+		format:      format,
+		args:        args,
 	}
 }
 
 func (apierr *ApiError) Error() string {
-	return apierr.ErrType
+	return apierr.Message(LangRu)
 }
@@ -0,0 +1,139 @@
+package serverr
+
+import (
+	"fmt"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util"
+	"net/http"
+	"strings"
+)
+
+// languages of error messages, messages are written in russian and translated
+const (
+	LangRu = "ru"
+	LangEn = "en"
+)
+
+// fieldErrorFormat is the message of the field that failed validation
+const fieldErrorFormat = "Поле '%s' не прошло проверку '%s'"
+
+// translations of messages and their formats, keyed by the russian ones
+var translations = map[string]map[string]string{
+	LangEn: {
+		// descriptions
+		UserUnauthorized: "User is not authorized",
+		AccessRestricted: "User has no access",
+		InvalidData:      "Invalid data",
+		ServerConflict:   "Internal server error",
+		BannerNotFound:   "Banner not found",
+		FeatureNotFound:  "Feature not found",
+		TagNotFound:      "Tag not found",
+		RequestTimeout:   "Request processing time exceeded",
+		TooManyRequests:  "Too many requests",
+		QuotaExceeded:    "Quota exceeded",
+		Conflict:         "Conflict with existing data",
+
+		// defined errors
+		"Неверный формат запроса":                     "Invalid request format",
+		"Ошибка хранилища данных":                     "Data storage error",
+		"Ошибка парсинга токена":                      "Unable to parse the token",
+		"Непредвиденная ошибка при обработке запроса": "Unexpected error while processing the request",
+		"Превышен лимит запросов, повторите позже":    "Request limit exceeded, try again later",
+		"Запрос отменён или превышено время ожидания": "Request is cancelled or timed out",
+
+		// request parameters and body
+		fieldErrorFormat: "Field '%s' failed the '%s' check",
+		"Некорректные данные запроса":                        "Invalid request data",
+		"Некорректное значение '%s'":                         "Invalid value of '%s'",
+		"Некорректное значение 'mode'":                       "Invalid value of 'mode'",
+		"Некорректное значение feature_id":                   "Invalid value of feature_id",
+		"Некорректное значение tag_id":                       "Invalid value of tag_id",
+		"Больше %d tag_id в одном запросе":                   "More than %d tag_id in one request",
+		"Некорректное значение use_last_revision":            "Invalid value of use_last_revision",
+		"Некорректное значение locale":                       "Invalid value of locale",
+		"Параметр version недоступен в режиме resolve":       "The version parameter is not available in resolve mode",
+		"Некорректное значение version":                      "Invalid value of version",
+		"Отсутствует параметр 'bannerId'":                    "Parameter 'bannerId' is missing",
+		"Отсутствует параметр 'versionId'":                   "Parameter 'versionId' is missing",
+		"Отсутствует параметр 'to'":                          "Parameter 'to' is missing",
+		"Неверный формат параметра 'bannerId'":               "Invalid format of parameter 'bannerId'",
+		"Неверный формат параметра 'versionId'":              "Invalid format of parameter 'versionId'",
+		"Неверный формат параметра 'id'":                     "Invalid format of parameter 'id'",
+		"'feature_id' и 'tag_id' не установлены":             "Neither 'feature_id' nor 'tag_id' is set",
+		"Укажите либо feature_id, либо tag_id в отдельности": "Specify either feature_id or tag_id, not both",
+		"Некорректный tenant '%s'":                           "Invalid tenant '%s'",
+		"Неизвестное окружение '%s'":                         "Unknown environment '%s'",
+
+		// catalog
+		"Указанный feature_id не существует": "The feature_id does not exist",
+		"Указанный tag_id не существует":     "The tag_id does not exist",
+		"Все/некоторые tag_id не существуют": "Some or all tag_ids do not exist",
+		"Фича используется баннерами":        "The feature is used by banners",
+		"Тег используется баннерами":         "The tag is used by banners",
+		"tag_id %d указан несколько раз":     "tag_id %d is given more than once",
+
+		// banners
+		"Указанный external_key уже используется":                 "The external_key is already used",
+		"Баннер с указанным external_key удалён":                  "The banner with the external_key is deleted",
+		"Пара feature_id-tag_id принадлежит другому баннеру":      "The feature_id-tag_id pair belongs to another banner",
+		"Пара feature_id=%d tag_id=%d уже принадлежит баннеру %d": "The pair feature_id=%d tag_id=%d already belongs to banner %d",
+		"У фичи %d уже есть баннер по умолчанию %d":               "Feature %d already has default banner %d",
+		"У фичи уже есть баннер по умолчанию":                     "The feature already has a default banner",
+		"Баннер изменён другим запросом, повторите запрос":        "The banner is changed by another request, retry the request",
+
+		// bulk operations, import and promotion
+		"Тело запроса должно быть массивом":                                               "Request body must be an array",
+		"Пустой массив баннеров":                                                          "Empty array of banners",
+		"Больше %d баннеров в одном запросе":                                              "More than %d banners in one request",
+		"Пара feature_id=%d tag_id=%d повторяется в элементе %d":                          "The pair feature_id=%d tag_id=%d is already used by item %d",
+		"banner_id повторяется в элементе %d":                                             "banner_id is already used by item %d",
+		"external_key повторяется в элементе %d":                                          "external_key is already used by item %d",
+		"Баннер по умолчанию фичи %d повторяется в элементе %d":                           "Default banner of feature %d is already set by item %d",
+		"Некорректный JSON: %v":                                                           "Invalid JSON: %v",
+		"Нет баннеров для загрузки":                                                       "No banners to import",
+		"Не удалось прочитать строку %d: %v":                                              "Unable to read line %d: %v",
+		"external_key повторяется в строке %d":                                            "external_key is already used by line %d",
+		"Пара feature_id=%d tag_id=%d повторяется в строке %d":                            "The pair feature_id=%d tag_id=%d is already used by line %d",
+		"Баннер по умолчанию фичи %d повторяется в строке %d":                             "Default banner of feature %d is already set by line %d",
+		"Баннер уже находится в окружении '%s'":                                           "The banner is already in environment '%s'",
+		"Баннер в окружении '%s' удалён, восстановите его":                                "The banner in environment '%s' is deleted, restore it",
+		"В окружении '%s' баннеру соответствует несколько баннеров, укажите external_key": "Several banners of environment '%s' match the banner, set external_key",
+
+		// quotas
+		"Размер content превышает квоту в %d байт": "Size of content exceeds the quota of %d bytes",
+		"Превышена квота в %d баннеров":            "Quota of %d banners is exceeded",
+	},
+}
+
+// Lang
+// Returns the language of messages preferred in Accept-Language, russian if none of them is supported
+func Lang(r *http.Request) string {
+	for _, tag := range util.AcceptedLanguages(r.Header.Get("Accept-Language")) {
+		// region is ignored, en-US is en
+		primary, _, _ := strings.Cut(tag, "-")
+		if primary == LangRu || primary == LangEn {
+			return primary
+		}
+	}
+
+	return LangRu
+}
+
+// translated reports whether the message has a translation to lang
+func translated(lang string, msg string) bool {
+	_, ok := translations[lang][msg]
+	return ok
+}
+
+// translate
+// Returns the message in lang formatted with args, untranslated messages are kept in russian
+func translate(lang string, format string, args ...any) string {
+	if t, ok := translations[lang][format]; ok {
+		format = t
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS before_insert_banner_version ON banner_version;
+DROP FUNCTION IF EXISTS delete_old_banner_versions();
+
+DROP TABLE IF EXISTS banner_version;
+DROP TABLE IF EXISTS banners_tags;
+DROP TABLE IF EXISTS banners;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS features;
@@ -0,0 +1,3 @@
+-- fails if banners still reference the features
+DELETE FROM tags;
+DELETE FROM features;
@@ -0,0 +1,7 @@
+-- features and tags are not managed through the API,
+-- the service is provisioned with a fixed set of them
+INSERT INTO features(name)
+SELECT 'Feature ' || generate_series(1, 2000);
+
+INSERT INTO tags(name)
+SELECT 'Tag ' || generate_series(1, 2000);
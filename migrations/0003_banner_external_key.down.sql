@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS banners_external_key_idx;
+
+ALTER TABLE banners DROP COLUMN IF EXISTS external_key;
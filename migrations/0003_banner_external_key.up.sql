@@ -0,0 +1,4 @@
+-- stable key of a banner across environments, used to upsert on import
+ALTER TABLE banners ADD COLUMN external_key VARCHAR(255);
+
+CREATE UNIQUE INDEX banners_external_key_idx ON banners (external_key);
@@ -0,0 +1,7 @@
+-- fails if the same external key is used in several environments
+DROP INDEX IF EXISTS banners_environment_feature_idx;
+
+DROP INDEX IF EXISTS banners_external_key_idx;
+CREATE UNIQUE INDEX banners_external_key_idx ON banners (external_key);
+
+ALTER TABLE banners DROP COLUMN IF EXISTS environment;
@@ -0,0 +1,8 @@
+-- banners of every environment share the database, feature-tag pairs
+-- and external keys are unique within an environment
+ALTER TABLE banners ADD COLUMN environment VARCHAR(32) NOT NULL DEFAULT 'production';
+
+DROP INDEX banners_external_key_idx;
+CREATE UNIQUE INDEX banners_external_key_idx ON banners (environment, external_key);
+
+CREATE INDEX banners_environment_feature_idx ON banners (environment, feature_id);
@@ -0,0 +1,13 @@
+-- fails if the same external key is used by several tenants
+DROP INDEX IF EXISTS banners_scope_feature_idx;
+CREATE INDEX banners_environment_feature_idx ON banners (environment, feature_id);
+
+DROP INDEX IF EXISTS banners_external_key_idx;
+CREATE UNIQUE INDEX banners_external_key_idx ON banners (environment, external_key);
+
+DROP INDEX IF EXISTS tags_tenant_idx;
+DROP INDEX IF EXISTS features_tenant_idx;
+
+ALTER TABLE banners DROP COLUMN IF EXISTS tenant;
+ALTER TABLE tags DROP COLUMN IF EXISTS tenant;
+ALTER TABLE features DROP COLUMN IF EXISTS tenant;
@@ -0,0 +1,14 @@
+-- every product is a tenant with its own features, tags and banners,
+-- rows created before tenants belong to the default one
+ALTER TABLE features ADD COLUMN tenant VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE tags ADD COLUMN tenant VARCHAR(64) NOT NULL DEFAULT 'default';
+ALTER TABLE banners ADD COLUMN tenant VARCHAR(64) NOT NULL DEFAULT 'default';
+
+CREATE INDEX features_tenant_idx ON features (tenant);
+CREATE INDEX tags_tenant_idx ON tags (tenant);
+
+DROP INDEX banners_external_key_idx;
+CREATE UNIQUE INDEX banners_external_key_idx ON banners (tenant, environment, external_key);
+
+DROP INDEX banners_environment_feature_idx;
+CREATE INDEX banners_scope_feature_idx ON banners (tenant, environment, feature_id);
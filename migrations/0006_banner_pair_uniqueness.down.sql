@@ -0,0 +1,12 @@
+ALTER TABLE banners_tags DROP CONSTRAINT IF EXISTS banners_tags_pair_key;
+
+DROP TRIGGER IF EXISTS after_update_banner_scope ON banners;
+DROP FUNCTION IF EXISTS copy_banner_scope_to_tags();
+
+DROP TRIGGER IF EXISTS before_insert_banners_tags ON banners_tags;
+DROP FUNCTION IF EXISTS copy_banner_scope_to_tag();
+
+ALTER TABLE banners_tags
+    DROP COLUMN IF EXISTS feature_id,
+    DROP COLUMN IF EXISTS environment,
+    DROP COLUMN IF EXISTS tenant;
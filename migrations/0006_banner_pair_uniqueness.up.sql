@@ -0,0 +1,55 @@
+-- feature-tag pair belongs to one banner of the tenant and environment. The scope of the banner
+-- is copied to its tags, so the rule is kept by a unique constraint. The constraint is checked
+-- on commit: a banner moved to other pairs may clash with itself until all its rows are changed.
+-- fails if pairs are already duplicated, such banners must be changed before
+ALTER TABLE banners_tags
+    ADD COLUMN tenant      VARCHAR(64),
+    ADD COLUMN environment VARCHAR(32),
+    ADD COLUMN feature_id  BIGINT;
+
+UPDATE banners_tags bt
+SET tenant      = b.tenant,
+    environment = b.environment,
+    feature_id  = b.feature_id
+FROM banners b
+WHERE b.id = bt.banner_id;
+
+-- function copying the scope of the banner to its new tag
+CREATE OR REPLACE FUNCTION copy_banner_scope_to_tag() RETURNS TRIGGER AS $$
+BEGIN
+    SELECT b.tenant, b.environment, b.feature_id
+    INTO NEW.tenant, NEW.environment, NEW.feature_id
+    FROM banners b
+    WHERE b.id = NEW.banner_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER before_insert_banners_tags
+    BEFORE INSERT ON banners_tags
+    FOR EACH ROW
+EXECUTE FUNCTION copy_banner_scope_to_tag();
+
+-- function moving tags of the banner along with it
+CREATE OR REPLACE FUNCTION copy_banner_scope_to_tags() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE banners_tags
+    SET tenant      = NEW.tenant,
+        environment = NEW.environment,
+        feature_id  = NEW.feature_id
+    WHERE banner_id = NEW.id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER after_update_banner_scope
+    AFTER UPDATE OF tenant, environment, feature_id ON banners
+    FOR EACH ROW
+    WHEN (OLD.tenant IS DISTINCT FROM NEW.tenant
+        OR OLD.environment IS DISTINCT FROM NEW.environment
+        OR OLD.feature_id IS DISTINCT FROM NEW.feature_id)
+EXECUTE FUNCTION copy_banner_scope_to_tags();
+
+ALTER TABLE banners_tags
+    ADD CONSTRAINT banners_tags_pair_key UNIQUE (tenant, environment, feature_id, tag_id)
+        DEFERRABLE INITIALLY DEFERRED;
@@ -0,0 +1,4 @@
+-- content variants of a banner by locale, content itself is the default variant.
+-- versions keep the variants too, so setting a version restores all of them
+ALTER TABLE banners ADD COLUMN locales JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE banner_version ADD COLUMN locales JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS banners_default_key;
+ALTER TABLE banners
+    DROP COLUMN IF EXISTS is_default,
+    DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,8 @@
+-- priority decides between banners of the feature matching several tags of the user,
+-- the default banner of the feature is served when none of the tags has a banner
+ALTER TABLE banners
+    ADD COLUMN priority   INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN is_default BOOL    NOT NULL DEFAULT false;
+
+-- feature has at most one default banner in the tenant and environment
+CREATE UNIQUE INDEX banners_default_key ON banners (tenant, environment, feature_id) WHERE is_default;
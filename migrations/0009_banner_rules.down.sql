@@ -0,0 +1,2 @@
+ALTER TABLE banner_version DROP COLUMN IF EXISTS rule;
+ALTER TABLE banners DROP COLUMN IF EXISTS rule;
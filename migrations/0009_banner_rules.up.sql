@@ -0,0 +1,4 @@
+-- targeting rule of a banner over request attributes, empty rule targets every user.
+-- versions keep the rule together with tags, so setting a version restores the targeting
+ALTER TABLE banners ADD COLUMN rule TEXT NOT NULL DEFAULT '';
+ALTER TABLE banner_version ADD COLUMN rule TEXT NOT NULL DEFAULT '';
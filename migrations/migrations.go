@@ -0,0 +1,10 @@
+// Package migrations
+// Versioned database schema, files are named <version>_<name>.up.sql and
+// <version>_<name>.down.sql and are applied by internal/migrate in version order.
+// Applied files must never be changed, add a new migration instead
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// createBanner creates the banner as admin and returns its id
+func (suite *BannerHandlerSuite) createBanner(body string) int64 {
+	rec := suite.serveWith(suite.router, "POST", "/api/v1/banner", "aap_1", body)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	var created dto.CreateBannerResponseDto
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+
+	return created.BannerId
+}
+
+// createCatalogItem creates the feature or tag as admin and returns its id
+func (suite *BannerHandlerSuite) createCatalogItem(path string, name string) int64 {
+	rec := suite.serveWith(suite.router, "POST", "/api/v1"+path, "aap_1", `{"name": "`+name+`"}`)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	var created dto.CreateCatalogItemResponseDto
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+
+	return created.Id
+}
+
+// banners of feature 22 are not in the test data
+func (suite *BannerHandlerSuite) TestBannerById() {
+	id := suite.createBanner(`{"feature_id": 22, "tag_ids": [1, 2], "content": {"title": "by id"}, "is_active": true}`)
+	path := "/api/v1/banner/" + strconv.FormatInt(id, 10)
+
+	suite.Run("Admin", func() {
+		rec := suite.serveWith(suite.router, "GET", path, "aap_1", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var banner dto.FilterBannersResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &banner))
+		suite.Equal(id, banner.BannerId)
+		suite.Equal(int64(22), banner.FeatureId)
+		suite.ElementsMatch([]int64{1, 2}, banner.TagIds)
+		suite.JSONEq(`{"title": "by id"}`, string(banner.Content))
+	})
+
+	suite.Run("User", func() {
+		rec := suite.serveWith(suite.router, "GET", path, "aup_1", "")
+		suite.Equal(http.StatusForbidden, rec.Code)
+	})
+
+	suite.Run("NotFound", func() {
+		rec := suite.serveWith(suite.router, "GET", "/api/v1/banner/999999", "aap_1", "")
+		suite.Equal(http.StatusNotFound, rec.Code)
+	})
+
+	suite.Run("InvalidId", func() {
+		rec := suite.serveWith(suite.router, "GET", "/api/v1/banner/abc", "aap_1", "")
+		suite.Equal(http.StatusBadRequest, rec.Code)
+	})
+}
+
+// banners of feature 23 are not in the test data
+func (suite *BannerHandlerSuite) TestBannerRestore() {
+	id := suite.createBanner(`{"feature_id": 23, "tag_ids": [1], "content": {"title": "restored"}, "is_active": true}`)
+	path := "/api/v1/banner/" + strconv.FormatInt(id, 10)
+	const userBanner = "/api/v1/user_banner?feature_id=23&tag_id=1&use_last_revision=true"
+
+	suite.Run("NotDeleted", func() {
+		rec := suite.serveWith(suite.router, "POST", path+"/restore", "aap_1", "")
+		suite.Equal(http.StatusNotFound, rec.Code)
+	})
+
+	suite.Run("Deleted", func() {
+		rec := suite.serveWith(suite.router, "DELETE", path, "aap_1", "")
+		suite.Require().Equal(http.StatusNoContent, rec.Code, rec.Body.String())
+		suite.Equal(http.StatusNotFound, suite.serveWith(suite.router, "GET", userBanner, "aup_1", "").Code)
+
+		rec = suite.serveWith(suite.router, "POST", path+"/restore", "aup_1", "")
+		suite.Equal(http.StatusForbidden, rec.Code)
+
+		rec = suite.serveWith(suite.router, "POST", path+"/restore", "aap_1", "")
+		suite.Require().Equal(http.StatusNoContent, rec.Code, rec.Body.String())
+
+		rec = suite.serveWith(suite.router, "GET", userBanner, "aup_1", "")
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.JSONEq(`{"content": {"title": "restored"}}`, rec.Body.String())
+	})
+}
+
+func (suite *BannerHandlerSuite) TestCatalog() {
+	suite.Run("AdminOnly", func() {
+		for _, path := range []string{"/api/v1/feature", "/api/v1/tag"} {
+			rec := suite.serveWith(suite.router, "GET", path, "aup_1", "")
+			suite.Equal(http.StatusForbidden, rec.Code, path)
+		}
+	})
+
+	suite.Run("FeatureCrud", func() {
+		id := suite.createCatalogItem("/feature", "catalog feature")
+
+		rec := suite.serveWith(suite.router, "GET", "/api/v1/feature?offset=2000", "aap_1", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		var items []models.CatalogItem
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &items))
+		suite.Contains(items, models.CatalogItem{Id: id, Name: "catalog feature"}, "seeded features go first")
+
+		path := "/api/v1/feature/" + strconv.FormatInt(id, 10)
+		suite.Equal(http.StatusNoContent, suite.serveWith(suite.router, "DELETE", path, "aap_1", "").Code)
+		suite.Equal(http.StatusNotFound, suite.serveWith(suite.router, "DELETE", path, "aap_1", "").Code)
+	})
+
+	suite.Run("TagInUse", func() {
+		id := suite.createCatalogItem("/tag", "catalog tag")
+		banner := suite.createBanner(`{"feature_id": 24, "tag_ids": [` + strconv.FormatInt(id, 10) + `], "content": {}, "is_active": true}`)
+
+		// deleting the tag would silently unbind it from the banner
+		path := "/api/v1/tag/" + strconv.FormatInt(id, 10)
+		suite.Equal(http.StatusConflict, suite.serveWith(suite.router, "DELETE", path, "aap_1", "").Code)
+
+		rec := suite.serveWith(suite.router, "GET", "/api/v1/banner/"+strconv.FormatInt(banner, 10), "aap_1", "")
+		suite.Require().Equal(http.StatusOK, rec.Code)
+		suite.Contains(rec.Body.String(), `"tag_ids":[`+strconv.FormatInt(id, 10)+`]`)
+	})
+
+	suite.Run("InvalidName", func() {
+		rec := suite.serveWith(suite.router, "POST", "/api/v1/tag", "aap_1", `{"name": ""}`)
+		suite.Equal(http.StatusBadRequest, rec.Code)
+	})
+}
+
+// banners of feature 25 are not in the test data
+func (suite *BannerHandlerSuite) TestBannerctl() {
+	bin := filepath.Join(suite.T().TempDir(), "bannerctl")
+	build, err := exec.Command("go", "build", "-o", bin, "../cmd/bannerctl").CombinedOutput()
+	suite.Require().NoError(err, string(build))
+
+	srv := httptest.NewServer(suite.router)
+	defer srv.Close()
+
+	bannerctl := func(stdin string, args ...string) (string, error) {
+		cmd := exec.Command(bin, append([]string{"-url", srv.URL + "/api/v1", "-token", "aap_1", "-o", "json"}, args...)...)
+		cmd.Stdin = strings.NewReader(stdin)
+
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			return stderr.String(), err
+		}
+
+		return stdout.String(), nil
+	}
+
+	var created struct {
+		Id int64 `json:"id"`
+	}
+	out, err := bannerctl(`{"feature_id": 25, "tag_ids": [1], "content": {"title": "cli"}, "is_active": true}`, "create", "-f", "-")
+	suite.Require().NoError(err, out)
+	suite.Require().NoError(json.Unmarshal([]byte(out), &created))
+	id := strconv.FormatInt(created.Id, 10)
+
+	suite.Run("Get", func() {
+		out, err := bannerctl("", "get", id)
+		suite.Require().NoError(err, out)
+
+		var banner dto.FilterBannersResponseDto
+		suite.Require().NoError(json.Unmarshal([]byte(out), &banner))
+		suite.Equal(int64(25), banner.FeatureId)
+		suite.False(banner.ToDelete)
+	})
+
+	suite.Run("DeleteAndRestore", func() {
+		out, err := bannerctl("", "delete", id)
+		suite.Require().NoError(err, out)
+		suite.Equal(http.StatusNotFound, suite.getUserBanner(25, 1))
+
+		out, err = bannerctl("", "restore", id)
+		suite.Require().NoError(err, out)
+		suite.Equal(http.StatusOK, suite.getUserBanner(25, 1))
+	})
+
+	suite.Run("Catalog", func() {
+		out, err := bannerctl("", "tags", "create", "cli tag")
+		suite.Require().NoError(err, out)
+		suite.Require().NoError(json.Unmarshal([]byte(out), &created))
+
+		out, err = bannerctl("", "tags", "delete", strconv.FormatInt(created.Id, 10))
+		suite.NoError(err, out)
+
+		// tag 1 is used by banners, the api error is reported with its status
+		out, err = bannerctl("", "tags", "delete", "1")
+		suite.Error(err)
+		suite.Contains(out, "409")
+	})
+
+	suite.Run("ApiError", func() {
+		out, err := bannerctl("", "get", "999999")
+		suite.Error(err)
+		suite.Contains(out, "404")
+	})
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/util/serverr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApiErrorLanguage(t *testing.T) {
+	testCases := []struct {
+		name           string
+		acceptLanguage string
+		expectedLang   string
+	}{
+		{name: "Default", acceptLanguage: "", expectedLang: serverr.LangRu},
+		{name: "English", acceptLanguage: "en-US,en;q=0.9", expectedLang: serverr.LangEn},
+		{name: "Preferred by weight", acceptLanguage: "en;q=0.5, ru;q=0.8", expectedLang: serverr.LangRu},
+		{name: "Unsupported is skipped", acceptLanguage: "de, en;q=0.3", expectedLang: serverr.LangEn},
+		{name: "Only unsupported", acceptLanguage: "de, fr", expectedLang: serverr.LangRu},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+
+			assert.Equal(t, tc.expectedLang, serverr.Lang(req))
+		})
+	}
+}
+
+func TestApiErrorBody(t *testing.T) {
+	testCases := []struct {
+		name            string
+		apierr          *serverr.ApiError
+		acceptLanguage  string
+		expectedStatus  int
+		expectedCode    string
+		expectedMessage string
+	}{
+		{
+			name:            "Description without message",
+			apierr:          serverr.UserUnauthorizedError,
+			expectedStatus:  http.StatusUnauthorized,
+			expectedCode:    serverr.CodeUnauthorized,
+			expectedMessage: serverr.UserUnauthorized,
+		},
+		{
+			name:            "Translated format",
+			apierr:          serverr.NewPairConflictError(1, 2, 3),
+			acceptLanguage:  "en",
+			expectedStatus:  http.StatusConflict,
+			expectedCode:    serverr.CodePairConflict,
+			expectedMessage: "The pair feature_id=1 tag_id=2 already belongs to banner 3",
+		},
+		{
+			name:            "Untranslated message falls back to description",
+			apierr:          serverr.NewInvalidRequestError("Сообщение без перевода"),
+			acceptLanguage:  "en",
+			expectedStatus:  http.StatusBadRequest,
+			expectedCode:    serverr.CodeInvalidRequest,
+			expectedMessage: "Invalid data",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+
+			rec := httptest.NewRecorder()
+			tc.apierr.Write(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+
+			var body dto.ErrorResponseDto
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tc.expectedCode, body.Code)
+			assert.Equal(t, tc.expectedMessage, body.Error)
+		})
+	}
+}
+
+func TestApiErrorValidationDetails(t *testing.T) {
+	banner := dto.CreateBannerDto{Content: json.RawMessage(`{}`)}
+	apierr := banner.Validate(dto.NewValidator())
+	require.NotNil(t, apierr)
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set("Accept-Language", "en")
+
+	rec := httptest.NewRecorder()
+	apierr.Write(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, serverr.LangEn, rec.Header().Get("Content-Language"))
+
+	var body dto.ErrorResponseDto
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, serverr.CodeValidationFailed, body.Code)
+
+	fields := make(map[string]string)
+	for _, d := range body.Details {
+		fields[d.Field] = d.Rule
+		assert.NotEmpty(t, d.Message)
+	}
+	assert.Equal(t, map[string]string{"tag_ids": "required", "feature_id": "required"}, fields)
+}
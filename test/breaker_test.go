@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// call lets the call through the breaker and reports its result
+func call(t *testing.T, cb *repo.CircuitBreaker, err error) {
+	gen, aerr := cb.Allow()
+	require.NoError(t, aerr)
+	cb.Report(gen, err)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := repo.NewCircuitBreaker(repo.BreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      50 * time.Millisecond,
+	}, nil)
+	failure := errors.New("connection refused")
+
+	// cache misses are not failures
+	call(t, cb, redis.Nil)
+	call(t, cb, redis.Nil)
+	assert.Equal(t, repo.BreakerClosed, cb.State())
+
+	// consecutive failures open the circuit
+	call(t, cb, failure)
+	assert.Equal(t, repo.BreakerClosed, cb.State())
+	call(t, cb, failure)
+	assert.Equal(t, repo.BreakerOpen, cb.State())
+	_, err := cb.Allow()
+	assert.ErrorIs(t, err, repo.ErrCircuitOpen)
+
+	// after timeout a single probe is let through
+	time.Sleep(60 * time.Millisecond)
+	probe, err := cb.Allow()
+	assert.NoError(t, err)
+	assert.Equal(t, repo.BreakerHalfOpen, cb.State())
+	_, err = cb.Allow()
+	assert.ErrorIs(t, err, repo.ErrCircuitOpen)
+
+	// failed probe opens the circuit again
+	cb.Report(probe, failure)
+	assert.Equal(t, repo.BreakerOpen, cb.State())
+
+	// successful probe closes it
+	time.Sleep(60 * time.Millisecond)
+	call(t, cb, nil)
+	assert.Equal(t, repo.BreakerClosed, cb.State())
+	_, err = cb.Allow()
+	assert.NoError(t, err)
+}
+
+func TestCircuitBreakerIgnoresCancelledRequests(t *testing.T) {
+	cb := repo.NewCircuitBreaker(repo.BreakerConfig{FailureThreshold: 2}, nil)
+
+	for i := 0; i < 5; i++ {
+		call(t, cb, context.Canceled)
+		call(t, cb, fmt.Errorf("redis: %w", context.DeadlineExceeded))
+	}
+
+	assert.Equal(t, repo.BreakerClosed, cb.State())
+}
+
+func TestCircuitBreakerCancelledProbe(t *testing.T) {
+	cb := repo.NewCircuitBreaker(repo.BreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      10 * time.Millisecond,
+	}, nil)
+	call(t, cb, errors.New("connection refused"))
+
+	// cancelled probe neither closes nor opens the circuit, the next probe is let through
+	time.Sleep(20 * time.Millisecond)
+	call(t, cb, context.Canceled)
+	assert.Equal(t, repo.BreakerHalfOpen, cb.State())
+	call(t, cb, nil)
+	assert.Equal(t, repo.BreakerClosed, cb.State())
+}
+
+func TestCircuitBreakerLateReport(t *testing.T) {
+	cb := repo.NewCircuitBreaker(repo.BreakerConfig{
+		FailureThreshold: 1,
+		OpenTimeout:      10 * time.Millisecond,
+	}, nil)
+	failure := errors.New("connection refused")
+
+	// the call is made while the circuit is closed and completes after it's opened
+	late, err := cb.Allow()
+	require.NoError(t, err)
+	call(t, cb, failure)
+	assert.Equal(t, repo.BreakerOpen, cb.State())
+
+	cb.Report(late, nil)
+	assert.Equal(t, repo.BreakerOpen, cb.State())
+
+	// nor does it close the half-open circuit or let another probe through
+	time.Sleep(20 * time.Millisecond)
+	probe, err := cb.Allow()
+	require.NoError(t, err)
+	cb.Report(late, nil)
+	assert.Equal(t, repo.BreakerHalfOpen, cb.State())
+	_, err = cb.Allow()
+	assert.ErrorIs(t, err, repo.ErrCircuitOpen)
+
+	cb.Report(probe, nil)
+	assert.Equal(t, repo.BreakerClosed, cb.State())
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+)
+
+// banners of feature 13 are not in the test data
+func (suite *BannerHandlerSuite) TestBulkCreateAndChange() {
+	suite.Run("AtomicBatchWithClashIsNotApplied", func() {
+		body := `[
+			{"feature_id": 13, "tag_ids": [1, 2], "content": {"title": "a"}, "is_active": true},
+			{"feature_id": 13, "tag_ids": [2, 3], "content": {"title": "b"}, "is_active": true}
+		]`
+		resp := suite.bulk("POST", "", body, http.StatusBadRequest)
+
+		suite.Equal(0, resp.Applied)
+		suite.Equal(1, resp.Failed)
+		suite.Empty(resp.Items[0].Error)
+		suite.Contains(resp.Items[1].Error, "элементе 0")
+
+		suite.Equal(http.StatusNotFound, suite.getUserBanner(13, 1))
+	})
+
+	var ids []int64
+	suite.Run("AtomicBatchIsCreated", func() {
+		body := `[
+			{"feature_id": 13, "tag_ids": [1, 2], "content": {"title": "a"}, "is_active": true},
+			{"feature_id": 13, "tag_ids": [3], "content": {"title": "b"}, "is_active": true}
+		]`
+		resp := suite.bulk("POST", "", body, http.StatusCreated)
+
+		suite.Equal(2, resp.Applied)
+		for _, item := range resp.Items {
+			suite.Positive(item.BannerId)
+			ids = append(ids, item.BannerId)
+		}
+
+		suite.Equal(http.StatusOK, suite.getUserBanner(13, 3))
+	})
+
+	suite.Run("BestEffortSkipsFailedItems", func() {
+		body := `[
+			{"feature_id": 13, "tag_ids": [4], "content": {"title": "c"}, "is_active": true},
+			{"feature_id": 13, "tag_ids": [1], "content": {"title": "taken"}, "is_active": true},
+			{"feature_id": 13, "content": {"title": "no tags"}}
+		]`
+		resp := suite.bulk("POST", "?mode=best_effort", body, http.StatusOK)
+
+		suite.Equal(1, resp.Applied)
+		suite.Equal(2, resp.Failed)
+		suite.Positive(resp.Items[0].BannerId)
+	})
+
+	suite.Run("AtomicPatch", func() {
+		body := `[
+			{"banner_id": ` + strconv.FormatInt(ids[0], 10) + `, "content": {"title": "a v2"}},
+			{"banner_id": ` + strconv.FormatInt(ids[1], 10) + `, "tag_ids": [2], "is_active": false}
+		]`
+		resp := suite.bulk("PATCH", "", body, http.StatusBadRequest)
+
+		suite.Equal(0, resp.Applied)
+		suite.Contains(resp.Items[1].Error, "уже принадлежит баннеру "+strconv.FormatInt(ids[0], 10))
+
+		body = `[
+			{"banner_id": ` + strconv.FormatInt(ids[0], 10) + `, "content": {"title": "a v2"}},
+			{"banner_id": ` + strconv.FormatInt(ids[1], 10) + `, "tag_ids": [5], "is_active": false}
+		]`
+		resp = suite.bulk("PATCH", "", body, http.StatusOK)
+
+		suite.Equal(2, resp.Applied)
+		suite.Equal(http.StatusOK, suite.getUserBanner(13, 5), "banner is visible to admin")
+	})
+}
+
+func (suite *BannerHandlerSuite) bulk(method string, query string, body string, expectedStatus int) dto.BulkResponseDto {
+	req := httptest.NewRequest(method, "/api/v1/banner/bulk"+query, strings.NewReader(body))
+	req.Header.Set("X-Access-Token", "aap_1")
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, req)
+	suite.Require().Equal(expectedStatus, rec.Code, rec.Body.String())
+
+	var resp dto.BulkResponseDto
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	return resp
+}
+
+func (suite *BannerHandlerSuite) getUserBanner(featureId int64, tagId int64) int {
+	req := httptest.NewRequest(
+		"GET",
+		"/api/v1/user_banner?feature_id="+strconv.FormatInt(featureId, 10)+"&tag_id="+strconv.FormatInt(tagId, 10)+"&use_last_revision=true",
+		nil,
+	)
+	req.Header.Set("X-Access-Token", "aap_1")
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, req)
+
+	return rec.Code
+}
+
+// banners of feature 26 are not in the test data
+func (suite *BannerHandlerSuite) TestBulkDefaultBanners() {
+	suite.Run("DefaultRepeatedInBatch", func() {
+		body := `[
+			{"feature_id": 26, "tag_ids": [1], "content": {"title": "a"}, "is_active": true, "is_default": true},
+			{"feature_id": 26, "tag_ids": [2], "content": {"title": "b"}, "is_active": true, "is_default": true}
+		]`
+		resp := suite.bulk("POST", "", body, http.StatusBadRequest)
+
+		suite.Equal(0, resp.Applied)
+		suite.Equal(1, resp.Failed)
+		suite.Empty(resp.Items[0].Error)
+		suite.Contains(resp.Items[1].Error, "элементе 0")
+	})
+
+	var id int64
+	suite.Run("DefaultOfAnotherBanner", func() {
+		body := `[
+			{"feature_id": 26, "tag_ids": [1], "content": {"title": "a"}, "is_active": true, "is_default": true},
+			{"feature_id": 26, "tag_ids": [2], "content": {"title": "b"}, "is_active": true}
+		]`
+		resp := suite.bulk("POST", "", body, http.StatusCreated)
+		id = resp.Items[0].BannerId
+
+		body = `[
+			{"feature_id": 26, "tag_ids": [3], "content": {"title": "c"}, "is_active": true, "is_default": true},
+			{"feature_id": 26, "tag_ids": [4], "content": {"title": "d"}, "is_active": true}
+		]`
+		resp = suite.bulk("POST", "?mode=best_effort", body, http.StatusOK)
+
+		suite.Equal(1, resp.Applied)
+		suite.Contains(resp.Items[0].Error, "баннер по умолчанию "+strconv.FormatInt(id, 10))
+		suite.Positive(resp.Items[1].BannerId)
+	})
+
+	suite.Run("DefaultBannerChangesItself", func() {
+		body := `[{"banner_id": ` + strconv.FormatInt(id, 10) + `, "content": {"title": "a v2"}, "is_default": true}]`
+		resp := suite.bulk("PATCH", "", body, http.StatusOK)
+
+		suite.Equal(1, resp.Applied)
+	})
+
+	suite.Run("ItemErrorsInEnglish", func() {
+		body := `[
+			{"feature_id": 26, "tag_ids": [5], "content": {"title": "e"}, "is_active": true, "is_default": true},
+			{"feature_id": 26, "tag_ids": [1], "content": {"title": "f"}, "is_active": true}
+		]`
+		req := httptest.NewRequest("POST", "/api/v1/banner/bulk", strings.NewReader(body))
+		req.Header.Set("X-Access-Token", "aap_1")
+		req.Header.Set("Accept-Language", "en")
+
+		rec := httptest.NewRecorder()
+		suite.router.ServeHTTP(rec, req)
+		suite.Require().Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+		suite.Equal("en", rec.Header().Get("Content-Language"))
+
+		var resp dto.BulkResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+		suite.Equal("Feature 26 already has default banner "+strconv.FormatInt(id, 10), resp.Items[0].Error)
+		suite.Equal("The pair feature_id=26 tag_id=1 already belongs to banner "+strconv.FormatInt(id, 10), resp.Items[1].Error)
+	})
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (suite *BannerHandlerSuite) serveWith(router *mux.Router, method string, url string, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("X-Access-Token", token)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// cachedEntry returns the cached default content of the pair, empty if it's not cached
+func (suite *BannerHandlerSuite) cachedEntry(featureTag string) string {
+	key := service.DefaultTenant + ":" + service.EnvironmentProduction + ":" + featureTag
+
+	return suite.rediscli.HGet(context.Background(), key, "default").Val()
+}
+
+// banners of feature 19 are not in the test data
+func (suite *BannerHandlerSuite) TestNegativeCaching() {
+	router := suite.newRouter(suite.newService(service.CacheConfig{NegativeTtl: time.Minute}))
+	const path = "/api/v1/user_banner?feature_id=19&tag_id=1"
+
+	suite.Run("MissIsCached", func() {
+		rec := suite.serveWith(router, "GET", path, "aup_1", "")
+		suite.Equal(http.StatusNotFound, rec.Code)
+		suite.Contains(suite.cachedEntry("19_1"), `"not_found":true`)
+
+		// served from the negative entry
+		rec = suite.serveWith(router, "GET", path, "aup_1", "")
+		suite.Equal(http.StatusNotFound, rec.Code)
+	})
+
+	suite.Run("CreateInvalidates", func() {
+		rec := suite.serveWith(router, "POST", "/api/v1/banner", "aap_1",
+			`{"feature_id": 19, "tag_ids": [1], "content": {"title": "created"}, "is_active": true}`)
+		suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+		suite.Empty(suite.cachedEntry("19_1"))
+
+		rec = suite.serveWith(router, "GET", path, "aup_1", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		suite.JSONEq(`{"content": {"title": "created"}}`, rec.Body.String())
+		suite.NotContains(suite.cachedEntry("19_1"), "not_found")
+	})
+}
+
+// banners of feature 20 are not in the test data
+func (suite *BannerHandlerSuite) TestInactiveBannerVisibility() {
+	rec := suite.serveWith(suite.router, "POST", "/api/v1/banner", "aap_1",
+		`{"feature_id": 20, "tag_ids": [1], "content": {"title": "inactive"}, "is_active": false}`)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	testCases := []struct {
+		name         string
+		token        string
+		expectedCode int
+	}{
+		{name: "Admin", token: "aap_1", expectedCode: http.StatusOK},
+		{name: "User", token: "aup_1", expectedCode: http.StatusNotFound},
+	}
+
+	suite.Run("Database", func() {
+		for _, tc := range testCases {
+			rec := suite.serveWith(suite.router, "GET", "/api/v1/user_banner?feature_id=20&tag_id=1&use_last_revision=true", tc.token, "")
+			suite.Equal(tc.expectedCode, rec.Code, tc.name)
+		}
+		suite.Empty(suite.cachedEntry("20_1"))
+	})
+
+	suite.Run("Cache", func() {
+		// the first lookup caches the banner, inactive one is cached too
+		suite.serveWith(suite.router, "GET", "/api/v1/user_banner?feature_id=20&tag_id=1", "aap_1", "")
+		suite.Require().Contains(suite.cachedEntry("20_1"), `"is_active":false`)
+
+		for _, tc := range testCases {
+			rec := suite.serveWith(suite.router, "GET", "/api/v1/user_banner?feature_id=20&tag_id=1", tc.token, "")
+			suite.Equal(tc.expectedCode, rec.Code, tc.name)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRepo serves the banner once released, counting database reads
+type fakeRepo struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{release: make(chan struct{})}
+}
+
+func (fr *fakeRepo) get(ctx context.Context) (models.BannerModel, error) {
+	fr.calls.Add(1)
+
+	select {
+	case <-fr.release:
+		return models.BannerModel{Id: 1}, nil
+	case <-ctx.Done():
+		return models.BannerModel{}, ctx.Err()
+	}
+}
+
+func TestCoalescerSharesLoad(t *testing.T) {
+	co := service.NewCoalescer(time.Second)
+	fr := newFakeRepo()
+
+	const lookups = 10
+	var wg sync.WaitGroup
+	var shared atomic.Int32
+	for i := 0; i < lookups; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			banner, s, err := co.Load(context.Background(), "1_1", fr.get)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), banner.Id)
+			if s {
+				shared.Add(1)
+			}
+		}()
+	}
+
+	// let every lookup join the flight before it completes
+	require.Eventually(t, func() bool { return fr.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(fr.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), fr.calls.Load())
+	assert.Equal(t, int32(lookups), shared.Load())
+}
+
+func TestCoalescerAbandonedLookup(t *testing.T) {
+	co := service.NewCoalescer(time.Second)
+	fr := newFakeRepo()
+
+	// the request that started the load is gone, the load goes on for the one waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := co.Load(ctx, "1_1", fr.get)
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return fr.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	waiting := make(chan models.BannerModel)
+	go func() {
+		banner, _, _ := co.Load(context.Background(), "1_1", fr.get)
+		waiting <- banner
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	time.Sleep(20 * time.Millisecond)
+	close(fr.release)
+	assert.Equal(t, int64(1), (<-waiting).Id)
+	assert.Equal(t, int32(1), fr.calls.Load())
+}
+
+func TestCoalescerLoadTimeout(t *testing.T) {
+	co := service.NewCoalescer(20 * time.Millisecond)
+	fr := newFakeRepo()
+
+	// database never answers, the shared load is bounded by its own timeout
+	_, _, err := co.Load(context.Background(), "1_1", fr.get)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	// the failed load isn't remembered
+	close(fr.release)
+	banner, _, err := co.Load(context.Background(), "1_1", fr.get)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), banner.Id)
+}
+
+func TestShouldRefreshEarly(t *testing.T) {
+	refreshes := func(remaining time.Duration, delta time.Duration, beta float64) int {
+		n := 0
+		for i := 0; i < 1000; i++ {
+			if service.ShouldRefreshEarly(remaining, delta, beta) {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	testCases := []struct {
+		name      string
+		remaining time.Duration
+		delta     time.Duration
+		beta      float64
+		min, max  int
+	}{
+		{name: "Disabled", remaining: time.Millisecond, delta: time.Second, beta: 0, min: 0, max: 0},
+		{name: "NoLoadsYet", remaining: time.Millisecond, delta: 0, beta: 1, min: 0, max: 0},
+		{name: "Expired", remaining: 0, delta: time.Second, beta: 1, min: 0, max: 0},
+		{name: "FarFromExpiry", remaining: 5 * time.Minute, delta: 10 * time.Millisecond, beta: 1, min: 0, max: 0},
+		// P = exp(-remaining / (delta * beta)) = exp(-1) ≈ 0.37
+		{name: "NearExpiry", remaining: 10 * time.Millisecond, delta: 10 * time.Millisecond, beta: 1, min: 300, max: 440},
+		{name: "AboutToExpire", remaining: time.Microsecond, delta: 10 * time.Millisecond, beta: 1, min: 990, max: 1000},
+		// higher beta refreshes earlier, exp(-1/4) ≈ 0.78
+		{name: "HigherBeta", remaining: 10 * time.Millisecond, delta: 10 * time.Millisecond, beta: 4, min: 720, max: 840},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := refreshes(tc.remaining, tc.delta, tc.beta)
+			assert.GreaterOrEqual(t, n, tc.min)
+			assert.LessOrEqual(t, n, tc.max)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// banners of feature 14 are not in the test data
+func (suite *BannerHandlerSuite) TestPairConflict() {
+	create := func(body string) int64 {
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "", body)
+		suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+		var created dto.CreateBannerResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+
+		return created.BannerId
+	}
+
+	first := create(`{"feature_id": 14, "tag_ids": [1, 2], "content": {"title": "first"}, "is_active": true}`)
+	second := create(`{"feature_id": 14, "tag_ids": [3], "content": {"title": "second"}, "is_active": true}`)
+	owner := "уже принадлежит баннеру " + strconv.FormatInt(first, 10)
+
+	suite.Run("CreateOnTakenPair", func() {
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "", `{"feature_id": 14, "tag_ids": [2], "content": {}}`)
+		suite.Equal(http.StatusConflict, rec.Code)
+		suite.Contains(rec.Body.String(), owner)
+	})
+
+	suite.Run("PatchOntoTakenPair", func() {
+		path := "/api/v1/banner/" + strconv.FormatInt(second, 10)
+		rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"tag_ids": [3, 2]}`)
+		suite.Equal(http.StatusConflict, rec.Code)
+		suite.Contains(rec.Body.String(), owner)
+
+		suite.Equal(http.StatusOK, suite.getUserBanner(14, 3), "banner is not changed")
+	})
+
+	suite.Run("PatchKeepingOwnPairs", func() {
+		path := "/api/v1/banner/" + strconv.FormatInt(first, 10)
+		rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"tag_ids": [2, 1, 4]}`)
+		suite.Equal(http.StatusOK, rec.Code, rec.Body.String())
+	})
+}
+
+// banners of feature 27 are not in the test data
+func (suite *BannerHandlerSuite) TestConcurrentPatch() {
+	id := suite.createBanner(`{"feature_id": 27, "tag_ids": [1], "content": {"title": "v1"}, "is_active": true}`)
+	path := "/api/v1/banner/" + strconv.FormatInt(id, 10)
+
+	// every change is based on the first revision, the ones applied after another are rejected
+	codes := make([]int, 8)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			body := `{"content": {"title": "v` + strconv.Itoa(i+2) + `"}}`
+			codes[i] = suite.serveWith(suite.router, "PATCH", path, "aap_1", body).Code
+		}()
+	}
+	wg.Wait()
+
+	changed := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			changed++
+		} else {
+			suite.Equal(http.StatusConflict, code)
+		}
+	}
+	suite.Positive(changed)
+
+	rec := suite.serveWith(suite.router, "PATCH", path, "aap_1", `{"content": {"title": "last"}}`)
+	suite.Equal(http.StatusOK, rec.Code, "the banner is changed after the race")
+}
+
+// banners of feature 28 are not in the test data
+func (suite *BannerHandlerSuite) TestDeletedBannerKeepsPairs() {
+	id := suite.createBanner(`{"feature_id": 28, "tag_ids": [1, 2], "content": {"title": "deleted"}, "is_active": true}`)
+	path := "/api/v1/banner/" + strconv.FormatInt(id, 10)
+	const body = `{"feature_id": 28, "tag_ids": [2], "content": {"title": "new"}, "is_active": true}`
+
+	rec := suite.serveWith(suite.router, "DELETE", path, "aap_1", "")
+	suite.Require().Equal(http.StatusNoContent, rec.Code, rec.Body.String())
+
+	suite.Run("PairsAreTakenUntilPurge", func() {
+		rec := suite.serveWith(suite.router, "POST", "/api/v1/banner", "aap_1", body)
+		suite.Equal(http.StatusConflict, rec.Code)
+		suite.Contains(rec.Body.String(), "уже принадлежит баннеру "+strconv.FormatInt(id, 10))
+	})
+
+	suite.Run("RestoredWithItsPairs", func() {
+		rec := suite.serveWith(suite.router, "POST", path+"/restore", "aap_1", "")
+		suite.Require().Equal(http.StatusNoContent, rec.Code, rec.Body.String())
+		suite.Equal(http.StatusOK, suite.getUserBanner(28, 2))
+	})
+
+	suite.Run("PairsAreReleasedByPurge", func() {
+		rec := suite.serveWith(suite.router, "DELETE", path, "aap_1", "")
+		suite.Require().Equal(http.StatusNoContent, rec.Code, rec.Body.String())
+		suite.Require().NoError(repo.NewBannerRepository(suite.pool, zap.NewNop().Sugar()).DeleteMarkedBanners(context.Background()))
+
+		suite.createBanner(body)
+	})
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/apiserver"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeadlineMiddlewareUsesRouteDeadline(t *testing.T) {
+	deadlines := apiserver.Deadlines{
+		Default: time.Minute,
+		Routes: map[string]time.Duration{
+			"/api/v1/user_banner": 50 * time.Millisecond,
+			"/healthz":            0,
+		},
+	}
+
+	left := make(map[string]time.Duration)
+	router := mux.NewRouter()
+	router.Use(deadlines.DeadlineMiddleware)
+	subrouter := router.PathPrefix("/api/v1").Subrouter()
+
+	record := func(route string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if deadline, ok := r.Context().Deadline(); ok {
+				left[route] = time.Until(deadline)
+			}
+		}
+	}
+	subrouter.HandleFunc("/user_banner", record("user_banner"))
+	subrouter.HandleFunc("/banner", record("banner"))
+	router.HandleFunc("/healthz", record("healthz"))
+
+	for _, path := range []string{"/api/v1/user_banner", "/api/v1/banner", "/healthz"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.LessOrEqual(t, left["user_banner"], 50*time.Millisecond)
+	assert.Greater(t, left["banner"], 50*time.Millisecond)
+	assert.NotContains(t, left, "healthz")
+}
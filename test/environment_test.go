@@ -0,0 +1,57 @@
+package test
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnvironmentSelection(t *testing.T) {
+	router := mux.NewRouter()
+	subrouter := router.PathPrefix("/api/v1").Subrouter()
+	subrouter.Use(service.TokenValidationMiddleware)
+	subrouter.Use(service.EnvironmentMiddleware(service.EnvironmentConfig{
+		Names:   []string{"production", "staging"},
+		Default: "production",
+	}))
+	subrouter.HandleFunc("/user_banner", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(service.Environment(r.Context())))
+	})
+
+	testCases := []struct {
+		name           string
+		token          string
+		header         string
+		expectedStatus int
+		expectedEnv    string
+	}{
+		{name: "Default", token: "aup_1", expectedStatus: http.StatusOK, expectedEnv: "production"},
+		{name: "Header", token: "aup_1", header: "staging", expectedStatus: http.StatusOK, expectedEnv: "staging"},
+		{name: "Token", token: "aup_1@staging", expectedStatus: http.StatusOK, expectedEnv: "staging"},
+		{name: "Token and the same header", token: "aup_1@staging", header: "staging", expectedStatus: http.StatusOK, expectedEnv: "staging"},
+		{name: "Header can't override token", token: "aup_1@staging", header: "production", expectedStatus: http.StatusForbidden},
+		{name: "Unknown header", token: "aup_1", header: "qa", expectedStatus: http.StatusBadRequest},
+		{name: "Unknown token environment", token: "aup_1@qa", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/user_banner", nil)
+			req.Header.Set("X-Access-Token", tc.token)
+			if tc.header != "" {
+				req.Header.Set(service.EnvironmentHeader, tc.header)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedEnv != "" {
+				assert.Equal(t, tc.expectedEnv, rec.Body.String())
+			}
+		})
+	}
+}
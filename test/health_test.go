@@ -0,0 +1,50 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/migrate"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+)
+
+func TestReadinessHidesStorageErrors(t *testing.T) {
+	pool, rc := stalledStorage(t)
+	defer pool.Close()
+	defer rc.Close()
+
+	version, err := migrate.Latest(migrations.FS)
+	require.NoError(t, err)
+
+	logger := zap.NewNop().Sugar()
+	br := repo.NewBannerRepository(pool, logger)
+	cr := repo.NewCacheRepo(rc, repo.BreakerConfig{}, logger)
+	bs := service.NewBannerService(br, cr, service.CacheConfig{}, service.EnvironmentConfig{}, service.TenantConfig{}, logger)
+	defer bs.Stop(context.Background())
+	hs := service.NewHealthService(pool, br, cr, bs, version, logger)
+
+	report := hs.Readiness(context.Background())
+	assert.Equal(t, service.StatusUnavailable, report.Status)
+	for _, check := range []string{"postgres", "schema", "redis"} {
+		assert.Equal(t, service.StatusFail, report.Checks[check], check)
+	}
+
+	// public report carries no addresses or driver messages
+	body, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "127.0.0.1")
+}
+
+func TestLatestMigration(t *testing.T) {
+	loaded, err := migrate.Load(migrations.FS)
+	require.NoError(t, err)
+
+	version, err := migrate.Latest(migrations.FS)
+	require.NoError(t, err)
+	assert.Equal(t, loaded[len(loaded)-1].Version, version)
+}
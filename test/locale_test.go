@@ -0,0 +1,114 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestLocaleChain(t *testing.T) {
+	testCases := []struct {
+		name           string
+		locale         string
+		acceptLanguage string
+		expected       []string
+	}{
+		{name: "Empty", expected: nil},
+		{name: "Parameter", locale: "pt-br", expected: []string{"pt-br", "pt"}},
+		{name: "Header by weight", acceptLanguage: "en;q=0.5, de-AT", expected: []string{"de-at", "de", "en"}},
+		{name: "Parameter goes first", locale: "en", acceptLanguage: "en-GB, fr", expected: []string{"en", "en-gb", "fr"}},
+		{name: "Invalid tags are skipped", acceptLanguage: "*, en_US", expected: []string{"en-us", "en"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, service.LocaleChain(tc.locale, tc.acceptLanguage))
+		})
+	}
+}
+
+// banners of feature 15 are not in the test data
+func (suite *BannerHandlerSuite) TestLocalizedContent() {
+	body := `{"feature_id": 15, "tag_ids": [1], "content": {"title": "привет"}, "is_active": true,
+		"locales": {"en": {"title": "hello"}, "pt-br": {"title": "olá"}}}`
+	rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "", body)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	var created dto.CreateBannerResponseDto
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+	path := "/api/v1/banner/" + strconv.FormatInt(created.BannerId, 10)
+
+	get := func(query string, acceptLanguage string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/user_banner?feature_id=15&tag_id=1"+query, nil)
+		req.Header.Set("X-Access-Token", "aup_1")
+		req.Header.Set("Accept-Language", acceptLanguage)
+
+		rec := httptest.NewRecorder()
+		suite.router.ServeHTTP(rec, req)
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		return rec
+	}
+
+	testCases := []struct {
+		name           string
+		query          string
+		acceptLanguage string
+		expectedTitle  string
+		expectedLocale string
+	}{
+		{name: "Default", expectedTitle: "привет"},
+		{name: "Parameter", query: "&locale=pt-BR", expectedTitle: "olá", expectedLocale: "pt-br"},
+		{name: "Parent of header", acceptLanguage: "en-US", expectedTitle: "hello", expectedLocale: "en"},
+		{name: "Parameter over header", query: "&locale=en", acceptLanguage: "pt-BR", expectedTitle: "hello", expectedLocale: "en"},
+		{name: "Unknown falls back to default", acceptLanguage: "de", expectedTitle: "привет"},
+	}
+
+	// every case runs twice, the second time the banner comes from cache
+	for _, cached := range []bool{false, true} {
+		for _, tc := range testCases {
+			suite.Run(tc.name, func() {
+				query := tc.query
+				if !cached {
+					query += "&use_last_revision=true"
+				}
+
+				rec := get(query, tc.acceptLanguage)
+				suite.JSONEq(`{"content": {"title": "`+tc.expectedTitle+`"}}`, rec.Body.String())
+				suite.Equal(tc.expectedLocale, rec.Header().Get("Content-Language"))
+			})
+		}
+	}
+
+	suite.Run("InvalidLocale", func() {
+		rec := suite.serveInEnvironment("GET", "/api/v1/user_banner?feature_id=15&tag_id=1&locale=*", "aup_1", "", "")
+		suite.Equal(http.StatusBadRequest, rec.Code)
+	})
+
+	suite.Run("PatchWithInvalidLocale", func() {
+		rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"locales": {"pt-BR": {"title": "olá"}}}`)
+		suite.Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+
+		rec = get("&use_last_revision=true", "pt-BR")
+		suite.JSONEq(`{"content": {"title": "olá"}}`, rec.Body.String(), "banner is not changed")
+	})
+
+	suite.Run("VersionKeepsLocales", func() {
+		rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"locales": {"de": {"title": "hallo"}}}`)
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = get("&use_last_revision=true", "en")
+		suite.JSONEq(`{"content": {"title": "привет"}}`, rec.Body.String(), "variants are replaced")
+
+		rec = suite.serveInEnvironment("PATCH", path+"/ver/1", "aap_1", "", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = get("&use_last_revision=true", "en")
+		suite.JSONEq(`{"content": {"title": "hello"}}`, rec.Body.String())
+	})
+}
@@ -6,11 +6,17 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mBayzigitov/dynamic-content-service/internal/apiserver"
 	"github.com/mBayzigitov/dynamic-content-service/internal/handler/banner"
+	"github.com/mBayzigitov/dynamic-content-service/internal/handler/catalog"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/migrate"
 	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
 	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/mBayzigitov/dynamic-content-service/migrations"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
 	"log"
+	"os"
 	"time"
 )
 
@@ -19,6 +25,45 @@ type BannerHandlerSuite struct {
 	router   *mux.Router
 	pool     *pgxpool.Pool
 	rediscli *redis.Client
+	services []*service.BannerService // stopped after every test
+}
+
+// SetupSuite
+// Recreates the test database with the same migrations as in production and loads test data
+func (suite *BannerHandlerSuite) SetupSuite() {
+	conf, err := apiserver.GetAppConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	logger, _ := zap.NewDevelopment()
+
+	pool, err := apiserver.ConnectPostgres(ctx, conf, logger.Sugar())
+	if err != nil {
+		log.Fatalf("Unable to connect to a database: %v\n", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, "DROP SCHEMA public CASCADE; CREATE SCHEMA public;"); err != nil {
+		log.Fatalf("Unable to clean the database: %v\n", err)
+	}
+
+	m, err := migrate.New(pool, migrations.FS, logger.Sugar())
+	if err == nil {
+		_, err = m.Up(ctx)
+	}
+	if err != nil {
+		log.Fatalf("Unable to migrate the database: %v\n", err)
+	}
+
+	data, err := os.ReadFile("testdata/load_data.sql")
+	if err == nil {
+		_, err = pool.Exec(ctx, string(data))
+	}
+	if err != nil {
+		log.Fatalf("Unable to load test data: %v\n", err)
+	}
 }
 
 func (suite *BannerHandlerSuite) SetupTest() {
@@ -27,37 +72,70 @@ func (suite *BannerHandlerSuite) SetupTest() {
 		log.Fatal(err)
 	}
 
-	time.Sleep(5 * time.Second) // wait until environment is load completely
+	logger, _ := zap.NewDevelopment()
 
-	dbURL := conf.Postgres.GetDbUrl()
-	pool, err := pgxpool.New(context.Background(), dbURL)
+	// wait until environment is load completely
+	pool, err := apiserver.ConnectPostgres(context.Background(), conf, logger.Sugar())
 	if err != nil {
 		log.Fatalf("Unable to connect to a database: %v\n", err)
 	}
 	suite.pool = pool
 
-	rediscli := redis.NewClient(&redis.Options{
-		Addr:     conf.Redis.Url,
-		Password: conf.Redis.Password,
-		DB:       conf.Redis.Db,
-	})
+	rediscli, err := apiserver.ConnectRedis(context.Background(), conf, logger.Sugar())
+	if err != nil {
+		log.Fatalf("Unable to connect to redis: %v\n", err)
+	}
 	suite.rediscli = rediscli
 
+	suite.router = suite.newRouter(suite.newService(service.CacheConfig{}))
+}
+
+// newService
+// Returns a new banner service with the cache config
+func (suite *BannerHandlerSuite) newService(cc service.CacheConfig) *service.BannerService {
+	logger, _ := zap.NewDevelopment()
+
+	cr := repo.NewCacheRepo(suite.rediscli, repo.BreakerConfig{}, logger.Sugar())
+	br := repo.NewBannerRepository(suite.pool, logger.Sugar())
+
+	bs := service.NewBannerService(br, cr, cc, service.EnvironmentConfig{}, service.TenantConfig{}, logger.Sugar())
+	suite.services = append(suite.services, bs)
+
+	return bs
+}
+
+// newRouter
+// Routes api requests to the banner service and the catalog of features and tags
+func (suite *BannerHandlerSuite) newRouter(bs *service.BannerService) *mux.Router {
+	logger, _ := zap.NewDevelopment()
+
 	router := mux.NewRouter()
+	router.Use(logging.RequestIdMiddleware)
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
 	subrouter.Use(service.TokenValidationMiddleware)
+	subrouter.Use(service.EnvironmentMiddleware(service.EnvironmentConfig{}))
+	subrouter.Use(service.TenantMiddleware)
 
-	cr := repo.NewCacheRepo(rediscli)
+	bh := banner.NewHandler(bs, logger.Sugar())
+	bh.RegisterRoutes(subrouter)
 
-	br := repo.NewBannerRepository(pool)
-	bs := service.NewBannerService(br, cr)
+	ch := catalog.NewHandler(service.NewCatalogService(repo.NewCatalogRepository(suite.pool, logger.Sugar())), logger.Sugar())
+	ch.RegisterRoutes(subrouter)
 
-	bh := banner.NewHandler(bs)
-	bh.RegisterRoutes(subrouter)
-	suite.router = router
+	return router
 }
 
-func (suite *BannerHandlerSuite) TearDownSuite() {
+// TearDownTest
+// Stops schedulers and background jobs of services of the test, then closes connections they use
+func (suite *BannerHandlerSuite) TearDownTest() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, bs := range suite.services {
+		suite.NoError(bs.Stop(ctx))
+	}
+	suite.services = nil
+
 	suite.pool.Close()
 	suite.rediscli.Close()
 }
@@ -0,0 +1,27 @@
+package test
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHttpMiddlewareUsesRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(metrics.HttpMiddleware)
+	router.HandleFunc("/banner/{bannerId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+
+	for _, id := range []string{"1", "2", "3"} {
+		req := httptest.NewRequest("DELETE", "/banner/"+id, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	counter := metrics.HttpRequests.WithLabelValues("/banner/{bannerId}", "DELETE", "204")
+	assert.Equal(t, float64(3), testutil.ToFloat64(counter))
+}
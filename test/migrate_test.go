@@ -0,0 +1,84 @@
+package test
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mBayzigitov/dynamic-content-service/internal/migrate"
+	"github.com/mBayzigitov/dynamic-content-service/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_index.up.sql":     {Data: []byte("CREATE INDEX i ON t (c);")},
+		"0002_add_index.down.sql":   {Data: []byte("DROP INDEX i;")},
+		"0001_init_schema.up.sql":   {Data: []byte("CREATE TABLE t (c INT);")},
+		"0001_init_schema.down.sql": {Data: []byte("DROP TABLE t;")},
+		"README.md":                 {Data: []byte("not a migration")},
+	}
+
+	loaded, err := migrate.Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+
+	assert.Equal(t, int64(1), loaded[0].Version)
+	assert.Equal(t, "init_schema", loaded[0].Name)
+	assert.Equal(t, "DROP TABLE t;", loaded[0].Down)
+	assert.Equal(t, int64(2), loaded[1].Version)
+	assert.NotEqual(t, loaded[0].Checksum, loaded[1].Checksum)
+
+	// checksum depends on the up script only
+	fsys["0002_add_index.down.sql"] = &fstest.MapFile{Data: []byte("DROP INDEX IF EXISTS i;")}
+	reloaded, err := migrate.Load(fsys)
+	require.NoError(t, err)
+	assert.Equal(t, loaded[1].Checksum, reloaded[1].Checksum)
+
+	// migration without down script is rejected
+	delete(fsys, "0002_add_index.down.sql")
+	_, err = migrate.Load(fsys)
+	assert.Error(t, err)
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := migrate.Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+
+	for i, m := range loaded {
+		assert.Equal(t, int64(i+1), m.Version, "migration versions must be sequential")
+	}
+}
+
+func TestMigratorRejectsInvalidArguments(t *testing.T) {
+	// arguments are checked before connecting, nothing listens on the address
+	pool, err := pgxpool.New(context.Background(), "postgres://user:password@"+unreachable+"/banners?connect_timeout=1")
+	require.NoError(t, err)
+	defer pool.Close()
+
+	m, err := migrate.New(pool, migrations.FS, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	// reverting everything must be asked for explicitly with DownAll
+	for _, steps := range []int{0, -1} {
+		n, err := m.Down(context.Background(), steps)
+		assert.ErrorContains(t, err, "must be positive")
+		assert.Zero(t, n)
+	}
+
+	_, err = m.Baseline(context.Background(), 1000)
+	assert.ErrorContains(t, err, "unknown migration version")
+}
+
+func (suite *BannerHandlerSuite) TestBaselineOfMigratedDatabase() {
+	m, err := migrate.New(suite.pool, migrations.FS, zap.NewNop().Sugar())
+	suite.Require().NoError(err)
+
+	// every migration is applied already, nothing is recorded twice
+	n, err := m.Baseline(context.Background(), 2)
+	suite.NoError(err)
+	suite.Zero(n)
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/models"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/redis/go-redis/v9"
+	"net/http"
+	"strconv"
+)
+
+// banners of features 18 and 32 are not in the test data
+func (suite *BannerHandlerSuite) TestBannerPreviewModes() {
+	create := func(body string) int64 {
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "", body)
+		suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+		var created dto.CreateBannerResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+
+		return created.BannerId
+	}
+
+	inactive := create(`{"feature_id": 18, "tag_ids": [1], "content": {"title": "v1"}, "locales": {"en": {"title": "v1 en"}}, "priority": 9}`)
+	ios := create(`{"feature_id": 18, "tag_ids": [2], "content": {"title": "ios"}, "is_active": true, "rule": "platform == \"ios\""}`)
+	high := create(`{"feature_id": 18, "tag_ids": [3], "content": {"title": "high"}, "is_active": true, "priority": 5}`)
+	low := create(`{"feature_id": 18, "tag_ids": [4], "content": {"title": "low"}, "is_active": true}`)
+	def := create(`{"feature_id": 18, "tag_ids": [5], "content": {"title": "default"}, "is_active": true, "is_default": true}`)
+
+	path := "/api/v1/banner/" + strconv.FormatInt(inactive, 10)
+	rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"content": {"title": "v2"}}`)
+	suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	preview := func(query string) dto.VersionPreviewResponseDto {
+		rec := suite.serveInEnvironment("GET", path+"/preview"+query, "aap_1", "", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp dto.VersionPreviewResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	suite.Run("CurrentVersion", func() {
+		resp := preview("")
+		suite.True(resp.Current)
+		suite.False(resp.IsActive)
+		suite.JSONEq(`{"title": "v2"}`, string(resp.Content))
+	})
+
+	suite.Run("OldVersion", func() {
+		resp := preview("?version=1&locale=en")
+		suite.Equal(int64(1), resp.Version)
+		suite.False(resp.Current)
+		suite.Equal("en", resp.Locale)
+		suite.JSONEq(`{"title": "v1 en"}`, string(resp.Content))
+	})
+
+	suite.Run("MissingVersion", func() {
+		rec := suite.serveInEnvironment("GET", path+"/preview?version=100", "aap_1", "", "")
+		suite.Equal(http.StatusNotFound, rec.Code)
+	})
+
+	suite.Run("UserToken", func() {
+		rec := suite.serveInEnvironment("GET", path+"/preview", "aup_1", "", "")
+		suite.Equal(http.StatusForbidden, rec.Code)
+	})
+
+	resolve := func(query string) dto.ResolveBannerResponseDto {
+		rec := suite.serveInEnvironment("GET", path+"/preview?mode=resolve"+query, "aap_1", "", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp dto.ResolveBannerResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	outcomes := func(resp dto.ResolveBannerResponseDto) map[int64]string {
+		byBanner := make(map[int64]string)
+		for _, c := range resp.Candidates {
+			byBanner[c.BannerId] = c.Outcome
+		}
+
+		return byBanner
+	}
+
+	suite.Run("Resolve", func() {
+		resp := resolve("&tag_id=1,2,3,4,6&platform=android")
+		suite.Require().NotNil(resp.Banner)
+		suite.Equal(high, resp.Banner.BannerId)
+		suite.Equal(map[int64]string{
+			inactive: models.OutcomeInactive,
+			ios:      models.OutcomeRuleMismatch,
+			high:     models.OutcomeChosen,
+			low:      models.OutcomeOutranked,
+			0:        models.OutcomeNoBanner,
+			def:      models.OutcomeDefaultUnused,
+		}, outcomes(resp))
+	})
+
+	suite.Run("ResolveTagsOfBanner", func() {
+		resp := resolve("")
+		suite.Require().NotNil(resp.Banner)
+		suite.Equal(def, resp.Banner.BannerId)
+		suite.Equal(map[int64]string{
+			inactive: models.OutcomeInactive,
+			def:      models.OutcomeChosen,
+		}, outcomes(resp))
+	})
+
+	suite.Run("ResolveAnotherFeature", func() {
+		other := create(`{"feature_id": 32, "tag_ids": [3], "content": {"title": "other"}, "is_active": true}`)
+
+		resp := resolve("&feature_id=32&tag_id=3,4")
+		suite.Require().NotNil(resp.Banner)
+		suite.Equal(other, resp.Banner.BannerId)
+		suite.Equal(map[int64]string{
+			other: models.OutcomeChosen,
+			0:     models.OutcomeNoBanner,
+		}, outcomes(resp))
+	})
+
+	suite.Run("ResolveDoesNotChangeCache", func() {
+		bs := suite.newService(service.CacheConfig{WarmUp: service.WarmUpConfig{Enabled: true, Limit: 10}})
+		router := suite.newRouter(bs)
+
+		rec := suite.serveWith(router, "GET", path+"/preview?mode=resolve&tag_id=3,4", "aap_1", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		for _, ft := range []string{"18_3", "18_4", "18_default"} {
+			suite.Empty(suite.cachedEntry(ft), ft)
+
+			key := service.DefaultTenant + ":" + service.EnvironmentProduction + ":" + ft
+			suite.ErrorIs(suite.rediscli.ZScore(context.Background(), service.HitsSet, key).Err(), redis.Nil, ft)
+		}
+	})
+
+	suite.Run("InvalidResolve", func() {
+		for _, query := range []string{"?mode=explain", "?mode=resolve&version=1", "?mode=resolve&tag_id=a",
+			"?mode=resolve&feature_id=0", "?mode=resolve&feature_id=a"} {
+			rec := suite.serveInEnvironment("GET", path+"/preview"+query, "aap_1", "", "")
+			suite.Equal(http.StatusBadRequest, rec.Code, query)
+		}
+	})
+}
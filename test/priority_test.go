@@ -0,0 +1,99 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"net/http"
+	"strconv"
+)
+
+// banners of feature 16 are not in the test data
+func (suite *BannerHandlerSuite) TestBannerResolution() {
+	create := func(body string) int64 {
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "", body)
+		suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+		var created dto.CreateBannerResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+
+		return created.BannerId
+	}
+
+	low := create(`{"feature_id": 16, "tag_ids": [1], "content": {"title": "low"}, "is_active": true, "priority": 1}`)
+	create(`{"feature_id": 16, "tag_ids": [2], "content": {"title": "high"}, "is_active": true, "priority": 5}`)
+	create(`{"feature_id": 16, "tag_ids": [3], "content": {"title": "high later"}, "is_active": true, "priority": 5}`)
+	create(`{"feature_id": 16, "tag_ids": [4], "content": {"title": "default"}, "is_active": true, "is_default": true}`)
+
+	title := func(query string) string {
+		rec := suite.serveInEnvironment("GET", "/api/v1/user_banner?feature_id=16&"+query, "aup_1", "", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp struct {
+			Content struct {
+				Title string `json:"title"`
+			} `json:"content"`
+		}
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+
+		return resp.Content.Title
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		expectedTitle string
+	}{
+		{name: "SingleTag", query: "tag_id=1", expectedTitle: "low"},
+		{name: "HighestPriority", query: "tag_id=1&tag_id=2", expectedTitle: "high"},
+		{name: "EqualPriorityLowestId", query: "tag_id=3,2", expectedTitle: "high"},
+		{name: "DefaultOfFeature", query: "tag_id=5", expectedTitle: "default"},
+		{name: "DefaultKeepsOwnTags", query: "tag_id=4", expectedTitle: "default"},
+	}
+
+	// every case runs twice, the second time banners come from cache
+	for _, cached := range []bool{false, true} {
+		for _, tc := range testCases {
+			suite.Run(tc.name, func() {
+				query := tc.query
+				if !cached {
+					query += "&use_last_revision=true"
+				}
+
+				suite.Equal(tc.expectedTitle, title(query))
+			})
+		}
+	}
+
+	suite.Run("SecondDefault", func() {
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "",
+			`{"feature_id": 16, "tag_ids": [6], "content": {}, "is_default": true}`)
+		suite.Equal(http.StatusConflict, rec.Code)
+	})
+
+	suite.Run("PriorityChange", func() {
+		path := "/api/v1/banner/" + strconv.FormatInt(low, 10)
+		rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"priority": 10}`)
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		suite.Equal("low", title("tag_id=1,2,3"))
+	})
+
+	suite.Run("InactiveIsSkipped", func() {
+		path := "/api/v1/banner/" + strconv.FormatInt(low, 10)
+		rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"is_active": false}`)
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		suite.Equal("high", title("tag_id=1,2"))
+		suite.Equal("default", title("tag_id=1"))
+	})
+
+	suite.Run("TooManyTags", func() {
+		query := "tag_id=1"
+		for i := 2; i <= 21; i++ {
+			query += "," + strconv.Itoa(i)
+		}
+
+		rec := suite.serveInEnvironment("GET", "/api/v1/user_banner?feature_id=16&"+query, "aup_1", "", "")
+		suite.Equal(http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,137 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+)
+
+// banners of feature 12 are not in the test data
+func (suite *BannerHandlerSuite) TestPromoteBanner() {
+	body := `{"feature_id": 12, "tag_ids": [1, 2], "content": {"title": "staging"}, "is_active": true}`
+	rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "staging", body)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	var created dto.CreateBannerResponseDto
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+	stagingPath := "/api/v1/banner/" + strconv.FormatInt(created.BannerId, 10)
+
+	userBanner := "/api/v1/user_banner?feature_id=12&tag_id=1&use_last_revision=true"
+
+	suite.Run("NotVisibleInProduction", func() {
+		rec := suite.serveInEnvironment("GET", userBanner, "aup_1", "", "")
+		suite.Equal(http.StatusNotFound, rec.Code)
+
+		rec = suite.serveInEnvironment("GET", userBanner, "aup_1@staging", "", "")
+		suite.Equal(http.StatusOK, rec.Code)
+	})
+
+	suite.Run("BoundTokenCantPromoteToAnotherEnvironment", func() {
+		rec := suite.serveInEnvironment("POST", stagingPath+"/promote?to=production", "aap_1@staging", "", "")
+		suite.Equal(http.StatusForbidden, rec.Code)
+	})
+
+	suite.Run("BoundTokenCantPromoteFromAnotherEnvironment", func() {
+		rec := suite.serveInEnvironment("POST", stagingPath+"/promote?to=production", "aap_1@production", "staging", "")
+		suite.Equal(http.StatusForbidden, rec.Code)
+	})
+
+	suite.Run("BannerOfAnotherEnvironment", func() {
+		rec := suite.serveInEnvironment("POST", stagingPath+"/promote?to=staging", "aap_1", "", "")
+		suite.Equal(http.StatusNotFound, rec.Code)
+	})
+
+	var promoted dto.PromoteBannerResponseDto
+	suite.Run("FirstPromotionCreatesBanner", func() {
+		rec := suite.serveInEnvironment("POST", stagingPath+"/promote?to=production", "aap_1", "staging", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &promoted))
+
+		suite.True(promoted.Created)
+		suite.NotEqual(created.BannerId, promoted.BannerId)
+
+		rec = suite.serveInEnvironment("GET", userBanner, "aup_1", "", "")
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.JSONEq(`{"content": {"title": "staging"}}`, rec.Body.String())
+	})
+
+	suite.Run("NextPromotionAddsVersion", func() {
+		rec := suite.serveInEnvironment("PATCH", stagingPath, "aap_1", "staging", `{"content": {"title": "staging v2"}}`)
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = suite.serveInEnvironment("POST", stagingPath+"/promote?to=production", "aap_1", "staging", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp dto.PromoteBannerResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+		suite.False(resp.Created)
+		suite.Equal(promoted.BannerId, resp.BannerId)
+		suite.Equal(int64(2), resp.Version)
+
+		rec = suite.serveInEnvironment("GET", userBanner, "aup_1", "", "")
+		suite.JSONEq(`{"content": {"title": "staging v2"}}`, rec.Body.String())
+	})
+}
+
+func (suite *BannerHandlerSuite) serveInEnvironment(method string, url string, token string, env string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("X-Access-Token", token)
+	if env != "" {
+		req.Header.Set(service.EnvironmentHeader, env)
+	}
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// banners of feature 30 are not in the test data
+func (suite *BannerHandlerSuite) TestBannerOfAnotherEnvironment() {
+	body := `{"feature_id": 30, "tag_ids": [1], "content": {"title": "staging"}, "is_active": true}`
+	rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "staging", body)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+	var created dto.CreateBannerResponseDto
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+	path := "/api/v1/banner/" + strconv.FormatInt(created.BannerId, 10)
+
+	requests := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{"GET", path, ""},
+		{"PATCH", path, `{"content": {"title": "production"}}`},
+		{"GET", path + "/ver", ""},
+		{"PATCH", path + "/ver/1", ""},
+		{"GET", path + "/preview", ""},
+		{"GET", path + "/preview?mode=resolve", ""},
+		{"DELETE", path, ""},
+		{"POST", path + "/restore", ""},
+		{"PATCH", "/api/v1/banner/bulk", `[{"banner_id": ` + strconv.FormatInt(created.BannerId, 10) + `, "is_active": false}]`},
+	}
+
+	for _, req := range requests {
+		rec := suite.serveInEnvironment(req.method, req.url, "aap_1", "", req.body)
+		if req.url == "/api/v1/banner/bulk" {
+			suite.Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+			suite.Contains(rec.Body.String(), "не найден")
+			continue
+		}
+		suite.Equal(http.StatusNotFound, rec.Code, req.method+" "+req.url)
+	}
+
+	rec = suite.serveInEnvironment("GET", path, "aap_1@staging", "", "")
+	suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	var banner dto.FilterBannersResponseDto
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &banner))
+	suite.JSONEq(`{"title": "staging"}`, string(banner.Content), "the banner is not changed")
+	suite.True(banner.IsActive)
+	suite.False(banner.ToDelete)
+}
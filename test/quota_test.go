@@ -0,0 +1,134 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+)
+
+// quotaRouter
+// Routes requests to the service limiting the tenant to maxBanners banners, the tenant
+// gets its own feature and tags, their ids are returned. Every test uses its own tenant
+func (suite *BannerHandlerSuite) quotaRouter(tenant string, maxBanners int64, tags int) (*mux.Router, int64, []int64) {
+	logger := zap.NewNop().Sugar()
+	tc := service.TenantConfig{Quotas: map[string]service.Quota{tenant: {MaxBanners: maxBanners}}}
+	bs := service.NewBannerService(repo.NewBannerRepository(suite.pool, logger), repo.NewCacheRepo(suite.rediscli, repo.BreakerConfig{}, logger),
+		service.CacheConfig{}, service.EnvironmentConfig{}, tc, logger)
+	suite.services = append(suite.services, bs)
+	router := suite.newRouter(bs)
+
+	create := func(path string) int64 {
+		rec := suite.serveWith(router, "POST", "/api/v1"+path, "aap_1#"+tenant, `{"name": "quota"}`)
+		suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+		var created struct {
+			Id int64 `json:"id"`
+		}
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+
+		return created.Id
+	}
+
+	featureId := create("/feature")
+	tagIds := make([]int64, tags)
+	for i := range tagIds {
+		tagIds[i] = create("/tag")
+	}
+
+	return router, featureId, tagIds
+}
+
+func (suite *BannerHandlerSuite) TestConcurrentCreationWithinQuota() {
+	const maxBanners = 3
+	router, featureId, tagIds := suite.quotaRouter("quota-single", maxBanners, 10)
+
+	// every request passes the early check, only the quota is let through
+	codes := make([]int, len(tagIds))
+	var wg sync.WaitGroup
+	for i, tagId := range tagIds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			body := `{"feature_id": ` + strconv.FormatInt(featureId, 10) + `, "tag_ids": [` + strconv.FormatInt(tagId, 10) + `], "content": {}, "is_active": true}`
+			codes[i] = suite.serveWith(router, "POST", "/api/v1/banner", "aap_1#quota-single", body).Code
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		} else {
+			suite.Equal(http.StatusForbidden, code)
+		}
+	}
+	suite.Equal(maxBanners, created)
+}
+
+func (suite *BannerHandlerSuite) TestConcurrentBulkCreationWithinQuota() {
+	const maxBanners = 4
+	router, featureId, tagIds := suite.quotaRouter("quota-bulk", maxBanners, 6)
+
+	// two atomic batches of 3 fit the quota one by one but not together
+	batch := func(tagIds []int64) string {
+		body := "["
+		for i, tagId := range tagIds {
+			if i > 0 {
+				body += ","
+			}
+			body += `{"feature_id": ` + strconv.FormatInt(featureId, 10) + `, "tag_ids": [` + strconv.FormatInt(tagId, 10) + `], "content": {}, "is_active": true}`
+		}
+
+		return body + "]"
+	}
+
+	codes := make([]int, 2)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = suite.serveWith(router, "POST", "/api/v1/banner/bulk", "aap_1#quota-bulk", batch(tagIds[i*3:i*3+3])).Code
+		}()
+	}
+	wg.Wait()
+
+	// the loser fails either the early check or the one in the transaction
+	suite.Contains(codes, http.StatusCreated)
+	suite.NotEqual(codes[0], codes[1])
+
+	rec := suite.serveWith(router, "GET", "/api/v1/banner?feature_id="+strconv.FormatInt(featureId, 10), "aap_1#quota-bulk", "")
+	suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	var banners []json.RawMessage
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &banners))
+	suite.Len(banners, 3)
+}
+
+func (suite *BannerHandlerSuite) TestRestoreWithinQuota() {
+	router, featureId, tagIds := suite.quotaRouter("quota-restore", 1, 2)
+	createBanner := func(tagId int64) *httptest.ResponseRecorder {
+		body := `{"feature_id": ` + strconv.FormatInt(featureId, 10) + `, "tag_ids": [` + strconv.FormatInt(tagId, 10) + `], "content": {}, "is_active": true}`
+		return suite.serveWith(router, "POST", "/api/v1/banner", "aap_1#quota-restore", body)
+	}
+
+	rec := createBanner(tagIds[0])
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		BannerId int64 `json:"banner_id"`
+	}
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+	path := "/api/v1/banner/" + strconv.FormatInt(created.BannerId, 10)
+
+	// deleted banner frees the quota, restoring it needs the quota back
+	suite.Require().Equal(http.StatusNoContent, suite.serveWith(router, "DELETE", path, "aap_1#quota-restore", "").Code)
+	suite.Require().Equal(http.StatusCreated, createBanner(tagIds[1]).Code)
+	suite.Equal(http.StatusForbidden, suite.serveWith(router, "POST", path+"/restore", "aap_1#quota-restore", "").Code)
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/ratelimit"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newRateLimitedRouter(limiter ratelimit.Limiter) *mux.Router {
+	rc := ratelimit.Config{
+		User:       ratelimit.Limit{Rate: 0.5, Burst: 3},
+		Admin:      ratelimit.Limit{Rate: 0.5, Burst: 1},
+		UserRoutes: []string{"/api/v1/user_banner"},
+	}
+
+	router := mux.NewRouter()
+	subrouter := router.PathPrefix("/api/v1").Subrouter()
+	subrouter.Use(ratelimit.NewRateLimiter(rc, limiter, zap.NewNop().Sugar()).Middleware)
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	subrouter.HandleFunc("/user_banner", ok)
+	subrouter.HandleFunc("/banner", ok)
+
+	return router
+}
+
+func limitedRequest(router *mux.Router, path string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if token != "" {
+		req.Header.Set("X-Access-Token", token)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestRateLimitPerTokenAndRoute(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter())
+
+	// user burst is 3
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, limitedRequest(router, "/api/v1/user_banner", "aup_1").Code)
+	}
+
+	rec := limitedRequest(router, "/api/v1/user_banner", "aup_1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, retryAfter)
+
+	// other token and admin routes have their own buckets
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "/api/v1/user_banner", "aup_2").Code)
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "/api/v1/banner", "aup_1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, "/api/v1/banner", "aup_1").Code)
+
+	// requests without token are limited by ip
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "/api/v1/banner", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, "/api/v1/banner", "").Code)
+}
+
+func TestRateLimitRotatingInvalidTokens(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter())
+
+	// invalid tokens share the bucket of the client ip, admin burst is 1
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "/api/v1/banner", "xyz_0").Code)
+	for i := 1; i < 5; i++ {
+		rec := limitedRequest(router, "/api/v1/banner", "xyz_"+strconv.Itoa(i))
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, "/api/v1/banner", "a").Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, "/api/v1/banner", "").Code)
+
+	// valid token has its own bucket
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "/api/v1/banner", "aap_1").Code)
+}
+
+func TestRedisRateLimitFallsBackToMemory(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: unreachable, MaxRetries: -1})
+	defer client.Close()
+
+	cr := repo.NewCacheRepo(client, repo.BreakerConfig{}, zap.NewNop().Sugar())
+	router := newRateLimitedRouter(ratelimit.NewRedisLimiter(cr, zap.NewNop().Sugar()))
+
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "/api/v1/banner", "aap_1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, "/api/v1/banner", "aap_1").Code)
+}
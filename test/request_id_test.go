@@ -0,0 +1,57 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIdInErrorResponse(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(logging.RequestIdMiddleware)
+	subrouter := router.PathPrefix("/api/v1").Subrouter()
+	subrouter.Use(service.TokenValidationMiddleware)
+	subrouter.HandleFunc("/user_banner", func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := []struct {
+		name      string
+		requestId string
+	}{
+		{name: "Propagated", requestId: "client-request-1"},
+		{name: "Generated", requestId: ""},
+		{name: "Invalid is replaced", requestId: "bad\tid"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/user_banner", nil)
+			if tc.requestId != "" {
+				req.Header.Set(logging.RequestIdHeader, tc.requestId)
+			}
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+			id := rec.Header().Get(logging.RequestIdHeader)
+			assert.NotEmpty(t, id)
+			if tc.name == "Propagated" {
+				assert.Equal(t, tc.requestId, id)
+			} else {
+				assert.NotEqual(t, tc.requestId, id)
+			}
+
+			var body struct {
+				RequestId string `json:"request_id"`
+			}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, id, body.RequestId)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mBayzigitov/dynamic-content-service/internal/apiserver"
+	"github.com/mBayzigitov/dynamic-content-service/internal/handler/banner"
+	"github.com/mBayzigitov/dynamic-content-service/internal/logging"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// unreachable is an address nothing listens on, every storage call fails
+const unreachable = "127.0.0.1:1"
+
+type ResilienceSuite struct {
+	suite.Suite
+	router *mux.Router
+	pool   *pgxpool.Pool
+	redis  *redis.Client
+	bs     *service.BannerService
+	bh     *banner.BannerHandler
+}
+
+func TestResilience(t *testing.T) {
+	suite.Run(t, new(ResilienceSuite))
+}
+
+func (suite *ResilienceSuite) SetupSuite() {
+	logger := zap.NewNop().Sugar()
+
+	// pool connects lazily, so it is created even if postgres is down
+	pool, err := pgxpool.New(context.Background(), "postgres://user:password@"+unreachable+"/banners?connect_timeout=1")
+	suite.Require().NoError(err)
+	suite.pool = pool
+	suite.redis = redis.NewClient(&redis.Options{Addr: unreachable, MaxRetries: -1})
+
+	router := mux.NewRouter()
+	router.Use(logging.RequestIdMiddleware)
+	router.Use(apiserver.RecoveryMiddleware(logger))
+	subrouter := router.PathPrefix("/api/v1").Subrouter()
+	subrouter.Use(service.TokenValidationMiddleware)
+
+	cr := repo.NewCacheRepo(suite.redis, repo.BreakerConfig{}, logger)
+	br := repo.NewBannerRepository(pool, logger)
+	suite.bs = service.NewBannerService(br, cr, service.CacheConfig{}, service.EnvironmentConfig{}, service.TenantConfig{}, logger)
+
+	suite.bh = banner.NewHandler(suite.bs, logger)
+	suite.bh.RegisterRoutes(subrouter)
+
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("unexpected state")
+	})
+	router.HandleFunc("/panic-after-write", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"content":`))
+		panic("unexpected state")
+	})
+	suite.router = router
+}
+
+func (suite *ResilienceSuite) TearDownSuite() {
+	suite.bs.Stop(context.Background())
+	suite.pool.Close()
+	suite.redis.Close()
+}
+
+func (suite *ResilienceSuite) serve(method string, path string, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Access-Token", token)
+	}
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func (suite *ResilienceSuite) TestStorageFailuresAreReturned() {
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+	}{
+		{name: "User banner", method: "GET", path: "/api/v1/user_banner?tag_id=1&feature_id=1", token: "aup_1"},
+		{name: "User banner, last revision", method: "GET", path: "/api/v1/user_banner?tag_id=1&feature_id=1&use_last_revision=true", token: "aup_1"},
+		{name: "Filter", method: "GET", path: "/api/v1/banner?feature_id=1", token: "aap_1"},
+		{name: "Change", method: "PATCH", path: "/api/v1/banner/1", token: "aap_1", body: `{"is_active": false}`},
+		{name: "Delete", method: "DELETE", path: "/api/v1/banner/1", token: "aap_1"},
+	}
+
+	// every request fails with 500 and the server keeps serving the next ones
+	for i := 0; i < 3; i++ {
+		for _, tc := range testCases {
+			suite.Run(tc.name, func() {
+				rec := suite.serve(tc.method, tc.path, tc.token, tc.body)
+				suite.Equal(http.StatusInternalServerError, rec.Code, rec.Body.String())
+			})
+		}
+	}
+}
+
+func (suite *ResilienceSuite) TestPanicIsRecovered() {
+	for i := 0; i < 2; i++ {
+		rec := suite.serve("GET", "/panic", "", "")
+		suite.Equal(http.StatusInternalServerError, rec.Code)
+
+		var body struct {
+			Error     string `json:"error"`
+			RequestId string `json:"request_id"`
+		}
+		suite.NoError(json.Unmarshal(rec.Body.Bytes(), &body))
+		suite.NotEmpty(body.Error)
+		suite.Equal(rec.Header().Get(logging.RequestIdHeader), body.RequestId)
+	}
+
+}
+
+func (suite *ResilienceSuite) TestPanicAfterResponseStarted() {
+	// the status is already sent, error body must not be appended to the partial response
+	rec := suite.serve("GET", "/panic-after-write", "", "")
+	suite.Equal(http.StatusOK, rec.Code)
+	suite.Equal(`{"content":`, rec.Body.String())
+}
+
+func (suite *ResilienceSuite) TestShortTokens() {
+	for _, token := range []string{"a", "aa", "ü"} {
+		rec := suite.serve("GET", "/api/v1/user_banner?tag_id=1&feature_id=1", token, "")
+		suite.Equal(http.StatusForbidden, rec.Code, token)
+	}
+}
+
+func (suite *ResilienceSuite) TestMissingTokenContextIsReturned() {
+	// routes registered without token validation have no isAdmin flag in context
+	router := mux.NewRouter()
+	suite.bh.RegisterRoutes(router)
+
+	req := httptest.NewRequest("DELETE", "/banner/1", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	suite.Equal(http.StatusInternalServerError, rec.Code)
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mBayzigitov/dynamic-content-service/internal/apiserver"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// freeAddr returns an address nothing listens on yet
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+// stalledStorage returns postgres pool and redis client failing every call, postgres
+// accepts connections but never answers, so a call takes about a second to fail
+func stalledStorage(t *testing.T) (*pgxpool.Pool, *redis.Client) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	pool, err := pgxpool.New(context.Background(), "postgres://user:password@"+l.Addr().String()+"/banners?connect_timeout=1")
+	require.NoError(t, err)
+
+	return pool, redis.NewClient(&redis.Options{Addr: unreachable, MaxRetries: -1})
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	pool, rc := stalledStorage(t)
+	addr := freeAddr(t)
+	serv := apiserver.New(&apiserver.AppConfig{ServerPort: addr}, zap.NewNop().Sugar(), pool, rc)
+
+	started := make(chan error)
+	go func() {
+		started <- serv.Start()
+	}()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// the request is in flight until postgres connection times out
+	responses := make(chan int)
+	go func() {
+		req, _ := http.NewRequest("GET", "http://"+addr+"/api/v1/user_banner?feature_id=1&tag_id=1&use_last_revision=true", nil)
+		req.Header.Set("X-Access-Token", "aup_1")
+
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			responses <- 0
+			return
+		}
+		resp.Body.Close()
+		responses <- resp.StatusCode
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, serv.Shutdown(ctx))
+
+	// the in-flight request is answered once postgres times out, the server doesn't accept new ones
+	assert.Equal(t, http.StatusGatewayTimeout, <-responses)
+	assert.NoError(t, <-started)
+	_, err := net.Dial("tcp", addr)
+	assert.Error(t, err)
+}
+
+func TestStopWaitsForBackgroundJobs(t *testing.T) {
+	pool, rc := stalledStorage(t)
+	defer pool.Close()
+	defer rc.Close()
+
+	logger := zap.NewNop().Sugar()
+	bs := service.NewBannerService(repo.NewBannerRepository(pool, logger), repo.NewCacheRepo(rc, repo.BreakerConfig{}, logger),
+		service.CacheConfig{WarmUp: service.WarmUpConfig{Enabled: true}}, service.EnvironmentConfig{}, service.TenantConfig{}, logger)
+	hs := service.NewHealthService(pool, repo.NewBannerRepository(pool, logger), repo.NewCacheRepo(rc, repo.BreakerConfig{}, logger), bs, 0, logger)
+
+	// warm-up is running until postgres connection times out
+	bs.StartWarmUp()
+	assert.False(t, bs.Ready())
+	assert.Equal(t, service.StatusOk, hs.Liveness().Status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, bs.Stop(ctx))
+
+	assert.True(t, bs.Ready(), "warm-up is finished before Stop returns")
+	assert.Equal(t, service.StatusUnavailable, hs.Liveness().Status)
+
+	// stopping again is a no-op
+	assert.NoError(t, bs.Stop(ctx))
+}
+
+func TestStopTimeout(t *testing.T) {
+	pool, rc := stalledStorage(t)
+	defer pool.Close()
+	defer rc.Close()
+
+	logger := zap.NewNop().Sugar()
+	bs := service.NewBannerService(repo.NewBannerRepository(pool, logger), repo.NewCacheRepo(rc, repo.BreakerConfig{}, logger),
+		service.CacheConfig{WarmUp: service.WarmUpConfig{Enabled: true}}, service.EnvironmentConfig{}, service.TenantConfig{}, logger)
+	bs.StartWarmUp()
+
+	// background jobs outlive the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bs.Stop(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,143 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"github.com/mBayzigitov/dynamic-content-service/internal/targeting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestTargetingRuleMatch(t *testing.T) {
+	attrs := targeting.Attributes{
+		targeting.AttrPlatform:   "iOS",
+		targeting.AttrAppVersion: "5.10",
+		targeting.AttrCountry:    "kz",
+		"attr.segment":           "beta",
+	}
+
+	testCases := []struct {
+		rule     string
+		expected bool
+	}{
+		{rule: "", expected: true},
+		{rule: `platform == "ios" && app_version >= "5.2"`, expected: true},
+		{rule: `app_version < 5.9`, expected: false},
+		{rule: `app_version == "5.10.0"`, expected: true},
+		{rule: `country in ["ru", "KZ"]`, expected: true},
+		{rule: `platform == "android" || attr.segment == "beta"`, expected: true},
+		{rule: `!(attr.segment == "beta")`, expected: false},
+		{rule: `attr.missing != "x"`, expected: false},
+		{rule: `!(attr.missing == "x")`, expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.rule, func(t *testing.T) {
+			rule, err := targeting.Parse(tc.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, rule.Match(attrs))
+		})
+	}
+}
+
+func TestTargetingRuleErrors(t *testing.T) {
+	testCases := []struct {
+		rule          string
+		expectedError string
+	}{
+		{rule: `platform = "ios"`, expectedError: "unexpected character '=' at 10"},
+		{rule: `os == "ios"`, expectedError: "unknown attribute 'os' at 1"},
+		{rule: `platform == "ios" &&`, expectedError: "unexpected end of expression"},
+		{rule: `(platform == "ios"`, expectedError: "unexpected end of expression"},
+		{rule: `country in "ru"`, expectedError: "unexpected 'ru' at 12"},
+		{rule: `platform == "ios`, expectedError: "unterminated string at 13"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.rule, func(t *testing.T) {
+			_, err := targeting.Parse(tc.rule)
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+// banners of feature 17 are not in the test data
+func (suite *BannerHandlerSuite) TestBannerRules() {
+	create := func(body string) int64 {
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "", body)
+		suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+
+		var created dto.CreateBannerResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+
+		return created.BannerId
+	}
+
+	ios := create(`{"feature_id": 17, "tag_ids": [1], "content": {"title": "ios"}, "is_active": true, "priority": 1,
+		"rule": "platform == \"ios\" && app_version >= \"5.2\""}`)
+	create(`{"feature_id": 17, "tag_ids": [2], "content": {"title": "any"}, "is_active": true}`)
+
+	suite.Run("InvalidRule", func() {
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner", "aap_1", "",
+			`{"feature_id": 17, "tag_ids": [3], "content": {}, "rule": "platform = 1"}`)
+		suite.Equal(http.StatusBadRequest, rec.Code)
+		suite.Contains(rec.Body.String(), "unexpected character")
+	})
+
+	suite.Run("PatchWithInvalidRule", func() {
+		path := "/api/v1/banner/" + strconv.FormatInt(ios, 10)
+		rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"rule": "platform =="}`)
+		suite.Equal(http.StatusBadRequest, rec.Code, rec.Body.String())
+
+		rec = suite.serveInEnvironment("GET", "/api/v1/user_banner?feature_id=17&tag_id=1,2&use_last_revision=true", "aup_1", "", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+		suite.JSONEq(`{"content": {"title": "any"}}`, rec.Body.String(), "rule is not changed")
+	})
+
+	testCases := []struct {
+		name          string
+		query         string
+		expectedTitle string
+	}{
+		{name: "RuleMatches", query: "&platform=ios&app_version=5.10", expectedTitle: "ios"},
+		{name: "OldVersion", query: "&platform=ios&app_version=5.1", expectedTitle: "any"},
+		{name: "NoAttributes", query: "", expectedTitle: "any"},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			rec := suite.serveInEnvironment("GET", "/api/v1/user_banner?feature_id=17&tag_id=1,2"+tc.query, "aup_1", "", "")
+			suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+			suite.JSONEq(`{"content": {"title": "`+tc.expectedTitle+`"}}`, rec.Body.String())
+		})
+	}
+
+	suite.Run("Preview", func() {
+		body := `{"feature_id": 17, "tag_ids": [1, 2], "attributes": {"platform": "ios", "app_version": "6"}}`
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner/preview", "aap_1", "", body)
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		var resp dto.PreviewBannerResponseDto
+		suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+		suite.Equal(ios, resp.BannerId)
+		suite.Equal(int64(1), resp.TagId)
+	})
+
+	suite.Run("PreviewNoBanner", func() {
+		body := `{"feature_id": 17, "tag_ids": [1], "attributes": {"platform": "android"}}`
+		rec := suite.serveInEnvironment("POST", "/api/v1/banner/preview", "aap_1", "", body)
+		suite.Equal(http.StatusNotFound, rec.Code)
+	})
+
+	suite.Run("RuleRemoved", func() {
+		path := "/api/v1/banner/" + strconv.FormatInt(ios, 10)
+		rec := suite.serveInEnvironment("PATCH", path, "aap_1", "", `{"rule": ""}`)
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = suite.serveInEnvironment("GET", "/api/v1/user_banner?feature_id=17&tag_id=1&use_last_revision=true", "aup_1", "", "")
+		suite.JSONEq(`{"content": {"title": "ios"}}`, rec.Body.String())
+	})
+}
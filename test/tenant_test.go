@@ -0,0 +1,60 @@
+package test
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTenantSelection(t *testing.T) {
+	router := mux.NewRouter()
+	subrouter := router.PathPrefix("/api/v1").Subrouter()
+	subrouter.Use(service.TokenValidationMiddleware)
+	subrouter.Use(service.EnvironmentMiddleware(service.EnvironmentConfig{}))
+	subrouter.Use(service.TenantMiddleware)
+	subrouter.HandleFunc("/user_banner", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(service.Tenant(r.Context()) + "/" + service.Environment(r.Context())))
+	})
+
+	testCases := []struct {
+		name           string
+		token          string
+		expectedStatus int
+		expectedScope  string
+	}{
+		{name: "Default", token: "aup_1", expectedStatus: http.StatusOK, expectedScope: "default/production"},
+		{name: "Tenant", token: "aup_1#shop", expectedStatus: http.StatusOK, expectedScope: "shop/production"},
+		{name: "Tenant and environment", token: "aap_1#shop@staging", expectedStatus: http.StatusOK, expectedScope: "shop/staging"},
+		{name: "Empty tenant", token: "aup_1#", expectedStatus: http.StatusOK, expectedScope: "default/production"},
+		{name: "Invalid tenant", token: "aup_1#Shop", expectedStatus: http.StatusBadRequest},
+		{name: "Separator in tenant", token: "aup_1#shop:1", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/user_banner", nil)
+			req.Header.Set("X-Access-Token", tc.token)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedScope != "" {
+				assert.Equal(t, tc.expectedScope, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	tc := service.TenantConfig{
+		Quota:  service.Quota{MaxBanners: 100},
+		Quotas: map[string]service.Quota{"shop": {MaxBanners: 10, MaxContentSize: 1024}},
+	}
+
+	assert.Equal(t, service.Quota{MaxBanners: 100}, tc.QuotaOf(service.DefaultTenant))
+	assert.Equal(t, service.Quota{MaxBanners: 10, MaxContentSize: 1024}, tc.QuotaOf("shop"))
+}
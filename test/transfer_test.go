@@ -0,0 +1,141 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/mBayzigitov/dynamic-content-service/internal/dto"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+// banners of feature 11 are not in the test data
+const importLines = `{"external_key": "promo-1", "feature_id": 11, "tag_ids": [1, 2], "content": {"title": "promo"}, "is_active": true}
+{"feature_id": 11, "tag_ids": [2, 3], "content": {"title": "clash"}}
+
+{"feature_id": 11, "tag_ids": [4],`
+
+func (suite *BannerHandlerSuite) TestImportAndExport() {
+	suite.Run("DryRunDoesNotWrite", func() {
+		resp := suite.importBanners("?dry_run=true", importLines)
+
+		suite.True(resp.DryRun)
+		suite.Equal(1, resp.Created)
+		suite.Equal(2, resp.Failed)
+		suite.Require().Len(resp.Lines, 3)
+		suite.Equal("create", resp.Lines[0].Action)
+		suite.Contains(resp.Lines[1].Error, "строке 1")
+		suite.Equal(4, resp.Lines[2].Line, "line numbers count empty lines")
+		suite.NotEmpty(resp.Lines[2].Error)
+
+		suite.Empty(suite.exportBanners("?feature_id=11"))
+	})
+
+	var bannerId int64
+	suite.Run("Create", func() {
+		resp := suite.importBanners("", importLines)
+
+		suite.False(resp.DryRun)
+		suite.Equal(1, resp.Created)
+		bannerId = resp.Lines[0].BannerId
+		suite.Positive(bannerId)
+	})
+
+	suite.Run("KnownKeyFailsInCreateMode", func() {
+		resp := suite.importBanners("", strings.Split(importLines, "\n")[0])
+
+		suite.Equal(1, resp.Failed)
+		suite.NotEmpty(resp.Lines[0].Error)
+	})
+
+	suite.Run("UpsertRewritesBanner", func() {
+		line := `{"external_key": "promo-1", "feature_id": 11, "tag_ids": [1], "content": {"title": "promo v2"}, "is_active": true}`
+		resp := suite.importBanners("?mode=upsert", line)
+
+		suite.Equal(1, resp.Updated)
+		suite.Equal("update", resp.Lines[0].Action)
+		suite.Equal(bannerId, resp.Lines[0].BannerId)
+	})
+
+	suite.Run("ExportWithVersions", func() {
+		lines := suite.exportBanners("?feature_id=11&versions=true")
+
+		suite.Require().Len(lines, 1)
+		suite.Equal("promo-1", lines[0].ExternalKey)
+		suite.Equal([]int64{1}, lines[0].TagIds)
+		suite.JSONEq(`{"title": "promo v2"}`, string(lines[0].Content))
+		suite.Len(lines[0].Versions, 2)
+	})
+
+	suite.Run("InvalidExportFilter", func() {
+		rec := suite.serveWith(suite.router, "GET", "/api/v1/banner/export?feature_id=abc", "aap_1", "")
+		suite.Equal(http.StatusBadRequest, rec.Code)
+		suite.Contains(rec.Body.String(), "'feature_id'", "error names the query parameter")
+	})
+}
+
+// banners of feature 31 are not in the test data
+func (suite *BannerHandlerSuite) TestImportDefaultBanners() {
+	lines := `{"external_key": "default-1", "feature_id": 31, "tag_ids": [1], "content": {"title": "a"}, "is_active": true, "is_default": true}
+{"external_key": "default-2", "feature_id": 31, "tag_ids": [2], "content": {"title": "b"}, "is_active": true, "is_default": true}`
+
+	suite.Run("DryRunMatchesImport", func() {
+		for _, query := range []string{"?dry_run=true", ""} {
+			resp := suite.importBanners(query, lines)
+
+			suite.Equal(1, resp.Created, query)
+			suite.Equal(1, resp.Failed, query)
+			suite.Require().Len(resp.Lines, 2)
+			suite.Contains(resp.Lines[1].Error, "строке 1")
+		}
+	})
+
+	suite.Run("StoredDefaultClashes", func() {
+		resp := suite.importBanners("", strings.Split(lines, "\n")[1])
+
+		suite.Equal(1, resp.Failed)
+		suite.NotEmpty(resp.Lines[0].Error)
+	})
+
+	suite.Run("UpsertKeepsOwnDefault", func() {
+		line := `{"external_key": "default-1", "feature_id": 31, "tag_ids": [1], "content": {"title": "a v2"}, "is_active": true, "is_default": true}`
+		resp := suite.importBanners("?mode=upsert", line)
+
+		suite.Equal(1, resp.Updated)
+		suite.Empty(resp.Lines[0].Error)
+	})
+}
+
+func (suite *BannerHandlerSuite) importBanners(query string, body string) dto.ImportBannersResponseDto {
+	req := httptest.NewRequest("POST", "/api/v1/banner/import"+query, strings.NewReader(body))
+	req.Header.Set("X-Access-Token", "aap_1")
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, req)
+	suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp dto.ImportBannersResponseDto
+	suite.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	return resp
+}
+
+func (suite *BannerHandlerSuite) exportBanners(query string) []dto.BannerLineDto {
+	req := httptest.NewRequest("GET", "/api/v1/banner/export"+query, nil)
+	req.Header.Set("X-Access-Token", "aap_1")
+
+	rec := httptest.NewRecorder()
+	suite.router.ServeHTTP(rec, req)
+	suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	suite.Equal("application/x-ndjson", rec.Header().Get("Content-Type"))
+
+	var lines []dto.BannerLineDto
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		var line dto.BannerLineDto
+		suite.Require().NoError(json.Unmarshal(sc.Bytes(), &line))
+		lines = append(lines, line)
+	}
+
+	return lines
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/mBayzigitov/dynamic-content-service/internal/handler/health"
+	"github.com/mBayzigitov/dynamic-content-service/internal/repo"
+	"github.com/mBayzigitov/dynamic-content-service/internal/service"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// banners of feature 21 are not in the test data
+func (suite *BannerHandlerSuite) TestWarmUp() {
+	rec := suite.serveWith(suite.router, "POST", "/api/v1/banner", "aap_1",
+		`{"feature_id": 21, "tag_ids": [1], "content": {"title": "warm"}, "is_active": true}`)
+	suite.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	suite.Require().Empty(suite.cachedEntry("21_1"))
+
+	logger := zap.NewNop().Sugar()
+	bs := suite.newService(service.CacheConfig{WarmUp: service.WarmUpConfig{Enabled: true}})
+	hs := service.NewHealthService(suite.pool, repo.NewBannerRepository(suite.pool, logger), repo.NewCacheRepo(suite.rediscli, repo.BreakerConfig{}, logger), bs, 0, logger)
+
+	router := mux.NewRouter()
+	health.NewHandler(hs).RegisterRoutes(router)
+
+	suite.Run("NotReadyBeforeWarmUp", func() {
+		rec := suite.serveWith(router, "GET", "/readyz", "", "")
+		suite.Equal(http.StatusServiceUnavailable, rec.Code)
+		suite.Contains(rec.Body.String(), "warming up")
+	})
+
+	bs.StartWarmUp()
+
+	suite.Run("ReadyAfterWarmUp", func() {
+		suite.Eventually(func() bool {
+			return suite.serveWith(router, "GET", "/readyz", "", "").Code == http.StatusOK
+		}, 10*time.Second, 50*time.Millisecond)
+
+		// ready is set once every pair is loaded
+		suite.Contains(suite.cachedEntry("21_1"), `"title":"warm"`)
+	})
+}
+
+// banners of feature 29 are not in the test data
+func (suite *BannerHandlerSuite) TestWarmUpHits() {
+	suite.createBanner(`{"feature_id": 29, "tag_ids": [1], "content": {"title": "hit"}, "is_active": true}`)
+	router := suite.newRouter(suite.newService(service.CacheConfig{WarmUp: service.WarmUpConfig{Enabled: true, Limit: 10}}))
+	hits := func(ft string) *redis.FloatCmd {
+		key := service.DefaultTenant + ":" + service.EnvironmentProduction + ":" + ft
+		return suite.rediscli.ZScore(context.Background(), service.HitsSet, key)
+	}
+
+	suite.Run("MissIsNotCounted", func() {
+		for range 2 {
+			rec := suite.serveWith(router, "GET", "/api/v1/user_banner?feature_id=29&tag_id=2", "aup_1", "")
+			suite.Equal(http.StatusNotFound, rec.Code)
+		}
+
+		suite.ErrorIs(hits("29_2").Err(), redis.Nil)
+	})
+
+	suite.Run("FoundIsCounted", func() {
+		rec := suite.serveWith(router, "GET", "/api/v1/user_banner?feature_id=29&tag_id=1", "aup_1", "")
+		suite.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+
+		suite.Positive(hits("29_1").Val())
+		suite.Positive(suite.rediscli.TTL(context.Background(), service.HitsSet).Val(), "counts expire")
+	})
+}